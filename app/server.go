@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"net"
@@ -33,9 +34,28 @@ func handleConnection(redis redis.Node, conn net.Conn) {
 	if redis.IsSlave() {
 		defer redis.RemoveSlaveConn(conn)
 	}
+	client := util.NewClient(conn)
+	defer client.Close()
+	inputs := make(chan []string, 128)
+	go readCommands(conn, client, inputs)
+	for input := range inputs {
+		cmd, err := command.NewCommand(input)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		util.Execute(redis, client, *cmd)
+	}
+}
+
+// readCommands parses commands off conn while earlier ones execute, so that a
+// client disconnecting while blocked is noticed straight away.
+func readCommands(conn net.Conn, client *util.Client, inputs chan<- []string) {
+	defer close(inputs)
+	defer client.Close()
+	reader := bufio.NewReader(conn)
 	for {
-		buf := make([]byte, 1024)
-		n, err := conn.Read(buf)
+		input, err := resp.ReadCommand(reader)
 		if err == io.EOF {
 			fmt.Println("Connection closed")
 			return
@@ -44,14 +64,6 @@ func handleConnection(redis redis.Node, conn net.Conn) {
 			fmt.Println("Error reading:", err.Error())
 			return
 		}
-		inputArr := resp.NewResp(buf[:n])
-		for _, input := range inputArr {
-			cmd, err := command.NewCommand(input)
-			if err != nil {
-				fmt.Println(err.Error())
-				return
-			}
-			util.Execute(redis, conn, *cmd)
-		}
+		inputs <- input
 	}
-}
\ No newline at end of file
+}
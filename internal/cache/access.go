@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	// lfuInitVal is the access frequency of new keys, so that they are not
+	// the first to go before they had a chance to be read.
+	lfuInitVal = 5
+	// lfuLogFactor slows down how fast the frequency grows: it takes about a
+	// million accesses to saturate it.
+	lfuLogFactor = 10
+	// lfuDecayTime is how many minutes without access take one off the
+	// frequency.
+	lfuDecayTime = 1
+)
+
+// decayedFreq returns the access frequency of data as of now, lowered by one
+// for every lfuDecayTime minutes since it was last accessed.
+func (data storeData) decayedFreq(now int64) uint8 {
+	periods := (now - data.accessed) / (60 * 1000) / lfuDecayTime
+	if periods >= int64(data.freq) {
+		return 0
+	}
+	return data.freq - uint8(max(periods, 0))
+}
+
+// touch records an access: the frequency is decayed, then raised with a
+// probability that shrinks as it grows, as Redis's LFU counter is.
+func (data *storeData) touch(now int64) {
+	freq := data.decayedFreq(now)
+	if freq < 255 {
+		base := float64(freq) - lfuInitVal
+		if base < 0 {
+			base = 0
+		}
+		if rand.Float64() < 1/(base*lfuLogFactor+1) {
+			freq++
+		}
+	}
+	data.freq = freq
+	data.accessed = now
+}
+
+// NoTouch returns a view of the store whose commands leave the access time
+// and frequency of keys alone, for clients in CLIENT NO-TOUCH mode.
+func (store *Store) NoTouch() Cache {
+	view := *store
+	view.noTouch = true
+	return &view
+}
+
+// Touch records an access to each of keys, even from a NoTouch view, and
+// returns how many exist.
+func (store *Store) Touch(keys []string) int {
+	store.lock(keys...)
+	defer store.unlock(keys...)
+	n := 0
+	for _, key := range keys {
+		if _, ok := store.lookupKey(key, true); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// KeyInfo is what OBJECT reports about a key. IdleTime is in milliseconds
+// and RefCount is only above 1 for shared integers.
+type KeyInfo struct {
+	Encoding string
+	IdleTime int64
+	Freq int
+	RefCount int
+}
+
+// ObjectInfo describes the value at key without recording an access.
+func (store *Store) ObjectInfo(key string) (KeyInfo, bool) {
+	store.lock(key)
+	defer store.unlock(key)
+	data, ok := store.lookupKey(key, false)
+	if !ok {
+		return KeyInfo{}, false
+	}
+	now := time.Now().UnixMilli()
+	info := KeyInfo{Encoding: data.encoding(), IdleTime: now - data.accessed, Freq: int(data.decayedFreq(now)), RefCount: 1}
+	if n := data.value.Int; n != nil && *n >= 0 && *n < sharedIntegers && n == &sharedIntegerPool[*n] {
+		info.RefCount = 2147483647
+	}
+	return info, true
+}
@@ -0,0 +1,392 @@
+package cache
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+)
+
+const MaxBitOffset = maxStringSize*8 - 1
+
+var ErrBitOffset = fmt.Errorf("ERR bit offset is not an integer or out of range")
+
+// BitFieldOp is one GET, SET or INCRBY operation of a BITFIELD command. Value
+// holds the value to set or the increment.
+type BitFieldOp struct {
+	Op string
+	Signed bool
+	Bits int
+	Offset int64
+	Value int64
+	Overflow string
+}
+
+// bitRange normalises a BITCOUNT/BITPOS range over a string of length bytes
+// into inclusive bit positions. ok is false when the range is empty.
+func bitRange(length int, start, end int64, bitUnit bool) (int64, int64, bool) {
+	total := int64(length)
+	if bitUnit {
+		total *= 8
+	}
+	if start < 0 && end < 0 && start > end {
+		return 0, 0, false
+	}
+	if start < 0 {
+		start = total + start
+	}
+	if end < 0 {
+		end = total + end
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= total {
+		end = total - 1
+	}
+	if start > end {
+		return 0, 0, false
+	}
+	if !bitUnit {
+		start, end = start*8, end*8+7
+	}
+	return start, end, true
+}
+
+// bitString is a string value as bitmap commands read it: the String of an
+// item, or its Bytes once SETBIT or BITFIELD has written it.
+type bitString interface {
+	~string | ~[]byte
+}
+
+func getBit[B bitString](buf B, offset int64) int {
+	idx := offset >> 3
+	if idx >= int64(len(buf)) {
+		return 0
+	}
+	return int(buf[idx]>>(7-uint(offset&7))) & 1
+}
+
+func setBit(buf []byte, offset int64, bit int) {
+	mask := byte(1) << (7 - uint(offset&7))
+	if bit == 1 {
+		buf[offset>>3] |= mask
+	} else {
+		buf[offset>>3] &^= mask
+	}
+}
+
+// growBuf pads buf with zero bytes so that it holds bit offset.
+func growBuf(buf []byte, offset int64) []byte {
+	need := int(offset>>3) + 1
+	if need > len(buf) {
+		buf = append(buf, make([]byte, need-len(buf))...)
+	}
+	return buf
+}
+
+// bitmap returns the string as bytes to write bits into. Only the first write
+// copies the string, after that the bytes are changed in place.
+func (it item) bitmap() []byte {
+	if it.Bytes != nil {
+		return it.Bytes
+	}
+	return []byte(it.str())
+}
+
+// putBitmap stores buf, as returned by bitmap, at key.
+func (store *Store) putBitmap(key string, buf []byte, ttl int64) {
+	store.put(key, storeData{
+		value: item{Bytes: buf},
+		dataType: "string",
+		ttl: ttl,
+	})
+}
+
+func (store *Store) SetBit(key string, offset int64, bit int) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	data, _, err := store.getString(key)
+	if err != nil {
+		return 0, err
+	}
+	buf := growBuf(data.value.bitmap(), offset)
+	old := getBit(buf, offset)
+	setBit(buf, offset, bit)
+	store.putBitmap(key, buf, data.ttl)
+	return old, nil
+}
+
+func (store *Store) GetBit(key string, offset int64) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	data, _, err := store.getString(key)
+	if err != nil {
+		return 0, err
+	}
+	if data.value.Bytes != nil {
+		return getBit(data.value.Bytes, offset), nil
+	}
+	return getBit(data.value.str(), offset), nil
+}
+
+// BitCount counts the set bits of the string at key, within [start, end] when
+// hasRange is set. The range is in bytes unless bitUnit is set.
+func (store *Store) BitCount(key string, start, end int64, hasRange, bitUnit bool) (int64, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	data, _, err := store.getString(key)
+	if err != nil {
+		return 0, err
+	}
+	if !hasRange {
+		start, end, bitUnit = 0, -1, false
+	}
+	if data.value.Bytes != nil {
+		return countBits(data.value.Bytes, start, end, bitUnit), nil
+	}
+	return countBits(data.value.str(), start, end, bitUnit), nil
+}
+
+func countBits[B bitString](buf B, start, end int64, bitUnit bool) int64 {
+	first, last, ok := bitRange(len(buf), start, end, bitUnit)
+	if !ok {
+		return 0
+	}
+	count := int64(0)
+	for pos := first; pos <= last; {
+		if pos&7 == 0 && pos+7 <= last {
+			count += int64(bits.OnesCount8(buf[pos>>3]))
+			pos += 8
+			continue
+		}
+		count += int64(getBit(buf, pos))
+		pos++
+	}
+	return count
+}
+
+// BitPos returns the position of the first bit set to bit within the range,
+// following the BITPOS rules for missing keys and open-ended ranges.
+func (store *Store) BitPos(key string, bit int, start, end int64, hasEnd, bitUnit bool) (int64, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	data, ok, err := store.getString(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+	if !hasEnd {
+		end, bitUnit = -1, false
+	}
+	if data.value.Bytes != nil {
+		return findBit(data.value.Bytes, bit, start, end, hasEnd, bitUnit), nil
+	}
+	return findBit(data.value.str(), bit, start, end, hasEnd, bitUnit), nil
+}
+
+func findBit[B bitString](buf B, bit int, start, end int64, hasEnd, bitUnit bool) int64 {
+	first, last, ok := bitRange(len(buf), start, end, bitUnit)
+	if !ok {
+		return -1
+	}
+	skip := byte(0)
+	if bit == 0 {
+		skip = 0xff
+	}
+	for pos := first; pos <= last; {
+		if pos&7 == 0 && pos+7 <= last && buf[pos>>3] == skip {
+			pos += 8
+			continue
+		}
+		if getBit(buf, pos) == bit {
+			return pos
+		}
+		pos++
+	}
+	// Looking for a clear bit with no explicit end: the string is treated as
+	// padded with zeros on the right.
+	if bit == 0 && !hasEnd {
+		return last + 1
+	}
+	return -1
+}
+
+// BitOp stores the result of op over the strings at keys into dest and
+// returns its length.
+func (store *Store) BitOp(op, dest string, keys []string) (int, error) {
+	locked := append([]string{dest}, keys...)
+	store.lock(locked...)
+	defer store.unlock(locked...)
+	sources := make([][]byte, len(keys))
+	maxLen := 0
+	for i, key := range keys {
+		data, _, err := store.getString(key)
+		if err != nil {
+			return 0, err
+		}
+		sources[i] = []byte(data.value.str())
+		if len(sources[i]) > maxLen {
+			maxLen = len(sources[i])
+		}
+	}
+	res := make([]byte, maxLen)
+	for i := 0; i < maxLen; i++ {
+		var b byte
+		for j, src := range sources {
+			var s byte
+			if i < len(src) {
+				s = src[i]
+			}
+			switch {
+			case op == "not":
+				b = ^s
+			case j == 0:
+				b = s
+			case op == "and":
+				b &= s
+			case op == "or":
+				b |= s
+			case op == "xor":
+				b ^= s
+			}
+		}
+		res[i] = b
+	}
+	if maxLen == 0 {
+		store.remove(dest)
+		return 0, nil
+	}
+	store.putString(dest, string(res), 0)
+	return maxLen, nil
+}
+
+func readBits[B bitString](buf B, offset int64, width int) uint64 {
+	value := uint64(0)
+	for i := 0; i < width; i++ {
+		value = value<<1 | uint64(getBit(buf, offset+int64(i)))
+	}
+	return value
+}
+
+func writeBits(buf []byte, offset int64, width int, value uint64) {
+	for i := 0; i < width; i++ {
+		setBit(buf, offset+int64(i), int(value>>(uint(width-1-i)))&1)
+	}
+}
+
+// fieldValue interprets the raw bits of a field as signed or unsigned.
+func fieldValue(raw uint64, width int, signed bool) int64 {
+	if signed && width < 64 && raw&(1<<uint(width-1)) != 0 {
+		return int64(raw | ^uint64(0)<<uint(width))
+	}
+	return int64(raw)
+}
+
+// fitField applies the overflow policy to v for a field of the given width.
+// It reports false when the policy is FAIL and v does not fit.
+func fitField(v *big.Int, width int, signed bool, overflow string) (uint64, bool) {
+	lo, hi := big.NewInt(0), new(big.Int).Lsh(big.NewInt(1), uint(width))
+	hi.Sub(hi, big.NewInt(1))
+	if signed {
+		lo.Lsh(big.NewInt(1), uint(width-1))
+		lo.Neg(lo)
+		hi.Lsh(big.NewInt(1), uint(width-1))
+		hi.Sub(hi, big.NewInt(1))
+	}
+	if v.Cmp(lo) < 0 || v.Cmp(hi) > 0 {
+		switch overflow {
+		case "fail":
+			return 0, false
+		case "sat":
+			if v.Cmp(lo) < 0 {
+				v = lo
+			} else {
+				v = hi
+			}
+		}
+	}
+	mod := new(big.Int).Lsh(big.NewInt(1), uint(width))
+	wrapped := new(big.Int).Mod(v, mod)
+	return wrapped.Uint64(), true
+}
+
+// BitField runs ops against the string at key. Each result is nil when an
+// INCRBY or SET failed under OVERFLOW FAIL.
+func (store *Store) BitField(key string, ops []BitFieldOp) ([]*int64, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	data, ok, err := store.getString(key)
+	if err != nil {
+		return nil, err
+	}
+	write := false
+	for _, op := range ops {
+		write = write || op.Op != "get"
+	}
+	if !write {
+		if data.value.Bytes != nil {
+			return getFields(data.value.Bytes, ops), nil
+		}
+		return getFields(data.value.str(), ops), nil
+	}
+	buf := data.value.bitmap()
+	for _, op := range ops {
+		if op.Op != "get" {
+			buf = growBuf(buf, op.Offset+int64(op.Bits)-1)
+		}
+	}
+	results := make([]*int64, len(ops))
+	for i, op := range ops {
+		raw := readBits(buf, op.Offset, op.Bits)
+		old := fieldValue(raw, op.Bits, op.Signed)
+		var target *big.Int
+		switch op.Op {
+		case "get":
+			results[i] = &old
+			continue
+		case "set":
+			target = big.NewInt(op.Value)
+			if !op.Signed {
+				target = new(big.Int).SetUint64(uint64(op.Value))
+			}
+		case "incrby":
+			target = new(big.Int).Add(big.NewInt(old), big.NewInt(op.Value))
+		}
+		fitted, ok := fitField(target, op.Bits, op.Signed, op.Overflow)
+		if !ok {
+			continue
+		}
+		writeBits(buf, op.Offset, op.Bits, fitted)
+		res := fieldValue(fitted, op.Bits, op.Signed)
+		if op.Op == "set" {
+			res = old
+		}
+		results[i] = &res
+	}
+	ttl := int64(0)
+	if ok {
+		ttl = data.ttl
+	}
+	store.putBitmap(key, buf, ttl)
+	return results, nil
+}
+
+// getFields runs a BITFIELD made only of GET operations.
+func getFields[B bitString](buf B, ops []BitFieldOp) []*int64 {
+	results := make([]*int64, len(ops))
+	for i, op := range ops {
+		value := fieldValue(readBits(buf, op.Offset, op.Bits), op.Bits, op.Signed)
+		results[i] = &value
+	}
+	return results
+}
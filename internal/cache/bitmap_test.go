@@ -0,0 +1,76 @@
+package cache
+
+import "testing"
+
+func TestSetBitInPlace(t *testing.T) {
+	store := newStore()
+	store.Set("bits", "a", 0)
+	if old, err := store.SetBit("bits", 6, 1); err != nil || old != 0 {
+		t.Fatalf("SETBIT bits 6 1 = %d, %v", old, err)
+	}
+	first := store.shard("bits").data["bits"].value.Bytes
+	if old, _ := store.SetBit("bits", 7, 0); old != 1 {
+		t.Fatalf("SETBIT bits 7 0 = %d, want 1", old)
+	}
+	if second := store.shard("bits").data["bits"].value.Bytes; &second[0] != &first[0] {
+		t.Fatal("second SETBIT copied the string")
+	}
+	if value, _ := store.Get("bits"); value != "b" {
+		t.Fatalf("GET after SETBIT = %q, want %q", value, "b")
+	}
+	if info, _ := store.ObjectInfo("bits"); info.Encoding != "raw" {
+		t.Fatalf("encoding after SETBIT = %q, want raw", info.Encoding)
+	}
+	if size, _ := store.MemoryUsage("bits"); size <= 0 {
+		t.Fatalf("MEMORY USAGE after SETBIT = %d", size)
+	}
+
+	if old, _ := store.SetBit("bits", 23, 1); old != 0 {
+		t.Fatalf("SETBIT past the end = %d, want 0", old)
+	}
+	if value, _ := store.Get("bits"); value != "b\x00\x01" {
+		t.Fatalf("GET after growing = %q", value)
+	}
+	for offset, want := range map[int64]int{6: 1, 7: 0, 23: 1, 100: 0} {
+		if bit, _ := store.GetBit("bits", offset); bit != want {
+			t.Fatalf("GETBIT bits %d = %d, want %d", offset, bit, want)
+		}
+	}
+	if count, _ := store.BitCount("bits", 0, 0, false, false); count != 4 {
+		t.Fatalf("BITCOUNT = %d, want 4", count)
+	}
+	if pos, _ := store.BitPos("bits", 1, 1, 0, false, false); pos != 23 {
+		t.Fatalf("BITPOS bits 1 1 = %d, want 23", pos)
+	}
+
+	store.Set("bits", "a", 0)
+	if value, _ := store.Get("bits"); value != "a" {
+		t.Fatalf("GET after SET = %q", value)
+	}
+	if info, _ := store.ObjectInfo("bits"); info.Encoding != "embstr" {
+		t.Fatalf("encoding after SET = %q, want embstr", info.Encoding)
+	}
+}
+
+func TestBitFieldInPlace(t *testing.T) {
+	store := newStore()
+	store.Set("bits", "12", 0)
+	read := []BitFieldOp{{Op: "get", Bits: 8, Offset: 0}, {Op: "get", Bits: 8, Offset: 8}}
+	results, err := store.BitField("bits", read)
+	if err != nil || *results[0] != '1' || *results[1] != '2' {
+		t.Fatalf("BITFIELD GET of an integer = %v, %v", results, err)
+	}
+	if store.shard("bits").data["bits"].value.Bytes != nil {
+		t.Fatal("BITFIELD GET converted the string to bytes")
+	}
+	write := []BitFieldOp{{Op: "incrby", Bits: 8, Offset: 8, Value: 1, Overflow: "wrap"}}
+	if results, _ := store.BitField("bits", write); *results[0] != '3' {
+		t.Fatalf("BITFIELD INCRBY = %d", *results[0])
+	}
+	if results, _ := store.BitField("bits", read); *results[1] != '3' {
+		t.Fatalf("BITFIELD GET after INCRBY = %d", *results[1])
+	}
+	if n, err := store.IncrBy("bits", 1); err != nil || n != 14 {
+		t.Fatalf("INCRBY after BITFIELD = %d, %v", n, err)
+	}
+}
@@ -2,199 +2,381 @@ package cache
 
 import (
 	"fmt"
-	"strconv"
-	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Cache interface {
 	Get(key string) (string, error)
 	Set(key, value string, px int64)
+	SetWithOptions(key, value string, opts SetOptions) (SetResult, error)
 	Del(key string)
+	IncrBy(key string, delta int64) (int64, error)
+	IncrByFloat(key string, delta float64) (string, error)
+	Append(key, value string) (int, error)
+	StrLen(key string) (int, error)
+	GetRange(key string, start, end int64) (string, error)
+	SetRange(key string, offset int64, value string) (int, error)
+	MGet(keys []string) []*string
+	MSet(pairs []string, nx bool) bool
+	GetDel(key string) (string, bool, error)
+	GetEx(key string, expireAt int64, persist bool) (string, bool, error)
+	SetBit(key string, offset int64, bit int) (int, error)
+	GetBit(key string, offset int64) (int, error)
+	BitCount(key string, start, end int64, hasRange, bitUnit bool) (int64, error)
+	BitPos(key string, bit int, start, end int64, hasEnd, bitUnit bool) (int64, error)
+	BitOp(op, dest string, keys []string) (int, error)
+	BitField(key string, ops []BitFieldOp) ([]*int64, error)
+	PFAdd(key string, elements []string) (bool, error)
+	PFCount(keys []string) (int64, error)
+	PFMerge(dest string, keys []string) error
+	Push(key string, values []string, left, onlyExisting bool) (int, error)
+	Pop(key string, count int, left bool) ([]string, bool, error)
+	LLen(key string) (int, error)
+	LRange(key string, start, stop int64) ([]string, error)
+	LIndex(key string, index int64) (string, bool, error)
+	LSet(key string, index int64, value string) error
+	LRem(key string, count int64, element string) (int, error)
+	LTrim(key string, start, stop int64) error
+	LInsert(key string, before bool, pivot, element string) (int, error)
+	LPos(key, element string, rank, count, maxLen int64) ([]int64, error)
+	LMove(src, dst string, fromLeft, toLeft bool) (string, bool, error)
+	LMPop(keys []string, left bool, count int) (string, []string, error)
+	HSet(key string, pairs []string, nx bool) (int, error)
+	HGet(key string, fields []string) ([]*string, error)
+	HDel(key string, fields []string) (int, error)
+	HGetAll(key string) ([]string, error)
+	HLen(key string) (int, error)
+	HIncrBy(key, field string, delta int64) (int64, error)
+	HIncrByFloat(key, field string, delta float64) (string, error)
+	HRandField(key string, count int64) ([]string, error)
+	PExpireAt(key string, at int64) bool
+	SAdd(key string, members []string) (int, error)
+	SRem(key string, members []string) (int, error)
+	SMembers(key string) ([]string, error)
+	SIsMember(key string, members []string) ([]bool, error)
+	SCard(key string) (int, error)
+	SPop(key string, count int) ([]string, error)
+	SRandMember(key string, count int64) ([]string, error)
+	SMove(src, dst, member string) (bool, error)
+	SetOp(op string, keys []string) ([]string, error)
+	SetOpStore(op, dest string, keys []string) (int, error)
+	SInterCard(keys []string, limit int) (int, error)
+	ZAdd(key string, members []ZMember, opts ZAddOptions) (int, int, float64, bool, error)
+	ZRem(key string, members []string) (int, error)
+	ZScore(key string, members []string) ([]*float64, error)
+	ZCard(key string) (int, error)
+	ZCount(key string, q ZRangeQuery) (int, error)
+	ZRank(key, member string, rev bool) (int, float64, bool, error)
+	ZRange(key string, q ZRangeQuery) ([]ZMember, error)
+	ZRangeStore(dst, src string, q ZRangeQuery) (int, error)
+	ZPop(key string, count int, max bool) ([]ZMember, error)
+	ZRandMember(key string, count int64) ([]ZMember, error)
+	ZRemRange(key string, q ZRangeQuery) (int, error)
+	GeoPos(key string, members []string) ([]*[2]float64, error)
+	GeoSearch(key string, q GeoQuery) ([]GeoResult, error)
+	GeoSearchStore(dst, src string, q GeoQuery, distScale float64) (int, error)
 	Keys() []string
 	GetType(key string) string
-	SetStream(key string)
-	AddToStream(streamKey, streamId string, data []string) (string, error)
-	GetStream(key, start, end string) []StreamType
+	ObjectInfo(key string) (KeyInfo, bool)
+	Touch(keys []string) int
+	NoTouch() Cache
+	MemoryUsage(key string) (int64, bool)
+	MemoryStats() (used, evicted int64)
+	ExpiredKeys() int64
+	Evict() ([]string, bool)
+	XAdd(key, id string, fields []string, opts XAddOptions) (StreamID, bool, error)
+	XTrim(key string, t StreamTrim) (int, error)
+	XDel(key string, ids []StreamID) (int, error)
+	XConfigSet(key string, retention int64) error
+	XConfigGet(key string) (int64, error)
+	StartStreamRetention(defaultRetention int64, onTrim func(key string, minID StreamID))
+	XLen(key string) (int, error)
+	XSetID(key string, lastID StreamID, entriesAdded *uint64, maxDeletedID *StreamID) error
+	XInfo(key string) (*StreamInfo, error)
+	XInfoFull(key string, count int) (*StreamInfo, []StreamType, []GroupInfo, error)
+	XInfoGroups(key string) ([]GroupInfo, error)
+	XInfoConsumers(key, group string) ([]ConsumerInfo, error)
+	XGroupCreate(key, group, id string, mkStream bool, entriesRead int64) error
+	XGroupSetID(key, group, id string, entriesRead int64) error
+	XGroupDestroy(key, group string) (bool, error)
+	XGroupCreateConsumer(key, group, consumer string) (bool, error)
+	XGroupDelConsumer(key, group, consumer string) (int, error)
+	XReadGroup(queries []GroupReadQuery, group, consumer string, count int, noAck bool) ([]GroupRead, int, error)
+	XAck(key, group string, ids []StreamID) (int, error)
+	XPendingSummary(key, group string) (PendingSummary, error)
+	XPending(key, group string, q PendingQuery) ([]PendingEntry, error)
+	XClaim(key, group, consumer string, minIdle int64, ids []StreamID, opts XClaimOptions) ([]StreamType, []StreamID, error)
+	XAutoClaim(key, group, consumer string, minIdle int64, start StreamID, count int, justID bool) (StreamID, []StreamType, []StreamID, error)
+	RestoreStream(key string, state StreamState)
+	Dump(key string) (Value, bool, error)
+	Restore(key string, v Value, opts RestoreOptions) error
+	Sort(key string, opts SortOptions) ([]*string, error)
+	LCS(key1, key2 string, minMatchLen int) (LCSResult, error)
+	SortStore(key, dest string, opts SortOptions) (int, error)
+	XRange(key string, start, end StreamID, count int, rev bool) ([]StreamType, error)
 }
 
+var ErrWrongType = fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
+
 type Store struct {
-	mu sync.Mutex
-	data map[string]storeData
+	*keyspace
+	// noTouch is set on views of the store that leave access metadata
+	// alone, see NoTouch.
+	noTouch bool
 }
 
-type StreamType struct {
-	Id string
-	Data []string
+// keyspace is the state shared by a store and its views. The keys are
+// spread over shards, each locked on its own, see shard.go. used is the sum
+// of the sizes of the keys.
+type keyspace struct {
+	shards [shardCount]shard
+	used atomic.Int64
+	evicted atomic.Int64
+	expired atomic.Int64
+	// evictionPool is guarded by the locks of all the shards.
+	evictionPool []evictionCandidate
+	// expireCursor is the shard the next active expire cycle starts at.
+	expireCursor int
+	// defaultRetention is the retention of streams that have none of their
+	// own, see StartStreamRetention.
+	defaultRetention atomic.Int64
 }
 
+// item holds a value of any type. Strings that read as integers are kept in
+// Int rather than String, see newStringItem, and strings written by SETBIT or
+// BITFIELD are kept in Bytes so that later writes change them in place.
 type item struct {
 	String string
-	Stream []StreamType
+	Int *int64
+	Bytes []byte
+	Stream *Stream
+	List *Deque
+	Hash *Hash
+	Set *Set
+	ZSet *ZSet
 }
 
+// storeData is a value with its type, its absolute expiry in unix
+// milliseconds, when it was last accessed along with its logarithmic access
+// frequency, see touch, and the memory it was last measured to take.
 type storeData struct {
 	value item
 	dataType string
 	ttl int64
+	accessed int64
+	freq uint8
+	size int64
+}
+
+// SetOptions carries the modifiers of a SET command. ExpireAt is an absolute
+// unix time in milliseconds, 0 meaning the key does not expire.
+type SetOptions struct {
+	NX bool
+	XX bool
+	Get bool
+	KeepTTL bool
+	ExpireAt int64
+}
+
+// SetResult reports whether SET wrote the value and, for SET ... GET, the
+// value the key held before.
+type SetResult struct {
+	Written bool
+	Old string
+	OldExists bool
 }
 
 func newStore() *Store {
-	return &Store{
-		data: make(map[string]storeData),
+	ks := &keyspace{}
+	for i := range ks.shards {
+		ks.shards[i].data = make(map[string]storeData)
+		ks.shards[i].expires = make(map[string]struct{})
+		ks.shards[i].retained = make(map[string]struct{})
+		ks.shards[i].dirty = make(map[string]struct{})
 	}
+	return &Store{keyspace: ks}
 }
 
 func NewCache() Cache {
 	s := newStore()
-	go s.cleanUpRoutine()
+	go s.activeExpireRoutine()
 	return s
 }
 
 func (store *Store) Get(key string) (string, error) {
-	store.cleanUp()
-	store.mu.Lock()
-	defer store.mu.Unlock()
-	if _, ok := store.data[key]; !ok {
+	store.lock(key)
+	defer store.unlock(key)
+	data, ok := store.lookup(key)
+	if !ok {
 		return "", fmt.Errorf("Key does not exist")
 	}
-	return store.data[key].value.String, nil
+	if data.dataType != "string" {
+		return "", ErrWrongType
+	}
+	return data.value.str(), nil
 }
 
 func (store *Store) Set(key, value string, px int64) {
-	store.mu.Lock()
-	defer store.mu.Unlock()
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
 	switch px {
 	case 0:
-		store.data[key] = storeData{
-			value: item{String: value},
+		store.put(key, storeData{
+			value: newStringItem(value),
 			dataType: "string",
 			ttl: 0,
-		}
+		})
 	default:
-		store.data[key] = storeData{
-			value: item{String: value},
+		store.put(key, storeData{
+			value: newStringItem(value),
 			dataType: "string",
 			ttl: time.Now().UnixMilli() + px,
-		}
+		})
 	}
 }
 
-func (store *Store) Del(key string) {
-	store.mu.Lock()
-	defer store.mu.Unlock()
-	delete(store.data, key)
-}
-
-func (store *Store) Keys() []string {
-	store.mu.Lock()
-	defer store.mu.Unlock()
-	keys := []string{}
-	for k := range store.data {
-		keys = append(keys, k)
+func (store *Store) SetWithOptions(key, value string, opts SetOptions) (SetResult, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	res := SetResult{}
+	old, exists := store.lookup(key)
+	if exists && opts.Get {
+		if old.dataType != "string" {
+			return res, ErrWrongType
+		}
+		res.Old = old.value.str()
+		res.OldExists = true
 	}
-	return keys
+	if (opts.NX && exists) || (opts.XX && !exists) {
+		return res, nil
+	}
+	ttl := opts.ExpireAt
+	if opts.KeepTTL && exists {
+		ttl = old.ttl
+	}
+	store.put(key, storeData{
+		value: newStringItem(value),
+		dataType: "string",
+		ttl: ttl,
+	})
+	res.Written = true
+	return res, nil
 }
 
-func (store *Store) cleanUp() {
-	for key, value := range store.data {
-		if value.ttl > 0 && value.ttl < time.Now().UnixMilli() {
-			store.Del(key)
-		}
+// PExpireAt sets the absolute expiry, in unix milliseconds, of an existing
+// key.
+func (store *Store) PExpireAt(key string, at int64) bool {
+	store.lock(key)
+	defer store.unlock(key)
+	data, ok := store.lookup(key)
+	if !ok {
+		return false
 	}
+	data.ttl = at
+	store.put(key, data)
+	return true
 }
 
-func (store *Store) cleanUpRoutine() {
-	for {
-		time.Sleep(120 * time.Second)
-		store.cleanUp()
-	}
+// lookup returns the entry stored at key, dropping it first if it has
+// expired, and records the access unless the store is a NoTouch view. The
+// caller must hold the lock of the shard of key.
+func (store *Store) lookup(key string) (storeData, bool) {
+	return store.lookupKey(key, !store.noTouch)
 }
 
-func (store *Store) GetType(key string) string {
-	store.cleanUp()
-	store.mu.Lock()
-	defer store.mu.Unlock()
-	if _, ok := store.data[key]; !ok {
-		return "none"
+// lookupKey is lookup with the access recorded only when touch is set.
+func (store *Store) lookupKey(key string, touch bool) (storeData, bool) {
+	s := store.shard(key)
+	data, ok := s.data[key]
+	if !ok {
+		return storeData{}, false
+	}
+	now := time.Now().UnixMilli()
+	if data.expired(now) {
+		store.remove(key)
+		store.expired.Add(1)
+		return storeData{}, false
 	}
-	return store.data[key].dataType
+	if touch {
+		data.touch(now)
+		s.data[key] = data
+	}
+	return data, true
 }
 
-func (store *Store) SetStream(key string) {
-	store.mu.Lock()
-	defer store.mu.Unlock()
-	if _, ok := store.data[key]; ok {
-		return
+// put stores data at key. A value replacing another keeps its access
+// metadata; a new one starts with a fresh access time and lfuInitVal. The
+// caller must hold the lock of the shard of key.
+func (store *Store) put(key string, data storeData) {
+	s := store.shard(key)
+	if old, ok := s.data[key]; ok {
+		data.accessed, data.freq = old.accessed, old.freq
+		store.used.Add(-old.size)
+	} else {
+		data.accessed, data.freq = time.Now().UnixMilli(), lfuInitVal
 	}
-	store.data[key] = storeData{
-		value: item{Stream: []StreamType{}},
-		dataType: "stream",
-		ttl: 0,
+	data.size = 0
+	s.data[key] = data
+	if data.ttl > 0 {
+		s.expires[key] = struct{}{}
+	} else {
+		delete(s.expires, key)
 	}
+	store.indexRetention(key, data.value.Stream)
+	s.dirty[key] = struct{}{}
 }
 
-func (store *Store) AddToStream(streamKey, streamId string, data []string) (string, error) {
-	store.mu.Lock()
-	defer store.mu.Unlock()
-	if streamId == "0-0" {
-		return "", fmt.Errorf("ERR The ID specified in XADD must be greater than 0-0")
+// remove deletes key. The caller must hold the lock of the shard of key.
+func (store *Store) remove(key string) {
+	s := store.shard(key)
+	if data, ok := s.data[key]; ok {
+		store.used.Add(-data.size)
+		delete(s.data, key)
+		delete(s.expires, key)
+		delete(s.retained, key)
+		delete(s.dirty, key)
 	}
-	streamData := store.data[streamKey]
-	if streamId == "*" {
-		timestamp := int(time.Now().UnixMilli())
-		streamId = fmt.Sprintf("%d-%d", timestamp, 0)
-	} else {
-		streamIdParts := strings.Split(streamId, "-")
-		lastIdx := len(streamData.value.Stream) - 1
-		if streamIdParts[1] == "*" {
-			prevIdParts := []string{""}
-			if lastIdx > 0 {
-				prevIdParts = strings.Split(streamData.value.Stream[lastIdx].Id, "-")
-			}
-			if streamIdParts[0] == prevIdParts[0] {
-				lastId, _ := strconv.Atoi(prevIdParts[1])
-				streamId = fmt.Sprintf("%s-%d", streamIdParts[0], lastId+1)
-			} else {
-				seqNumber := 0
-				if streamIdParts[0] == "0" {
-					seqNumber = 1
-				}
-				streamId = fmt.Sprintf("%s-%d", streamIdParts[0], seqNumber)
+}
+
+func (store *Store) Del(key string) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.remove(key)
+}
+
+func (store *Store) Keys() []string {
+	store.lockAll()
+	defer store.unlockAll()
+	keys := []string{}
+	now := time.Now().UnixMilli()
+	for i := range store.shards {
+		for k, data := range store.shards[i].data {
+			if !data.expired(now) {
+				keys = append(keys, k)
 			}
 		}
-		if lastIdx > 0 && streamData.value.Stream[lastIdx].Id >= streamId {
-			return "", fmt.Errorf("ERR The ID specified in XADD is equal or smaller than the target stream top item")
-		}
 	}
-	streamData.value.Stream = append(streamData.value.Stream, StreamType{
-		Id:   streamId,
-		Data: data,
-	})
-	store.data[streamKey] = streamData
-	return streamId, nil
+	return keys
 }
 
-func (store *Store) GetStream(key, start, end string) []StreamType {
-	store.mu.Lock()
-	defer store.mu.Unlock()
-	streamData := store.data[key]
-	if !strings.Contains(start, "-") {
-		start = start + "-0"
-	}
-	if !strings.Contains(end, "-") {
-		end = end + "-0"
-	}
-	startIdx := 0
-	endIdx := len(streamData.value.Stream) - 1
-	for idx, stream := range streamData.value.Stream {
-		if stream.Id == start {
-			startIdx = idx
-		}
-		if stream.Id == end {
-			endIdx = idx
+func (store *Store) retentionRoutine(onTrim func(key string, minID StreamID)) {
+	for {
+		time.Sleep(time.Second)
+		for key, minID := range store.expireStreams() {
+			onTrim(key, minID)
 		}
 	}
-	return streamData.value.Stream[startIdx:endIdx+1]
+}
+
+func (store *Store) GetType(key string) string {
+	store.lock(key)
+	defer store.unlock(key)
+	data, ok := store.lookup(key)
+	if !ok {
+		return "none"
+	}
+	return data.dataType
 }
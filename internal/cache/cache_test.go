@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+const benchmarkKeys = 100000
+
+// filledStore returns a store holding benchmarkKeys strings and their names.
+func filledStore() (*Store, []string) {
+	store := newStore()
+	keys := make([]string, benchmarkKeys)
+	for i := range keys {
+		keys[i] = "key:" + strconv.Itoa(i)
+		store.Set(keys[i], "value:"+strconv.Itoa(i), 0)
+	}
+	return store, keys
+}
+
+func BenchmarkGet(b *testing.B) {
+	store, keys := filledStore()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(rand.Int63()))
+		for pb.Next() {
+			store.Get(keys[r.Intn(len(keys))])
+		}
+	})
+}
+
+func BenchmarkSet(b *testing.B) {
+	store, keys := filledStore()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(rand.Int63()))
+		for pb.Next() {
+			store.Set(keys[r.Intn(len(keys))], "value", 0)
+		}
+	})
+}
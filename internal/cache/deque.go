@@ -0,0 +1,237 @@
+package cache
+
+const (
+	dequeChunkSize = 128
+	// listpackEntryOverhead is roughly what a listpack spends on each entry
+	// besides its bytes.
+	listpackEntryOverhead = 2
+)
+
+type dequeChunk struct {
+	items [dequeChunkSize]string
+	start int
+	end int
+}
+
+// Deque is a double-ended queue of strings kept in fixed-size chunks. The
+// chunks sit in a ring buffer and every chunk but the first and the last is
+// full, so pushes and pops at either end are O(1) and indexing is O(1) too.
+//
+// Deques within list-max-listpack-size are kept in a single slice instead,
+// like Redis's listpack, moving to chunks when they outgrow it and back when
+// they shrink to half of it.
+type Deque struct {
+	lp []string
+	lpSize int
+	chunks []*dequeChunk
+	head int
+	used int
+	length int
+}
+
+func NewDeque() *Deque {
+	return &Deque{lp: []string{}}
+}
+
+func (d *Deque) IsListpack() bool {
+	return d.lp != nil
+}
+
+// convert moves the elements out of the listpack into chunks.
+func (d *Deque) convert() {
+	values := d.lp
+	d.lp, d.lpSize, d.length = nil, 0, 0
+	d.chunks = make([]*dequeChunk, 1)
+	for _, value := range values {
+		d.PushBack(value)
+	}
+}
+
+// fit converts the deque to whichever encoding its size calls for.
+func (d *Deque) fit() {
+	if d.IsListpack() {
+		if !listpackFits(len(d.lp), d.lpSize) {
+			d.convert()
+		}
+		return
+	}
+	if d.used > 1 {
+		return
+	}
+	size := 0
+	for i := 0; i < d.length; i++ {
+		size += len(d.Index(i)) + listpackEntryOverhead
+	}
+	if listpackFits(d.length*2, size*2) {
+		d.lp = d.Values()
+		d.lpSize = size
+		d.chunks, d.head, d.used = nil, 0, 0
+	}
+}
+
+func newDequeFrom(values []string) *Deque {
+	d := NewDeque()
+	for _, value := range values {
+		d.PushBack(value)
+	}
+	return d
+}
+
+func (d *Deque) Len() int {
+	return d.length
+}
+
+func (d *Deque) chunk(i int) *dequeChunk {
+	return d.chunks[(d.head+i)%len(d.chunks)]
+}
+
+func (d *Deque) grow() {
+	if d.used < len(d.chunks) {
+		return
+	}
+	chunks := make([]*dequeChunk, len(d.chunks)*2)
+	for i := 0; i < d.used; i++ {
+		chunks[i] = d.chunk(i)
+	}
+	d.chunks = chunks
+	d.head = 0
+}
+
+func (d *Deque) PushBack(value string) {
+	if d.IsListpack() {
+		d.lp = append(d.lp, value)
+		d.lpSize += len(value) + listpackEntryOverhead
+		d.length++
+		d.fit()
+		return
+	}
+	if d.used == 0 || d.chunk(d.used-1).end == dequeChunkSize {
+		d.grow()
+		d.chunks[(d.head+d.used)%len(d.chunks)] = &dequeChunk{}
+		d.used++
+	}
+	last := d.chunk(d.used - 1)
+	last.items[last.end] = value
+	last.end++
+	d.length++
+}
+
+func (d *Deque) PushFront(value string) {
+	if d.IsListpack() {
+		d.lp = append(d.lp, "")
+		copy(d.lp[1:], d.lp)
+		d.lp[0] = value
+		d.lpSize += len(value) + listpackEntryOverhead
+		d.length++
+		d.fit()
+		return
+	}
+	if d.used == 0 || d.chunk(0).start == 0 {
+		d.grow()
+		d.head = (d.head - 1 + len(d.chunks)) % len(d.chunks)
+		d.chunks[d.head] = &dequeChunk{start: dequeChunkSize, end: dequeChunkSize}
+		d.used++
+	}
+	first := d.chunk(0)
+	first.start--
+	first.items[first.start] = value
+	d.length++
+}
+
+func (d *Deque) PopFront() (string, bool) {
+	if d.length == 0 {
+		return "", false
+	}
+	if d.IsListpack() {
+		value := d.lp[0]
+		d.lp = append(d.lp[:0], d.lp[1:]...)
+		d.lpSize -= len(value) + listpackEntryOverhead
+		d.length--
+		return value, true
+	}
+	first := d.chunk(0)
+	value := first.items[first.start]
+	first.items[first.start] = ""
+	first.start++
+	d.length--
+	if first.start == first.end {
+		d.chunks[d.head] = nil
+		d.head = (d.head + 1) % len(d.chunks)
+		d.used--
+	}
+	d.fit()
+	return value, true
+}
+
+func (d *Deque) PopBack() (string, bool) {
+	if d.length == 0 {
+		return "", false
+	}
+	if d.IsListpack() {
+		value := d.lp[len(d.lp)-1]
+		d.lp = d.lp[:len(d.lp)-1]
+		d.lpSize -= len(value) + listpackEntryOverhead
+		d.length--
+		return value, true
+	}
+	last := d.chunk(d.used - 1)
+	last.end--
+	value := last.items[last.end]
+	last.items[last.end] = ""
+	d.length--
+	if last.start == last.end {
+		d.chunks[(d.head+d.used-1)%len(d.chunks)] = nil
+		d.used--
+	}
+	d.fit()
+	return value, true
+}
+
+// locate returns the chunk and slot holding element i.
+func (d *Deque) locate(i int) (*dequeChunk, int) {
+	first := d.chunk(0)
+	if n := first.end - first.start; i < n {
+		return first, first.start + i
+	} else {
+		i -= n
+	}
+	return d.chunk(1 + i/dequeChunkSize), i % dequeChunkSize
+}
+
+func (d *Deque) Index(i int) string {
+	if d.IsListpack() {
+		return d.lp[i]
+	}
+	c, slot := d.locate(i)
+	return c.items[slot]
+}
+
+func (d *Deque) Set(i int, value string) {
+	if d.IsListpack() {
+		d.lpSize += len(value) - len(d.lp[i])
+		d.lp[i] = value
+		d.fit()
+		return
+	}
+	c, slot := d.locate(i)
+	c.items[slot] = value
+}
+
+// Range returns the elements from start to stop inclusive.
+func (d *Deque) Range(start, stop int) []string {
+	if d.IsListpack() {
+		return append([]string{}, d.lp[start:stop+1]...)
+	}
+	values := make([]string, 0, stop-start+1)
+	for i := start; i <= stop; i++ {
+		values = append(values, d.Index(i))
+	}
+	return values
+}
+
+func (d *Deque) Values() []string {
+	if d.length == 0 {
+		return []string{}
+	}
+	return d.Range(0, d.length-1)
+}
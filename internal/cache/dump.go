@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+var ErrBusyKey = fmt.Errorf("BUSYKEY Target key name already exists.")
+
+// Value is the value of a key apart from the store, as DUMP payloads and RDB
+// files carry it. Type tells which field holds it: Value for "string", Hash
+// for "hash", its fields and values alternating, and Stream for "stream".
+type Value struct {
+	Type string
+	Value string
+	Hash []string
+	Stream *StreamState
+}
+
+// RestoreOptions carries the modifiers of RESTORE. ExpireAt is in unix
+// milliseconds, 0 for no ttl. IdleTime, in seconds, and Freq are -1 when not
+// given.
+type RestoreOptions struct {
+	ExpireAt int64
+	Replace bool
+	IdleTime int64
+	Freq int
+}
+
+// Dump returns the value at key. Only strings, which HyperLogLogs are, and
+// hashes can be dumped.
+func (store *Store) Dump(key string) (Value, bool, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	data, ok := store.lookup(key)
+	if !ok {
+		return Value{}, false, nil
+	}
+	switch data.dataType {
+	case "string":
+		return Value{Type: "string", Value: data.value.str()}, true, nil
+	case "hash":
+		return Value{Type: "hash", Hash: data.value.Hash.Pairs()}, true, nil
+	}
+	return Value{}, false, fmt.Errorf("ERR DUMP of a %s is not supported", data.dataType)
+}
+
+// Restore stores v at key, failing with ErrBusyKey when key exists unless
+// opts.Replace is set.
+func (store *Store) Restore(key string, v Value, opts RestoreOptions) error {
+	store.lock(key)
+	defer store.unlock(key)
+	if _, ok := store.lookupKey(key, false); ok && !opts.Replace {
+		return ErrBusyKey
+	}
+	var data storeData
+	switch v.Type {
+	case "string":
+		data = storeData{value: newStringItem(v.Value), dataType: "string"}
+	case "hash":
+		if len(v.Hash) == 0 {
+			return fmt.Errorf("ERR Bad data format")
+		}
+		hash := NewHash()
+		for i := 0; i+1 < len(v.Hash); i += 2 {
+			hash.Set(v.Hash[i], v.Hash[i+1])
+		}
+		data = storeData{value: item{Hash: hash}, dataType: "hash"}
+	case "stream":
+		data = storeData{value: item{Stream: newStreamFrom(*v.Stream)}, dataType: "stream"}
+	default:
+		return fmt.Errorf("ERR Bad data format")
+	}
+	store.remove(key)
+	data.ttl = opts.ExpireAt
+	store.put(key, data)
+	if opts.IdleTime >= 0 || opts.Freq >= 0 {
+		s := store.shard(key)
+		restored := s.data[key]
+		if opts.IdleTime >= 0 {
+			restored.accessed = time.Now().UnixMilli() - opts.IdleTime*1000
+		}
+		if opts.Freq >= 0 {
+			restored.freq = uint8(opts.Freq)
+		}
+		s.data[key] = restored
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+const (
+	// sharedIntegers is how many small integers, from 0 up, every string
+	// holding them points to instead of keeping its own copy.
+	sharedIntegers = 10000
+	// embstrMaxLen is the longest string reported with the embstr encoding.
+	embstrMaxLen = 44
+)
+
+var sharedIntegerPool [sharedIntegers]int64
+
+func init() {
+	for i := range sharedIntegerPool {
+		sharedIntegerPool[i] = int64(i)
+	}
+}
+
+// encodingConfig holds the thresholds past which collections leave their
+// compact encodings, keyed by their Redis configuration names.
+var encodingConfig = map[string]*atomic.Int64{
+	"hash-max-listpack-entries": newThreshold(128),
+	"hash-max-listpack-value": newThreshold(64),
+	"set-max-intset-entries": newThreshold(512),
+	"set-max-listpack-entries": newThreshold(128),
+	"set-max-listpack-value": newThreshold(64),
+	"zset-max-listpack-entries": newThreshold(128),
+	"zset-max-listpack-value": newThreshold(64),
+	"list-max-listpack-size": newThreshold(-2),
+}
+
+func newThreshold(n int64) *atomic.Int64 {
+	threshold := &atomic.Int64{}
+	threshold.Store(n)
+	return threshold
+}
+
+func threshold(name string) int {
+	return int(encodingConfig[name].Load())
+}
+
+// EncodingConfig returns the value of an encoding threshold.
+func EncodingConfig(name string) (int64, bool) {
+	threshold, ok := encodingConfig[name]
+	if !ok {
+		return 0, false
+	}
+	return threshold.Load(), true
+}
+
+// SetEncodingConfig changes an encoding threshold. It applies to values as
+// they are next written; nothing is converted straight away.
+func SetEncodingConfig(name string, value int64) bool {
+	threshold, ok := encodingConfig[name]
+	if !ok || (value < 0 && name != "list-max-listpack-size") || (name == "list-max-listpack-size" && value < -5) {
+		return false
+	}
+	threshold.Store(value)
+	return true
+}
+
+// listpackFits tells whether a list of count entries taking size bytes fits
+// list-max-listpack-size: a number of entries when positive, or when
+// negative a size of 4KB for -1 up to 64KB for -5.
+func listpackFits(count, size int) bool {
+	limit := threshold("list-max-listpack-size")
+	if limit > 0 {
+		return count <= limit
+	}
+	if limit == 0 {
+		return count <= 1
+	}
+	return size <= 4096<<(-limit-1)
+}
+
+// newStringItem stores value, as an int64 when it reads as one. Integers
+// below sharedIntegers all point into one shared pool.
+func newStringItem(value string) item {
+	n, ok := ParseInt(value)
+	if !ok {
+		return item{String: value}
+	}
+	if n >= 0 && n < sharedIntegers {
+		return item{Int: &sharedIntegerPool[n]}
+	}
+	return item{Int: &n}
+}
+
+// str returns the string an item holds, whatever its encoding.
+func (it item) str() string {
+	if it.Bytes != nil {
+		return string(it.Bytes)
+	}
+	if it.Int != nil {
+		return strconv.FormatInt(*it.Int, 10)
+	}
+	return it.String
+}
+
+// encoding names how data is stored the way OBJECT ENCODING reports it.
+func (data storeData) encoding() string {
+	switch data.dataType {
+	case "string":
+		if data.value.Int != nil {
+			return "int"
+		}
+		if data.value.Bytes == nil && len(data.value.String) <= embstrMaxLen {
+			return "embstr"
+		}
+		return "raw"
+	case "list":
+		if data.value.List.IsListpack() {
+			return "listpack"
+		}
+		return "quicklist"
+	case "hash":
+		if data.value.Hash.IsListpack() {
+			return "listpack"
+		}
+		return "hashtable"
+	case "set":
+		if data.value.Set.IsIntset() {
+			return "intset"
+		}
+		if data.value.Set.IsListpack() {
+			return "listpack"
+		}
+		return "hashtable"
+	case "zset":
+		if data.value.ZSet.IsListpack() {
+			return "listpack"
+		}
+		return "skiplist"
+	}
+	return data.dataType
+}
@@ -0,0 +1,71 @@
+package cache
+
+import "time"
+
+const (
+	// activeExpireInterval is how often the active expire cycle runs, ten
+	// times a second as with Redis's default hz.
+	activeExpireInterval = 100 * time.Millisecond
+	// activeExpireBudget is the time a cycle may spend, a quarter of the
+	// interval, so that a burst of expired keys cannot stall clients.
+	activeExpireBudget = 25 * time.Millisecond
+	// activeExpireKeysPerLoop is how many keys with a ttl a loop samples.
+	activeExpireKeysPerLoop = 20
+	// activeExpireAcceptableStale is the percentage of expired keys in a
+	// sample below which the cycle stops: the rest can wait.
+	activeExpireAcceptableStale = 10
+)
+
+// expired tells whether data has a ttl that passed by now.
+func (data storeData) expired(now int64) bool {
+	return data.ttl > 0 && data.ttl < now
+}
+
+// activeExpireCycle removes expired keys that are not being read, which lazy
+// expiry alone would keep forever. Going over the shards from where the last
+// cycle stopped, it samples keys with a ttl and removes those that expired,
+// sampling the same shard again while more than activeExpireAcceptableStale
+// percent of a sample had expired. Only one shard is locked at a time. It
+// returns how many keys it removed.
+func (store *Store) activeExpireCycle() int {
+	start := time.Now()
+	removed := 0
+	for n := 0; n < shardCount && time.Since(start) < activeExpireBudget; n++ {
+		s := &store.shards[store.expireCursor]
+		store.expireCursor = (store.expireCursor + 1) % shardCount
+		for time.Since(start) < activeExpireBudget {
+			s.mu.Lock()
+			now := time.Now().UnixMilli()
+			sampled, expired := 0, 0
+			for key := range s.expires {
+				if sampled == activeExpireKeysPerLoop {
+					break
+				}
+				sampled++
+				if s.data[key].expired(now) {
+					store.remove(key)
+					expired++
+				}
+			}
+			store.unlockShard(s)
+			store.expired.Add(int64(expired))
+			removed += expired
+			if sampled == 0 || expired*100/sampled <= activeExpireAcceptableStale {
+				break
+			}
+		}
+	}
+	return removed
+}
+
+func (store *Store) activeExpireRoutine() {
+	for {
+		time.Sleep(activeExpireInterval)
+		store.activeExpireCycle()
+	}
+}
+
+// ExpiredKeys returns how many keys were removed because their ttl passed.
+func (store *Store) ExpiredKeys() int64 {
+	return store.expired.Load()
+}
@@ -0,0 +1,365 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Positions are kept in a sorted set scored by a 52-bit geohash, as Redis
+// does, so ZRANGE and friends work on geo keys and nearby points are close
+// in the index.
+const (
+	geoStep = 26
+	GeoLonMin = -180.0
+	GeoLonMax = 180.0
+	GeoLatMin = -85.05112878
+	GeoLatMax = 85.05112878
+	earthRadius = 6372797.560856
+	mercatorMax = 20037726.37
+	geoAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+var ErrGeoMember = fmt.Errorf("ERR could not decode requested zset member")
+
+// ValidLonLat reports whether a position can be indexed.
+func ValidLonLat(lon, lat float64) bool {
+	return lon >= GeoLonMin && lon <= GeoLonMax && lat >= GeoLatMin && lat <= GeoLatMax
+}
+
+func interleave(x, y uint32) uint64 {
+	spread := func(v uint64) uint64 {
+		v = (v | v<<16) & 0x0000FFFF0000FFFF
+		v = (v | v<<8) & 0x00FF00FF00FF00FF
+		v = (v | v<<4) & 0x0F0F0F0F0F0F0F0F
+		v = (v | v<<2) & 0x3333333333333333
+		v = (v | v<<1) & 0x5555555555555555
+		return v
+	}
+	return spread(uint64(x)) | spread(uint64(y))<<1
+}
+
+func deinterleave(bits uint64) (uint32, uint32) {
+	squash := func(v uint64) uint32 {
+		v &= 0x5555555555555555
+		v = (v | v>>1) & 0x3333333333333333
+		v = (v | v>>2) & 0x0F0F0F0F0F0F0F0F
+		v = (v | v>>4) & 0x00FF00FF00FF00FF
+		v = (v | v>>8) & 0x0000FFFF0000FFFF
+		v = (v | v>>16) & 0x00000000FFFFFFFF
+		return uint32(v)
+	}
+	return squash(bits), squash(bits >> 1)
+}
+
+// geoCell is a geohash cell: the latitude and longitude indexes of a square
+// of the grid that splits each axis into 1<<step parts.
+type geoCell struct {
+	lat uint32
+	lon uint32
+	step uint
+}
+
+func encodeCell(lon, lat, latMin, latMax float64, step uint) geoCell {
+	scale := float64(uint64(1) << step)
+	return geoCell{
+		lat: uint32((lat - latMin) / (latMax - latMin) * scale),
+		lon: uint32((lon - GeoLonMin) / (GeoLonMax - GeoLonMin) * scale),
+		step: step,
+	}
+}
+
+func (cell geoCell) bits() uint64 {
+	return interleave(cell.lat, cell.lon)
+}
+
+// area returns the bounds of the cell as lon min, lon max, lat min, lat max.
+func (cell geoCell) area() (float64, float64, float64, float64) {
+	scale := float64(uint64(1) << cell.step)
+	latSpan, lonSpan := GeoLatMax-GeoLatMin, GeoLonMax-GeoLonMin
+	return GeoLonMin + float64(cell.lon)/scale*lonSpan,
+		GeoLonMin + float64(cell.lon+1)/scale*lonSpan,
+		GeoLatMin + float64(cell.lat)/scale*latSpan,
+		GeoLatMin + float64(cell.lat+1)/scale*latSpan
+}
+
+// move returns the cell dlon and dlat steps away, wrapping around the grid.
+func (cell geoCell) move(dlon, dlat int) geoCell {
+	mask := uint32(uint64(1)<<cell.step - 1)
+	cell.lon = uint32(int64(cell.lon)+int64(dlon)) & mask
+	cell.lat = uint32(int64(cell.lat)+int64(dlat)) & mask
+	return cell
+}
+
+// GeoEncode returns the sorted set score of a position.
+func GeoEncode(lon, lat float64) float64 {
+	return float64(encodeCell(lon, lat, GeoLatMin, GeoLatMax, geoStep).bits())
+}
+
+// GeoDecode returns the position at the centre of the cell a score encodes.
+func GeoDecode(score float64) (float64, float64) {
+	lat, lon := deinterleave(uint64(score))
+	lonMin, lonMax, latMin, latMax := geoCell{lat: lat, lon: lon, step: geoStep}.area()
+	return math.Max(GeoLonMin, math.Min(GeoLonMax, (lonMin+lonMax)/2)),
+		math.Max(GeoLatMin, math.Min(GeoLatMax, (latMin+latMax)/2))
+}
+
+// GeoHashString returns the standard 11 character geohash of a score.
+func GeoHashString(score float64) string {
+	lon, lat := GeoDecode(score)
+	bits := encodeCell(lon, lat, -90, 90, geoStep).bits()
+	hash := make([]byte, 11)
+	for i := range hash {
+		idx := uint64(0)
+		if i < 10 {
+			idx = (bits >> (52 - (i+1)*5)) & 0x1f
+		}
+		hash[i] = geoAlphabet[idx]
+	}
+	return string(hash)
+}
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radToDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+func geoLatDistance(lat1, lat2 float64) float64 {
+	return earthRadius * math.Abs(degToRad(lat2)-degToRad(lat1))
+}
+
+// GeoDistance returns the haversine distance in meters between two positions.
+func GeoDistance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r, lat2r := degToRad(lat1), degToRad(lat2)
+	v := math.Sin((degToRad(lon2) - degToRad(lon1)) / 2)
+	if v == 0 {
+		return geoLatDistance(lat1, lat2)
+	}
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// GeoQuery describes a GEOSEARCH: its centre, either a member or a position,
+// its shape, a radius or a box whose sizes are in meters, and how results are
+// ordered (1 ascending, -1 descending by distance) and limited.
+type GeoQuery struct {
+	FromMember bool
+	Member string
+	Lon float64
+	Lat float64
+	ByBox bool
+	Radius float64
+	Width float64
+	Height float64
+	Sort int
+	Count int
+	Any bool
+}
+
+type GeoResult struct {
+	Member string
+	Score float64
+	Dist float64
+	Lon float64
+	Lat float64
+}
+
+// within reports whether a position lies in the query shape and returns its
+// distance to the centre.
+func (q GeoQuery) within(lon, lat float64) (float64, bool) {
+	if q.ByBox {
+		if geoLatDistance(lat, q.Lat) > q.Height/2 {
+			return 0, false
+		}
+		if GeoDistance(lon, lat, q.Lon, lat) > q.Width/2 {
+			return 0, false
+		}
+		return GeoDistance(q.Lon, q.Lat, lon, lat), true
+	}
+	dist := GeoDistance(q.Lon, q.Lat, lon, lat)
+	return dist, dist <= q.Radius
+}
+
+// searchCells returns the cells to scan for q: the one holding the centre and
+// those of its neighbours the shape reaches into.
+func (q GeoQuery) searchCells() []geoCell {
+	halfWidth, halfHeight, radius := q.Radius, q.Radius, q.Radius
+	if q.ByBox {
+		halfWidth, halfHeight = q.Width/2, q.Height/2
+		radius = math.Sqrt(halfWidth*halfWidth + halfHeight*halfHeight)
+	}
+	latDelta := radToDeg(halfHeight / earthRadius)
+	lonDeltaTop := radToDeg(halfWidth / earthRadius / math.Cos(degToRad(q.Lat+latDelta)))
+	lonDeltaBottom := radToDeg(halfWidth / earthRadius / math.Cos(degToRad(q.Lat-latDelta)))
+	lonDelta := lonDeltaTop
+	if q.Lat < 0 {
+		lonDelta = lonDeltaBottom
+	}
+	minLon, maxLon := q.Lon-lonDelta, q.Lon+lonDelta
+	minLat, maxLat := q.Lat-latDelta, q.Lat+latDelta
+
+	step := uint(geoEstimateStep(radius, q.Lat))
+	centre := encodeCell(q.Lon, q.Lat, GeoLatMin, GeoLatMax, step)
+	if step > 1 {
+		_, _, _, north := centre.move(0, 1).area()
+		_, _, south, _ := centre.move(0, -1).area()
+		_, east, _, _ := centre.move(1, 0).area()
+		west, _, _, _ := centre.move(-1, 0).area()
+		if GeoDistance(q.Lon, q.Lat, q.Lon, north) < radius || GeoDistance(q.Lon, q.Lat, q.Lon, south) < radius ||
+			GeoDistance(q.Lon, q.Lat, east, q.Lat) < radius || GeoDistance(q.Lon, q.Lat, west, q.Lat) < radius {
+			step--
+			centre = encodeCell(q.Lon, q.Lat, GeoLatMin, GeoLatMax, step)
+		}
+	}
+	lonLo, lonHi, latLo, latHi := centre.area()
+	cells := []geoCell{centre}
+	seen := map[uint64]bool{centre.bits(): true}
+	// Neighbours in the order Redis scans them.
+	for _, d := range [][2]int{{0, 1}, {0, -1}, {1, 0}, {-1, 0}, {1, 1}, {-1, 1}, {1, -1}, {-1, -1}} {
+		// Skip the sides the centre cell already reaches past.
+		if step >= 2 && ((d[1] < 0 && latLo < minLat) || (d[1] > 0 && latHi > maxLat) ||
+			(d[0] < 0 && lonLo < minLon) || (d[0] > 0 && lonHi > maxLon)) {
+			continue
+		}
+		cell := centre.move(d[0], d[1])
+		if !seen[cell.bits()] {
+			seen[cell.bits()] = true
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
+
+// geoEstimateStep picks the coarsest cell size whose neighbourhood still
+// covers a search of radius meters around latitude lat.
+func geoEstimateStep(radius, lat float64) int {
+	if radius == 0 {
+		return geoStep
+	}
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	step -= 2
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+	return max(1, min(geoStep, step))
+}
+
+// geoSearch runs q against zset, scanning only the cells around the centre.
+func (z *ZSet) geoSearch(q GeoQuery) []GeoResult {
+	results := []GeoResult{}
+	limit := q.Any && q.Count > 0
+	for _, cell := range q.searchCells() {
+		shift := 2 * (geoStep - cell.step)
+		r := ScoreRange{Min: float64(cell.bits() << shift), Max: float64((cell.bits() + 1) << shift), MaxEx: true}
+		for _, m := range z.Range(ZRangeQuery{By: "score", Score: r, Count: -1}) {
+			lon, lat := GeoDecode(m.Score)
+			dist, ok := q.within(lon, lat)
+			if !ok {
+				continue
+			}
+			results = append(results, GeoResult{Member: m.Member, Score: m.Score, Dist: dist, Lon: lon, Lat: lat})
+			if limit && len(results) == q.Count {
+				break
+			}
+		}
+		if limit && len(results) == q.Count {
+			break
+		}
+	}
+	sortOrder := q.Sort
+	if sortOrder == 0 && q.Count > 0 && !q.Any {
+		sortOrder = 1
+	}
+	if sortOrder != 0 {
+		sort.SliceStable(results, func(i, j int) bool {
+			if sortOrder > 0 {
+				return results[i].Dist < results[j].Dist
+			}
+			return results[i].Dist > results[j].Dist
+		})
+	}
+	if q.Count > 0 && len(results) > q.Count {
+		results = results[:q.Count]
+	}
+	return results
+}
+
+// GeoPos returns the position of each member, nil for missing ones.
+func (store *Store) GeoPos(key string, members []string) ([]*[2]float64, error) {
+	scores, err := store.ZScore(key, members)
+	if err != nil {
+		return nil, err
+	}
+	positions := make([]*[2]float64, len(scores))
+	for i, score := range scores {
+		if score != nil {
+			lon, lat := GeoDecode(*score)
+			positions[i] = &[2]float64{lon, lat}
+		}
+	}
+	return positions, nil
+}
+
+// resolveGeoQuery fills in the centre of a FROMMEMBER query.
+func (z *ZSet) resolveGeoQuery(q GeoQuery) (GeoQuery, error) {
+	if !q.FromMember {
+		return q, nil
+	}
+	score, ok := z.Score(q.Member)
+	if !ok {
+		return q, ErrGeoMember
+	}
+	q.Lon, q.Lat = GeoDecode(score)
+	return q, nil
+}
+
+func (store *Store) GeoSearch(key string, q GeoQuery) ([]GeoResult, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	zset, err := store.getZSet(key)
+	if err != nil || zset == nil {
+		return []GeoResult{}, err
+	}
+	if q, err = zset.resolveGeoQuery(q); err != nil {
+		return nil, err
+	}
+	return zset.geoSearch(q), nil
+}
+
+// GeoSearchStore stores the results of q on src into dst, scored by their
+// geohash or, when distScale is not zero, by their distance divided by it.
+func (store *Store) GeoSearchStore(dst, src string, q GeoQuery, distScale float64) (int, error) {
+	store.lock(dst, src)
+	defer store.unlock(dst, src)
+	zset, err := store.getZSet(src)
+	if err != nil {
+		return 0, err
+	}
+	result := NewZSet()
+	if zset != nil {
+		if q, err = zset.resolveGeoQuery(q); err != nil {
+			return 0, err
+		}
+		for _, r := range zset.geoSearch(q) {
+			if distScale != 0 {
+				result.Set(r.Member, r.Dist/distScale)
+			} else {
+				result.Set(r.Member, r.Score)
+			}
+		}
+	}
+	store.remove(dst)
+	store.putZSet(dst, result)
+	return result.Len(), nil
+}
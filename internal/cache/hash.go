@@ -0,0 +1,325 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+)
+
+var (
+	ErrHashNotInteger = fmt.Errorf("ERR hash value is not an integer")
+	ErrHashNotFloat = fmt.Errorf("ERR hash value is not a float")
+)
+
+// Hash maps fields to values, keeping them alternating in pairs. Small
+// hashes search pairs linearly, like Redis's listpack; they are indexed by
+// a map from each field to its position in pairs once they grow past
+// hash-max-listpack-entries fields or hold a field or value longer than
+// hash-max-listpack-value. Either way a random field is picked by index.
+type Hash struct {
+	pairs []string
+	fields map[string]int
+}
+
+func NewHash() *Hash {
+	return &Hash{pairs: []string{}}
+}
+
+func (h *Hash) IsListpack() bool {
+	return h.fields == nil
+}
+
+func (h *Hash) convert() {
+	h.fields = make(map[string]int, len(h.pairs)/2)
+	for i := 0; i < len(h.pairs); i += 2 {
+		h.fields[h.pairs[i]] = i
+	}
+}
+
+// find returns the index of field in pairs, or -1.
+func (h *Hash) find(field string) int {
+	for i := 0; i < len(h.pairs); i += 2 {
+		if h.pairs[i] == field {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h *Hash) Len() int {
+	return len(h.pairs) / 2
+}
+
+func (h *Hash) Get(field string) (string, bool) {
+	if h.IsListpack() {
+		if i := h.find(field); i != -1 {
+			return h.pairs[i+1], true
+		}
+		return "", false
+	}
+	i, ok := h.fields[field]
+	if !ok {
+		return "", false
+	}
+	return h.pairs[i+1], true
+}
+
+// pair returns the field and value at index i, in no particular order but
+// stable while the hash is unchanged.
+func (h *Hash) pair(i int) (string, string) {
+	return h.pairs[i*2], h.pairs[i*2+1]
+}
+
+// Set stores value at field and reports whether the field is new.
+func (h *Hash) Set(field, value string) bool {
+	if h.IsListpack() {
+		limit := threshold("hash-max-listpack-value")
+		if len(field) > limit || len(value) > limit {
+			h.convert()
+		} else if i := h.find(field); i != -1 {
+			h.pairs[i+1] = value
+			return false
+		} else if h.Len() < threshold("hash-max-listpack-entries") {
+			h.pairs = append(h.pairs, field, value)
+			return true
+		} else {
+			h.convert()
+		}
+	}
+	if i, ok := h.fields[field]; ok {
+		h.pairs[i+1] = value
+		return false
+	}
+	h.fields[field] = len(h.pairs)
+	h.pairs = append(h.pairs, field, value)
+	return true
+}
+
+func (h *Hash) Delete(field string) bool {
+	if h.IsListpack() {
+		i := h.find(field)
+		if i == -1 {
+			return false
+		}
+		h.pairs = append(h.pairs[:i], h.pairs[i+2:]...)
+		return true
+	}
+	i, ok := h.fields[field]
+	if !ok {
+		return false
+	}
+	// The last pair takes the place of the removed one.
+	last := len(h.pairs) - 2
+	h.pairs[i], h.pairs[i+1] = h.pairs[last], h.pairs[last+1]
+	h.fields[h.pairs[i]] = i
+	h.pairs = h.pairs[:last]
+	delete(h.fields, field)
+	return true
+}
+
+// Pairs returns the fields and values as a flat list of field/value pairs.
+func (h *Hash) Pairs() []string {
+	return append([]string{}, h.pairs...)
+}
+
+// getHash returns the hash stored at key, nil when the key does not exist.
+// The caller must hold the key locks.
+func (store *Store) getHash(key string) (*Hash, error) {
+	data, ok := store.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	if data.dataType != "hash" {
+		return nil, ErrWrongType
+	}
+	return data.value.Hash, nil
+}
+
+// getOrCreateHash is getHash for writers, creating an empty hash when the key
+// does not exist. The caller must hold the key locks.
+func (store *Store) getOrCreateHash(key string) (*Hash, error) {
+	hash, err := store.getHash(key)
+	if err != nil || hash != nil {
+		return hash, err
+	}
+	hash = NewHash()
+	store.put(key, storeData{
+		value: item{Hash: hash},
+		dataType: "hash",
+	})
+	return hash, nil
+}
+
+// hashGet is Hash.Get for a hash that may not exist.
+func hashGet(hash *Hash, field string) (string, bool) {
+	if hash == nil {
+		return "", false
+	}
+	return hash.Get(field)
+}
+
+// HSet sets the field/value pairs in the hash at key, and with nx only the
+// fields that do not exist yet. It returns the number of fields added.
+func (store *Store) HSet(key string, pairs []string, nx bool) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	hash, err := store.getOrCreateHash(key)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if nx {
+			if _, exists := hash.Get(pairs[i]); exists {
+				continue
+			}
+		}
+		if hash.Set(pairs[i], pairs[i+1]) {
+			added++
+		}
+	}
+	if hash.Len() == 0 {
+		store.remove(key)
+	}
+	return added, nil
+}
+
+// HGet returns the value of each field, nil for the missing ones.
+func (store *Store) HGet(key string, fields []string) ([]*string, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	hash, err := store.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]*string, len(fields))
+	if hash == nil {
+		return values, nil
+	}
+	for i, field := range fields {
+		if value, ok := hash.Get(field); ok {
+			values[i] = &value
+		}
+	}
+	return values, nil
+}
+
+func (store *Store) HDel(key string, fields []string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	hash, err := store.getHash(key)
+	if err != nil || hash == nil {
+		return 0, err
+	}
+	removed := 0
+	for _, field := range fields {
+		if hash.Delete(field) {
+			removed++
+		}
+	}
+	if hash.Len() == 0 {
+		store.remove(key)
+	}
+	return removed, nil
+}
+
+// HGetAll returns the fields and values of the hash at key as a flat list of
+// field/value pairs.
+func (store *Store) HGetAll(key string) ([]string, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	hash, err := store.getHash(key)
+	if err != nil || hash == nil {
+		return []string{}, err
+	}
+	return hash.Pairs(), nil
+}
+
+func (store *Store) HLen(key string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	hash, err := store.getHash(key)
+	if err != nil || hash == nil {
+		return 0, err
+	}
+	return hash.Len(), nil
+}
+
+func (store *Store) HIncrBy(key, field string, delta int64) (int64, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	hash, err := store.getHash(key)
+	if err != nil {
+		return 0, err
+	}
+	current := int64(0)
+	if value, ok := hashGet(hash, field); ok {
+		if current, ok = ParseInt(value); !ok {
+			return 0, ErrHashNotInteger
+		}
+	}
+	if (delta < 0 && current < math.MinInt64-delta) || (delta > 0 && current > math.MaxInt64-delta) {
+		return 0, ErrOverflow
+	}
+	current += delta
+	hash, _ = store.getOrCreateHash(key)
+	hash.Set(field, strconv.FormatInt(current, 10))
+	return current, nil
+}
+
+func (store *Store) HIncrByFloat(key, field string, delta float64) (string, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	hash, err := store.getHash(key)
+	if err != nil {
+		return "", err
+	}
+	current := float64(0)
+	if value, ok := hashGet(hash, field); ok {
+		if current, ok = ParseFloat(value); !ok {
+			return "", ErrHashNotFloat
+		}
+	}
+	current += delta
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return "", fmt.Errorf("ERR increment would produce NaN or Infinity")
+	}
+	value := FormatFloat(current)
+	hash, _ = store.getOrCreateHash(key)
+	hash.Set(field, value)
+	return value, nil
+}
+
+// HRandField picks count fields of the hash at key as field/value pairs:
+// distinct fields when count is positive, possibly repeated ones when it is
+// negative.
+func (store *Store) HRandField(key string, count int64) ([]string, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	hash, err := store.getHash(key)
+	if err != nil || hash == nil {
+		return []string{}, err
+	}
+	n := hash.Len()
+	pairs := []string{}
+	if count < 0 {
+		for i := int64(0); i < -count; i++ {
+			field, value := hash.pair(rand.Intn(n))
+			pairs = append(pairs, field, value)
+		}
+		return pairs, nil
+	}
+	if count >= int64(n) {
+		return hash.Pairs(), nil
+	}
+	for _, i := range randomIndexes(n, int(count)) {
+		field, value := hash.pair(i)
+		pairs = append(pairs, field, value)
+	}
+	return pairs, nil
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestHashEncodings(t *testing.T) {
+	for _, n := range []int{10, 1000} {
+		h := NewHash()
+		for i := 0; i < n; i++ {
+			h.Set("f"+strconv.Itoa(i), "v"+strconv.Itoa(i))
+		}
+		if h.IsListpack() != (n == 10) {
+			t.Fatalf("%d fields: listpack %v", n, h.IsListpack())
+		}
+		h.Set("f3", "changed")
+		for i := 0; i < n; i += 2 {
+			if !h.Delete("f" + strconv.Itoa(i)) {
+				t.Fatalf("%d fields: f%d was not deleted", n, i)
+			}
+		}
+		if h.Len() != n/2 {
+			t.Fatalf("%d fields: %d left after deleting half", n, h.Len())
+		}
+		for i := 0; i < n; i++ {
+			value, ok := h.Get("f" + strconv.Itoa(i))
+			want := "v" + strconv.Itoa(i)
+			if i == 3 {
+				want = "changed"
+			}
+			if ok != (i%2 == 1) || ok && value != want {
+				t.Fatalf("%d fields: f%d = %q, %v", n, i, value, ok)
+			}
+		}
+	}
+}
+
+func TestHRandField(t *testing.T) {
+	for _, n := range []int{10, 1000} {
+		store := newStore()
+		pairs := []string{}
+		for i := 0; i < n; i++ {
+			pairs = append(pairs, "f"+strconv.Itoa(i), "v"+strconv.Itoa(i))
+		}
+		store.HSet("h", pairs, false)
+		picked, err := store.HRandField("h", 7)
+		if err != nil || len(picked) != 14 {
+			t.Fatalf("%d fields: HRANDFIELD 7 = %v, %v", n, picked, err)
+		}
+		seen := map[string]bool{}
+		for i := 0; i < len(picked); i += 2 {
+			if seen[picked[i]] || "v"+picked[i][1:] != picked[i+1] {
+				t.Fatalf("%d fields: HRANDFIELD 7 returned %v", n, picked)
+			}
+			seen[picked[i]] = true
+		}
+		if picked, _ := store.HRandField("h", int64(n)+1); len(picked) != 2*n {
+			t.Fatalf("%d fields: HRANDFIELD past the size returned %d pairs", n, len(picked)/2)
+		}
+		if picked, _ := store.HRandField("h", -20); len(picked) != 40 {
+			t.Fatalf("%d fields: HRANDFIELD -20 returned %d pairs", n, len(picked)/2)
+		}
+	}
+}
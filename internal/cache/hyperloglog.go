@@ -0,0 +1,390 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// HyperLogLogs are stored as strings in the same layout Redis uses, so the
+// values are interchangeable with it: a 16 byte header ("HYLL", the encoding,
+// three unused bytes and a little endian cached cardinality whose top bit
+// marks it stale) followed by either the 6 bit dense registers or the sparse
+// run-length opcodes.
+const (
+	hllP = 14
+	hllQ = 64 - hllP
+	hllRegisters = 1 << hllP
+	hllBits = 6
+	hllHeaderSize = 16
+	hllDenseSize = hllHeaderSize + (hllRegisters*hllBits+7)/8
+	hllDense = 0
+	hllSparse = 1
+	hllSparseMaxBytes = 3000
+	hllSparseValMax = 32
+	hllAlphaInf = 0.721347520444481703680
+)
+
+var (
+	ErrNotHLL = fmt.Errorf("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	ErrCorruptHLL = fmt.Errorf("INVALIDOBJ Corrupted HLL object detected")
+)
+
+// murmurHash64A is the hash Redis uses to place elements in registers.
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+	h := seed ^ (uint64(len(key)) * m)
+	n := len(key) - len(key)&7
+	for i := 0; i < n; i += 8 {
+		k := binary.LittleEndian.Uint64(key[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+	tail := key[n:]
+	for i := len(tail) - 1; i >= 0; i-- {
+		h ^= uint64(tail[i]) << (8 * uint(i))
+	}
+	if len(tail) > 0 {
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// hllPatLen returns the register an element maps to and the length of the
+// run of zeros, plus one, in the rest of its hash.
+func hllPatLen(element string) (int, uint8) {
+	hash := murmurHash64A([]byte(element), 0xadc83b19)
+	index := int(hash & (hllRegisters - 1))
+	hash >>= hllP
+	hash |= 1 << hllQ
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+type hll struct {
+	registers []uint8
+	sparse bool
+	card uint64
+	cardValid bool
+}
+
+func newHLL() *hll {
+	return &hll{registers: make([]uint8, hllRegisters), sparse: true, cardValid: true}
+}
+
+// decodeHLL parses a HyperLogLog string in either encoding.
+func decodeHLL(value string) (*hll, error) {
+	if len(value) < hllHeaderSize || value[:4] != "HYLL" || value[4] > hllSparse {
+		return nil, ErrNotHLL
+	}
+	h := &hll{registers: make([]uint8, hllRegisters), sparse: value[4] == hllSparse}
+	card := []byte(value[8:16])
+	h.cardValid = card[7]&(1<<7) == 0
+	h.card = binary.LittleEndian.Uint64(card)
+	body := value[hllHeaderSize:]
+	if !h.sparse {
+		if len(value) != hllDenseSize {
+			return nil, ErrNotHLL
+		}
+		for i := range h.registers {
+			h.registers[i] = denseRegister(body, i)
+		}
+		return h, nil
+	}
+	idx := 0
+	for p := 0; p < len(body); {
+		b := body[p]
+		switch {
+		case b&0xc0 == 0:
+			idx += int(b&0x3f) + 1
+			p++
+		case b&0xc0 == 0x40:
+			if p+1 >= len(body) {
+				return nil, ErrCorruptHLL
+			}
+			idx += (int(b&0x3f)<<8 | int(body[p+1])) + 1
+			p += 2
+		default:
+			val := (b>>2)&0x1f + 1
+			run := int(b&3) + 1
+			if idx+run > hllRegisters {
+				return nil, ErrCorruptHLL
+			}
+			for i := 0; i < run; i++ {
+				h.registers[idx+i] = val
+			}
+			idx += run
+			p++
+		}
+	}
+	if idx != hllRegisters {
+		return nil, ErrCorruptHLL
+	}
+	return h, nil
+}
+
+func denseRegister(body string, i int) uint8 {
+	byteIdx := i * hllBits / 8
+	fb := uint(i * hllBits & 7)
+	b0 := uint(body[byteIdx])
+	b1 := uint(0)
+	if byteIdx+1 < len(body) {
+		b1 = uint(body[byteIdx+1])
+	}
+	return uint8((b0>>fb | b1<<(8-fb)) & 63)
+}
+
+// encodeSparse writes the registers as sparse opcodes, reporting false when
+// a register is too large for the encoding or the result is too long.
+func (h *hll) encodeSparse() ([]byte, bool) {
+	out := []byte{}
+	for i := 0; i < hllRegisters; {
+		val := h.registers[i]
+		run := 1
+		for i+run < hllRegisters && h.registers[i+run] == val {
+			run++
+		}
+		i += run
+		if val > hllSparseValMax {
+			return nil, false
+		}
+		for run > 0 {
+			switch {
+			case val == 0 && run > 64:
+				n := run
+				if n > 16384 {
+					n = 16384
+				}
+				out = append(out, 0x40|byte((n-1)>>8), byte(n-1))
+				run -= n
+			case val == 0:
+				out = append(out, byte(run-1))
+				run = 0
+			default:
+				n := run
+				if n > 4 {
+					n = 4
+				}
+				out = append(out, 0x80|(val-1)<<2|byte(n-1))
+				run -= n
+			}
+		}
+		if hllHeaderSize+len(out) > hllSparseMaxBytes {
+			return nil, false
+		}
+	}
+	return out, true
+}
+
+func (h *hll) encodeDense() []byte {
+	out := make([]byte, hllDenseSize-hllHeaderSize)
+	for i, val := range h.registers {
+		byteIdx := i * hllBits / 8
+		fb := uint(i * hllBits & 7)
+		out[byteIdx] &^= byte(63 << fb)
+		out[byteIdx] |= val << fb
+		if byteIdx+1 < len(out) {
+			out[byteIdx+1] &^= byte(63 >> (8 - fb))
+			out[byteIdx+1] |= val >> (8 - fb)
+		}
+	}
+	return out
+}
+
+// encode serialises the HyperLogLog, promoting it to the dense encoding once
+// the sparse one can no longer represent it compactly.
+func (h *hll) encode() string {
+	body, ok := []byte(nil), false
+	if h.sparse {
+		body, ok = h.encodeSparse()
+		h.sparse = ok
+	}
+	if !h.sparse {
+		body = h.encodeDense()
+	}
+	header := make([]byte, hllHeaderSize)
+	copy(header, "HYLL")
+	if h.sparse {
+		header[4] = hllSparse
+	}
+	binary.LittleEndian.PutUint64(header[8:], h.card)
+	if !h.cardValid {
+		header[15] |= 1 << 7
+	}
+	return string(append(header, body...))
+}
+
+func (h *hll) add(element string) bool {
+	index, count := hllPatLen(element)
+	if count <= h.registers[index] {
+		return false
+	}
+	h.registers[index] = count
+	h.cardValid = false
+	return true
+}
+
+func (h *hll) merge(other *hll) {
+	for i, val := range other.registers {
+		if val > h.registers[i] {
+			h.registers[i] = val
+		}
+	}
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
+
+// count estimates the cardinality with the improved estimator from Ertl's
+// "New cardinality estimation algorithms for HyperLogLog sketches", as Redis
+// does.
+func (h *hll) count() uint64 {
+	m := float64(hllRegisters)
+	histogram := make([]int, 64)
+	for _, val := range h.registers {
+		histogram[val]++
+	}
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+	return uint64(math.Round(hllAlphaInf * m * m / z))
+}
+
+// getHLL returns the HyperLogLog at key, or nil when the key does not exist.
+// The caller must hold the key locks.
+func (store *Store) getHLL(key string) (*hll, storeData, error) {
+	data, ok, err := store.getString(key)
+	if err != nil || !ok {
+		return nil, data, err
+	}
+	h, err := decodeHLL(data.value.str())
+	return h, data, err
+}
+
+// PFAdd adds elements to the HyperLogLog at key, reporting whether any
+// register changed or the key was created.
+func (store *Store) PFAdd(key string, elements []string) (bool, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	h, data, err := store.getHLL(key)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	if h == nil {
+		h = newHLL()
+		changed = true
+	}
+	for _, element := range elements {
+		if h.add(element) {
+			changed = true
+		}
+	}
+	if changed {
+		store.putString(key, h.encode(), data.ttl)
+	}
+	return changed, nil
+}
+
+// PFCount estimates the cardinality of the union of the HyperLogLogs at keys.
+// With a single key the estimate is cached in the header.
+func (store *Store) PFCount(keys []string) (int64, error) {
+	store.lock(keys...)
+	defer store.unlock(keys...)
+	if len(keys) == 1 {
+		h, data, err := store.getHLL(keys[0])
+		if err != nil || h == nil {
+			return 0, err
+		}
+		if !h.cardValid {
+			h.card = h.count()
+			h.cardValid = true
+			store.putString(keys[0], h.encode(), data.ttl)
+		}
+		return int64(h.card), nil
+	}
+	union := newHLL()
+	for _, key := range keys {
+		h, _, err := store.getHLL(key)
+		if err != nil {
+			return 0, err
+		}
+		if h != nil {
+			union.merge(h)
+		}
+	}
+	return int64(union.count()), nil
+}
+
+// PFMerge stores the union of dest and the HyperLogLogs at keys into dest.
+// The result stays sparse only when every input was sparse.
+func (store *Store) PFMerge(dest string, keys []string) error {
+	locked := append([]string{dest}, keys...)
+	store.lock(locked...)
+	defer store.unlock(locked...)
+	union := newHLL()
+	target, data, err := store.getHLL(dest)
+	if err != nil {
+		return err
+	}
+	if target != nil {
+		union.merge(target)
+		union.sparse = target.sparse
+	}
+	for _, key := range keys {
+		h, _, err := store.getHLL(key)
+		if err != nil {
+			return err
+		}
+		if h != nil {
+			union.merge(h)
+			union.sparse = union.sparse && h.sparse
+		}
+	}
+	union.cardValid = false
+	store.putString(dest, union.encode(), data.ttl)
+	return nil
+}
@@ -0,0 +1,379 @@
+package cache
+
+import "fmt"
+
+var (
+	ErrNoSuchKey = fmt.Errorf("ERR no such key")
+	ErrIndexOutOfRange = fmt.Errorf("ERR index out of range")
+)
+
+// getList returns the list stored at key, nil when the key does not exist.
+// The caller must hold the key locks.
+func (store *Store) getList(key string) (*Deque, error) {
+	data, ok := store.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	if data.dataType != "list" {
+		return nil, ErrWrongType
+	}
+	return data.value.List, nil
+}
+
+// listRange clamps an inclusive LRANGE-style range to a list of the given
+// length. ok is false when the range is empty.
+func listRange(length int, start, stop int64) (int, int, bool) {
+	n := int64(length)
+	if start < 0 {
+		start = n + start
+	}
+	if stop < 0 {
+		stop = n + stop
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop || start >= n {
+		return 0, 0, false
+	}
+	return int(start), int(stop), true
+}
+
+// Push adds values to the head (left) or tail of the list at key, creating
+// it unless onlyExisting is set, and returns the new length.
+func (store *Store) Push(key string, values []string, left, onlyExisting bool) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	list, err := store.getList(key)
+	if err != nil {
+		return 0, err
+	}
+	if list == nil {
+		if onlyExisting {
+			return 0, nil
+		}
+		list = NewDeque()
+		store.put(key, storeData{
+			value: item{List: list},
+			dataType: "list",
+		})
+	}
+	for _, value := range values {
+		if left {
+			list.PushFront(value)
+		} else {
+			list.PushBack(value)
+		}
+	}
+	return list.Len(), nil
+}
+
+// Pop removes up to count elements from the head (left) or tail of the list
+// at key. ok is false when the key does not exist.
+func (store *Store) Pop(key string, count int, left bool) ([]string, bool, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	list, err := store.getList(key)
+	if err != nil || list == nil {
+		return nil, false, err
+	}
+	return store.popList(key, list, count, left), true, nil
+}
+
+// popList pops up to count elements and deletes the key once the list is
+// empty. The caller must hold the key locks.
+func (store *Store) popList(key string, list *Deque, count int, left bool) []string {
+	values := []string{}
+	for i := 0; i < count; i++ {
+		var value string
+		var ok bool
+		if left {
+			value, ok = list.PopFront()
+		} else {
+			value, ok = list.PopBack()
+		}
+		if !ok {
+			break
+		}
+		values = append(values, value)
+	}
+	store.modified(key)
+	if list.Len() == 0 {
+		store.remove(key)
+	}
+	return values
+}
+
+func (store *Store) LLen(key string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	list, err := store.getList(key)
+	if err != nil || list == nil {
+		return 0, err
+	}
+	return list.Len(), nil
+}
+
+func (store *Store) LRange(key string, start, stop int64) ([]string, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	list, err := store.getList(key)
+	if err != nil || list == nil {
+		return []string{}, err
+	}
+	first, last, ok := listRange(list.Len(), start, stop)
+	if !ok {
+		return []string{}, nil
+	}
+	return list.Range(first, last), nil
+}
+
+// normalizeIndex turns a possibly negative list index into an offset from
+// the head, reporting false when it falls outside the list.
+func normalizeIndex(length int, index int64) (int, bool) {
+	if index < 0 {
+		index += int64(length)
+	}
+	if index < 0 || index >= int64(length) {
+		return 0, false
+	}
+	return int(index), true
+}
+
+func (store *Store) LIndex(key string, index int64) (string, bool, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	list, err := store.getList(key)
+	if err != nil || list == nil {
+		return "", false, err
+	}
+	i, ok := normalizeIndex(list.Len(), index)
+	if !ok {
+		return "", false, nil
+	}
+	return list.Index(i), true, nil
+}
+
+func (store *Store) LSet(key string, index int64, value string) error {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	list, err := store.getList(key)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		return ErrNoSuchKey
+	}
+	i, ok := normalizeIndex(list.Len(), index)
+	if !ok {
+		return ErrIndexOutOfRange
+	}
+	list.Set(i, value)
+	return nil
+}
+
+// replaceList rebuilds the list at key from values, deleting the key when
+// values is empty. The caller must hold the key locks.
+func (store *Store) replaceList(key string, values []string) {
+	if len(values) == 0 {
+		store.remove(key)
+		return
+	}
+	data := store.shard(key).data[key]
+	data.value.List = newDequeFrom(values)
+	store.put(key, data)
+}
+
+// LRem removes up to count occurrences of element, from the head when count
+// is positive, from the tail when negative and all of them when zero.
+func (store *Store) LRem(key string, count int64, element string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	list, err := store.getList(key)
+	if err != nil || list == nil {
+		return 0, err
+	}
+	values := list.Values()
+	keep := make([]bool, len(values))
+	removed := 0
+	for i := range values {
+		idx := i
+		if count < 0 {
+			idx = len(values) - 1 - i
+		}
+		keep[idx] = true
+		if values[idx] == element && (count == 0 || int64(removed) < count || int64(removed) < -count) {
+			keep[idx] = false
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	remaining := make([]string, 0, len(values)-removed)
+	for i, value := range values {
+		if keep[i] {
+			remaining = append(remaining, value)
+		}
+	}
+	store.replaceList(key, remaining)
+	return removed, nil
+}
+
+func (store *Store) LTrim(key string, start, stop int64) error {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	list, err := store.getList(key)
+	if err != nil || list == nil {
+		return err
+	}
+	first, last, ok := listRange(list.Len(), start, stop)
+	if !ok {
+		store.remove(key)
+		return nil
+	}
+	tail := list.Len() - 1 - last
+	for i := 0; i < first; i++ {
+		list.PopFront()
+	}
+	for i := 0; i < tail; i++ {
+		list.PopBack()
+	}
+	return nil
+}
+
+// LInsert inserts element before or after the first occurrence of pivot. It
+// returns the new length, -1 when pivot is missing and 0 when key is.
+func (store *Store) LInsert(key string, before bool, pivot, element string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	list, err := store.getList(key)
+	if err != nil || list == nil {
+		return 0, err
+	}
+	values := list.Values()
+	for i, value := range values {
+		if value != pivot {
+			continue
+		}
+		if !before {
+			i++
+		}
+		inserted := make([]string, 0, len(values)+1)
+		inserted = append(inserted, values[:i]...)
+		inserted = append(inserted, element)
+		inserted = append(inserted, values[i:]...)
+		store.replaceList(key, inserted)
+		return len(inserted), nil
+	}
+	return -1, nil
+}
+
+// LPos returns the indexes of up to count matches of element, skipping the
+// first rank-1 matches (scanning from the tail when rank is negative) and
+// looking at no more than maxLen elements when maxLen is positive. A count of
+// 0 returns every match.
+func (store *Store) LPos(key, element string, rank, count, maxLen int64) ([]int64, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	list, err := store.getList(key)
+	if err != nil || list == nil {
+		return []int64{}, err
+	}
+	matches := []int64{}
+	length := list.Len()
+	skip := rank - 1
+	if rank < 0 {
+		skip = -rank - 1
+	}
+	for i := 0; i < length; i++ {
+		if maxLen > 0 && int64(i) >= maxLen {
+			break
+		}
+		idx := i
+		if rank < 0 {
+			idx = length - 1 - i
+		}
+		if list.Index(idx) != element {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		matches = append(matches, int64(idx))
+		if count > 0 && int64(len(matches)) >= count {
+			break
+		}
+	}
+	return matches, nil
+}
+
+// LMove pops an element from one end of src and pushes it onto one end of
+// dst, atomically. ok is false when src does not exist.
+func (store *Store) LMove(src, dst string, fromLeft, toLeft bool) (string, bool, error) {
+	store.lock(src, dst)
+	defer store.unlock(src, dst)
+	return store.lmove(src, dst, fromLeft, toLeft)
+}
+
+// lmove is LMove for callers already holding the key locks.
+func (store *Store) lmove(src, dst string, fromLeft, toLeft bool) (string, bool, error) {
+	list, err := store.getList(src)
+	if err != nil || list == nil {
+		return "", false, err
+	}
+	target, err := store.getList(dst)
+	if err != nil {
+		return "", false, err
+	}
+	var value string
+	if fromLeft {
+		value, _ = list.PopFront()
+	} else {
+		value, _ = list.PopBack()
+	}
+	if target == nil {
+		target = NewDeque()
+		store.put(dst, storeData{
+			value: item{List: target},
+			dataType: "list",
+		})
+	}
+	if toLeft {
+		target.PushFront(value)
+	} else {
+		target.PushBack(value)
+	}
+	store.modified(src, dst)
+	if list.Len() == 0 {
+		store.remove(src)
+	}
+	return value, true, nil
+}
+
+// LMPop pops up to count elements from the first non-empty list among keys,
+// returning the key it popped from, or "" when all are empty.
+func (store *Store) LMPop(keys []string, left bool, count int) (string, []string, error) {
+	store.lock(keys...)
+	defer store.unlock(keys...)
+	for _, key := range keys {
+		list, err := store.getList(key)
+		if err != nil {
+			return "", nil, err
+		}
+		if list != nil {
+			return key, store.popList(key, list, count, left), nil
+		}
+	}
+	return "", nil, nil
+}
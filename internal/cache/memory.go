@@ -0,0 +1,343 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// keyOverhead approximates what a key costs besides its name and value:
+	// the map entry and the storeData.
+	keyOverhead = 96
+	// stringHeader is the size of a Go string or pointer plus length.
+	stringHeader = 16
+	// memorySamples is how many elements of a collection are measured to
+	// estimate the size of all of them, as MEMORY USAGE does by default.
+	memorySamples = 5
+	// evictionPoolSize is how many eviction candidates are remembered
+	// between evictions.
+	evictionPoolSize = 16
+)
+
+var (
+	ErrOOM = fmt.Errorf("OOM command not allowed when used memory > 'maxmemory'.")
+
+	maxMemory atomic.Int64
+	maxMemoryPolicy atomic.Value
+	maxMemorySamples = newThreshold(5)
+
+	evictionPolicies = []string{"noeviction", "allkeys-lru", "allkeys-lfu", "allkeys-random", "volatile-lru", "volatile-lfu", "volatile-random", "volatile-ttl"}
+)
+
+func init() {
+	maxMemoryPolicy.Store("noeviction")
+}
+
+// parseMemory reads a number of bytes with an optional unit: k, m and g are
+// powers of 1000, kb, mb and gb powers of 1024.
+func parseMemory(value string) (int64, bool) {
+	value = strings.ToLower(value)
+	unit := int64(1)
+	for _, u := range []struct {
+		suffix string
+		unit int64
+	}{{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30}, {"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000}, {"b", 1}} {
+		if strings.HasSuffix(value, u.suffix) {
+			value, unit = strings.TrimSuffix(value, u.suffix), u.unit
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/unit {
+		return 0, false
+	}
+	return n * unit, true
+}
+
+// MemoryConfig returns the value of maxmemory, maxmemory-policy or
+// maxmemory-samples.
+func MemoryConfig(name string) (string, bool) {
+	switch name {
+	case "maxmemory":
+		return strconv.FormatInt(maxMemory.Load(), 10), true
+	case "maxmemory-policy":
+		return maxMemoryPolicy.Load().(string), true
+	case "maxmemory-samples":
+		return strconv.FormatInt(maxMemorySamples.Load(), 10), true
+	}
+	return "", false
+}
+
+// SetMemoryConfig changes maxmemory, maxmemory-policy or maxmemory-samples.
+func SetMemoryConfig(name, value string) error {
+	invalid := fmt.Errorf("ERR Invalid argument '%s' for CONFIG SET '%s'", value, name)
+	switch name {
+	case "maxmemory":
+		n, ok := parseMemory(value)
+		if !ok {
+			return invalid
+		}
+		maxMemory.Store(n)
+	case "maxmemory-policy":
+		for _, policy := range evictionPolicies {
+			if strings.ToLower(value) == policy {
+				maxMemoryPolicy.Store(policy)
+				return nil
+			}
+		}
+		return invalid
+	case "maxmemory-samples":
+		n, ok := ParseInt(value)
+		if !ok || n < 1 || n > 64 {
+			return invalid
+		}
+		maxMemorySamples.Store(n)
+	default:
+		return fmt.Errorf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", name)
+	}
+	return nil
+}
+
+// sampledSize estimates the total size of n elements from the sizes of up
+// to memorySamples of them spread over the collection.
+func sampledSize(n int, size func(i int) int) int64 {
+	if n == 0 {
+		return 0
+	}
+	samples := min(n, memorySamples)
+	total := int64(0)
+	for s := 0; s < samples; s++ {
+		total += int64(size(s * n / samples))
+	}
+	return total * int64(n) / int64(samples)
+}
+
+func (d *Deque) memory() int64 {
+	if d.IsListpack() {
+		return int64(cap(d.lp)*stringHeader + d.lpSize)
+	}
+	return int64(len(d.chunks)*8+d.used*dequeChunkSize*stringHeader) + sampledSize(d.length, func(i int) int { return len(d.Index(i)) })
+}
+
+func (s *Set) memory() int64 {
+	switch {
+	case s.IsIntset():
+		return int64(cap(s.ints) * 8)
+	case s.IsListpack():
+		return int64(cap(s.lp)*stringHeader) + sampledSize(len(s.lp), func(i int) int { return len(s.lp[i]) })
+	}
+	return int64(cap(s.keys)*stringHeader) + sampledSize(len(s.keys), func(i int) int { return 2*stringHeader + 8 + len(s.keys[i]) })
+}
+
+func (h *Hash) memory() int64 {
+	if h.IsListpack() {
+		return int64(cap(h.pairs)*stringHeader) + 2*sampledSize(len(h.pairs)/2, func(i int) int { return len(h.pairs[i*2]) + len(h.pairs[i*2+1]) })/2
+	}
+	return int64(cap(h.pairs)*stringHeader+h.Len()*(stringHeader+8)) + sampledSize(h.Len(), func(i int) int { return len(h.pairs[i*2]) + len(h.pairs[i*2+1]) })
+}
+
+func (z *ZSet) memory() int64 {
+	if z.IsListpack() {
+		return int64(cap(z.lp)*(stringHeader+8)) + sampledSize(len(z.lp), func(i int) int { return len(z.lp[i].Member) })
+	}
+	// A member is shared by the dict and its skiplist node, which holds
+	// about 1.33 levels on average.
+	return sampledSize(z.Len(), func(i int) int { return 3*stringHeader + 8 + 48 + 22 + len(z.At(i).Member) })
+}
+
+func (s *Stream) memory() int64 {
+	size := int64(len(s.nodes) * 8)
+	size += sampledSize(len(s.nodes), func(i int) int {
+		node := s.nodes[i]
+		entry := node.entries[0]
+		fields := 0
+		for _, field := range entry.Data {
+			fields += stringHeader + len(field)
+		}
+		return 24 + len(node.entries)*(16+24+fields)
+	})
+	for name, g := range s.groups {
+		size += int64(len(name) + 64 + len(g.pel)*(40+8))
+		for consumer := range g.consumers {
+			size += int64(len(consumer) + 64)
+		}
+	}
+	return size
+}
+
+// memory estimates how many bytes key and its value take.
+func (data storeData) memory(key string) int64 {
+	size := int64(keyOverhead + len(key))
+	v := data.value
+	switch data.dataType {
+	case "string":
+		if v.Bytes != nil {
+			size += int64(cap(v.Bytes))
+		} else if v.Int == nil {
+			size += int64(len(v.String))
+		} else if *v.Int < 0 || *v.Int >= sharedIntegers {
+			size += 8
+		}
+	case "list":
+		size += v.List.memory()
+	case "hash":
+		size += v.Hash.memory()
+	case "set":
+		size += v.Set.memory()
+	case "zset":
+		size += v.ZSet.memory()
+	case "stream":
+		size += v.Stream.memory()
+	}
+	return size
+}
+
+// MemoryUsage returns how many bytes key and its value are estimated to take.
+func (store *Store) MemoryUsage(key string) (int64, bool) {
+	store.lock(key)
+	defer store.unlock(key)
+	data, ok := store.lookupKey(key, false)
+	if !ok {
+		return 0, false
+	}
+	return data.memory(key), true
+}
+
+// MemoryStats returns the memory the keys are estimated to take and how
+// many keys were evicted.
+func (store *Store) MemoryStats() (used, evicted int64) {
+	return store.used.Load(), store.evicted.Load()
+}
+
+type evictionCandidate struct {
+	key string
+	idle int64
+}
+
+// sampleKeys returns up to n keys, the ones with a ttl when volatile is set,
+// taking one from each shard in turn from a random one. The caller must
+// hold the locks of all the shards.
+func (store *Store) sampleKeys(n int, volatile bool) []string {
+	keys := make([]string, 0, n)
+	start := rand.Intn(shardCount)
+	for i := 0; i < shardCount && len(keys) < n; i++ {
+		s := &store.shards[(start+i)%shardCount]
+		if volatile {
+			for key := range s.expires {
+				keys = append(keys, key)
+				break
+			}
+		} else {
+			for key := range s.data {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	return keys
+}
+
+// populateEvictionPool samples keys, the ones with a ttl for volatile
+// policies, and keeps the best candidates to evict in the pool sorted by how
+// idle they are: for longest unused for LRU, least frequently used for LFU
+// and soonest to expire for volatile-ttl. The caller must hold the locks of
+// all the shards.
+func (store *Store) populateEvictionPool(policy string, now int64) {
+	keys := store.sampleKeys(int(maxMemorySamples.Load()), strings.HasPrefix(policy, "volatile-"))
+	for _, key := range keys {
+		data := store.shard(key).data[key]
+		var idle int64
+		switch {
+		case strings.HasSuffix(policy, "-lru"):
+			idle = now - data.accessed
+		case strings.HasSuffix(policy, "-lfu"):
+			idle = 255 - int64(data.decayedFreq(now))
+		default:
+			idle = math.MaxInt64 - data.ttl
+		}
+		pool := store.evictionPool[:0]
+		for _, c := range store.evictionPool {
+			if c.key != key {
+				pool = append(pool, c)
+			}
+		}
+		i := sort.Search(len(pool), func(i int) bool { return pool[i].idle >= idle })
+		if len(pool) == evictionPoolSize {
+			if i == 0 {
+				store.evictionPool = pool
+				continue
+			}
+			pool = pool[1:]
+			i--
+		}
+		pool = append(pool, evictionCandidate{})
+		copy(pool[i+1:], pool[i:])
+		pool[i] = evictionCandidate{key: key, idle: idle}
+		store.evictionPool = pool
+	}
+}
+
+// evictionKey picks the key to evict under policy. The caller must hold the
+// locks of all the shards.
+func (store *Store) evictionKey(policy string) (string, bool) {
+	volatile := strings.HasPrefix(policy, "volatile-")
+	if policy == "noeviction" {
+		return "", false
+	}
+	if strings.HasSuffix(policy, "-random") {
+		keys := store.sampleKeys(1, volatile)
+		if len(keys) == 0 {
+			return "", false
+		}
+		return keys[0], true
+	}
+	now := time.Now().UnixMilli()
+	for {
+		store.populateEvictionPool(policy, now)
+		if len(store.evictionPool) == 0 {
+			return "", false
+		}
+		for len(store.evictionPool) > 0 {
+			last := len(store.evictionPool) - 1
+			c := store.evictionPool[last]
+			store.evictionPool = store.evictionPool[:last]
+			s := store.shard(c.key)
+			if _, ok := s.data[c.key]; !ok {
+				continue
+			}
+			if _, ok := s.expires[c.key]; volatile && !ok {
+				continue
+			}
+			return c.key, true
+		}
+	}
+}
+
+// Evict removes keys under maxmemory-policy until the keys fit in
+// maxmemory, and returns the keys it removed. ok is false when they still
+// do not fit. It only locks the shards when there is something to evict.
+func (store *Store) Evict() (evicted []string, ok bool) {
+	limit := maxMemory.Load()
+	if limit == 0 || store.used.Load() <= limit {
+		return nil, true
+	}
+	policy := maxMemoryPolicy.Load().(string)
+	store.lockAll()
+	defer store.unlockAll()
+	for store.used.Load() > limit {
+		key, ok := store.evictionKey(policy)
+		if !ok {
+			return evicted, false
+		}
+		store.remove(key)
+		store.evicted.Add(1)
+		evicted = append(evicted, key)
+	}
+	return evicted, true
+}
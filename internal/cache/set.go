@@ -0,0 +1,487 @@
+package cache
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// Set is an unordered collection of unique strings. Small sets whose members
+// are all integers are kept as a sorted slice of int64, like Redis's intset,
+// and other small sets as a slice searched linearly, like a listpack. They
+// are converted to a hash table once they grow past set-max-intset-entries
+// or set-max-listpack-entries members, or hold a member longer than
+// set-max-listpack-value. The hash table maps each member to its index in
+// keys, so random members can be picked, as SPOP and SRANDMEMBER do, without
+// walking the map.
+type Set struct {
+	ints []int64
+	lp []string
+	members map[string]int
+	keys []string
+}
+
+func NewSet() *Set {
+	return &Set{ints: []int64{}}
+}
+
+func (s *Set) IsIntset() bool {
+	return s.ints != nil
+}
+
+func (s *Set) IsListpack() bool {
+	return s.lp != nil
+}
+
+// convert moves the members to a hash table, or with listpack set to a
+// listpack, making room for one more.
+func (s *Set) convert(listpack bool) {
+	members := s.Members()
+	s.ints, s.lp = nil, nil
+	if listpack {
+		s.lp = append(make([]string, 0, len(members)+1), members...)
+		return
+	}
+	s.members = make(map[string]int, len(members)+1)
+	s.keys = append(make([]string, 0, len(members)+1), members...)
+	for i, member := range members {
+		s.members[member] = i
+	}
+}
+
+// searchInt returns where n is, or would be inserted, in the intset.
+func (s *Set) searchInt(n int64) (int, bool) {
+	i := sort.Search(len(s.ints), func(i int) bool { return s.ints[i] >= n })
+	return i, i < len(s.ints) && s.ints[i] == n
+}
+
+// searchListpack returns the index of member in the listpack, or -1.
+func (s *Set) searchListpack(member string) int {
+	for i, m := range s.lp {
+		if m == member {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Set) Add(member string) bool {
+	if s.IsIntset() {
+		n, ok := ParseInt(member)
+		if ok {
+			i, found := s.searchInt(n)
+			if found {
+				return false
+			}
+			if len(s.ints) < threshold("set-max-intset-entries") {
+				s.ints = append(s.ints, 0)
+				copy(s.ints[i+1:], s.ints[i:])
+				s.ints[i] = n
+				return true
+			}
+			s.convert(false)
+		} else {
+			s.convert(len(s.ints) < threshold("set-max-listpack-entries") && len(member) <= threshold("set-max-listpack-value"))
+		}
+	}
+	if s.IsListpack() {
+		if s.searchListpack(member) != -1 {
+			return false
+		}
+		if len(s.lp) < threshold("set-max-listpack-entries") && len(member) <= threshold("set-max-listpack-value") {
+			s.lp = append(s.lp, member)
+			return true
+		}
+		s.convert(false)
+	}
+	if _, ok := s.members[member]; ok {
+		return false
+	}
+	s.members[member] = len(s.keys)
+	s.keys = append(s.keys, member)
+	return true
+}
+
+func (s *Set) Remove(member string) bool {
+	if s.IsIntset() {
+		n, ok := ParseInt(member)
+		if !ok {
+			return false
+		}
+		i, found := s.searchInt(n)
+		if found {
+			s.ints = append(s.ints[:i], s.ints[i+1:]...)
+		}
+		return found
+	}
+	if s.IsListpack() {
+		i := s.searchListpack(member)
+		if i != -1 {
+			s.lp = append(s.lp[:i], s.lp[i+1:]...)
+		}
+		return i != -1
+	}
+	i, ok := s.members[member]
+	if !ok {
+		return false
+	}
+	// The last member takes the place of the removed one.
+	last := s.keys[len(s.keys)-1]
+	s.keys[i] = last
+	s.members[last] = i
+	s.keys = s.keys[:len(s.keys)-1]
+	delete(s.members, member)
+	return true
+}
+
+func (s *Set) Has(member string) bool {
+	if s.IsIntset() {
+		n, ok := ParseInt(member)
+		if !ok {
+			return false
+		}
+		_, found := s.searchInt(n)
+		return found
+	}
+	if s.IsListpack() {
+		return s.searchListpack(member) != -1
+	}
+	_, ok := s.members[member]
+	return ok
+}
+
+func (s *Set) Len() int {
+	if s.IsIntset() {
+		return len(s.ints)
+	}
+	if s.IsListpack() {
+		return len(s.lp)
+	}
+	return len(s.members)
+}
+
+func (s *Set) Members() []string {
+	members := make([]string, 0, s.Len())
+	if s.IsIntset() {
+		for _, n := range s.ints {
+			members = append(members, strconv.FormatInt(n, 10))
+		}
+		return members
+	}
+	if s.IsListpack() {
+		return append(members, s.lp...)
+	}
+	return append(members, s.keys...)
+}
+
+// member returns the member at index i of the encoding, in no particular
+// order but stable while the set is unchanged.
+func (s *Set) member(i int) string {
+	if s.IsIntset() {
+		return strconv.FormatInt(s.ints[i], 10)
+	}
+	if s.IsListpack() {
+		return s.lp[i]
+	}
+	return s.keys[i]
+}
+
+// randomIndexes returns count distinct indexes below n in random order,
+// shuffling only the positions it picks rather than all n of them.
+func randomIndexes(n, count int) []int {
+	picked := make([]int, count)
+	swapped := map[int]int{}
+	at := func(i int) int {
+		if j, ok := swapped[i]; ok {
+			return j
+		}
+		return i
+	}
+	for i := range picked {
+		j := i + rand.Intn(n-i)
+		picked[i] = at(j)
+		swapped[j] = at(i)
+	}
+	return picked
+}
+
+// getSet returns the set stored at key, nil when the key does not exist. The
+// caller must hold the key locks.
+func (store *Store) getSet(key string) (*Set, error) {
+	data, ok := store.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	if data.dataType != "set" {
+		return nil, ErrWrongType
+	}
+	return data.value.Set, nil
+}
+
+// putSet stores set at key, or deletes the key when set is empty. The caller
+// must hold the key locks.
+func (store *Store) putSet(key string, set *Set) {
+	if set.Len() == 0 {
+		store.remove(key)
+		return
+	}
+	store.put(key, storeData{
+		value: item{Set: set},
+		dataType: "set",
+	})
+}
+
+func (store *Store) SAdd(key string, members []string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	set, err := store.getSet(key)
+	if err != nil {
+		return 0, err
+	}
+	if set == nil {
+		set = NewSet()
+	}
+	added := 0
+	for _, member := range members {
+		if set.Add(member) {
+			added++
+		}
+	}
+	store.putSet(key, set)
+	return added, nil
+}
+
+func (store *Store) SRem(key string, members []string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	set, err := store.getSet(key)
+	if err != nil || set == nil {
+		return 0, err
+	}
+	removed := 0
+	for _, member := range members {
+		if set.Remove(member) {
+			removed++
+		}
+	}
+	store.putSet(key, set)
+	return removed, nil
+}
+
+func (store *Store) SMembers(key string) ([]string, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	set, err := store.getSet(key)
+	if err != nil || set == nil {
+		return []string{}, err
+	}
+	return set.Members(), nil
+}
+
+// SIsMember reports for each of members whether it belongs to the set at key.
+func (store *Store) SIsMember(key string, members []string) ([]bool, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	set, err := store.getSet(key)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]bool, len(members))
+	for i, member := range members {
+		found[i] = set != nil && set.Has(member)
+	}
+	return found, nil
+}
+
+func (store *Store) SCard(key string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	set, err := store.getSet(key)
+	if err != nil || set == nil {
+		return 0, err
+	}
+	return set.Len(), nil
+}
+
+// SPop removes and returns up to count random members of the set at key.
+func (store *Store) SPop(key string, count int) ([]string, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	set, err := store.getSet(key)
+	if err != nil || set == nil {
+		return []string{}, err
+	}
+	if count >= set.Len() {
+		members := set.Members()
+		store.remove(key)
+		return members, nil
+	}
+	members := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		member := set.member(rand.Intn(set.Len()))
+		set.Remove(member)
+		members = append(members, member)
+	}
+	store.putSet(key, set)
+	return members, nil
+}
+
+// SRandMember returns count random members of the set at key: distinct ones
+// when count is positive, possibly repeated ones when it is negative.
+func (store *Store) SRandMember(key string, count int64) ([]string, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	set, err := store.getSet(key)
+	if err != nil || set == nil {
+		return []string{}, err
+	}
+	picked := []string{}
+	if count < 0 {
+		for i := int64(0); i < -count; i++ {
+			picked = append(picked, set.member(rand.Intn(set.Len())))
+		}
+		return picked, nil
+	}
+	if count >= int64(set.Len()) {
+		return set.Members(), nil
+	}
+	for _, i := range randomIndexes(set.Len(), int(count)) {
+		picked = append(picked, set.member(i))
+	}
+	return picked, nil
+}
+
+// SMove moves member from the set at src to the set at dst, reporting
+// whether it was in src.
+func (store *Store) SMove(src, dst, member string) (bool, error) {
+	store.lock(src, dst)
+	defer store.unlock(src, dst)
+	from, err := store.getSet(src)
+	if err != nil {
+		return false, err
+	}
+	to, err := store.getSet(dst)
+	if err != nil {
+		return false, err
+	}
+	if from == nil || !from.Has(member) {
+		return false, nil
+	}
+	if src == dst {
+		return true, nil
+	}
+	from.Remove(member)
+	store.putSet(src, from)
+	if to == nil {
+		to = NewSet()
+	}
+	to.Add(member)
+	store.putSet(dst, to)
+	return true, nil
+}
+
+// setOp computes the intersection ("inter"), union ("union") or difference
+// ("diff") of the sets at keys, stopping an intersection once it holds limit
+// members when limit is positive. The caller must hold the key locks.
+func (store *Store) setOp(op string, keys []string, limit int) (*Set, error) {
+	sets := make([]*Set, len(keys))
+	for i, key := range keys {
+		set, err := store.getSet(key)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	result := NewSet()
+	switch op {
+	case "inter":
+		for _, set := range sets {
+			if set == nil {
+				return result, nil
+			}
+		}
+		// Walk the smallest set and probe the others.
+		sort.SliceStable(sets, func(i, j int) bool { return sets[i].Len() < sets[j].Len() })
+		for _, member := range sets[0].Members() {
+			inAll := true
+			for _, other := range sets[1:] {
+				if !other.Has(member) {
+					inAll = false
+					break
+				}
+			}
+			if inAll {
+				result.Add(member)
+				if limit > 0 && result.Len() >= limit {
+					break
+				}
+			}
+		}
+	case "union":
+		for _, set := range sets {
+			if set == nil {
+				continue
+			}
+			for _, member := range set.Members() {
+				result.Add(member)
+			}
+		}
+	case "diff":
+		if sets[0] == nil {
+			return result, nil
+		}
+		for _, member := range sets[0].Members() {
+			unique := true
+			for _, other := range sets[1:] {
+				if other != nil && other.Has(member) {
+					unique = false
+					break
+				}
+			}
+			if unique {
+				result.Add(member)
+			}
+		}
+	}
+	return result, nil
+}
+
+// SetOp returns the members of the intersection, union or difference of the
+// sets at keys.
+func (store *Store) SetOp(op string, keys []string) ([]string, error) {
+	store.lock(keys...)
+	defer store.unlock(keys...)
+	result, err := store.setOp(op, keys, 0)
+	if err != nil {
+		return nil, err
+	}
+	return result.Members(), nil
+}
+
+// SetOpStore stores the result of SetOp in dest and returns its size.
+func (store *Store) SetOpStore(op, dest string, keys []string) (int, error) {
+	locked := append([]string{dest}, keys...)
+	store.lock(locked...)
+	defer store.unlock(locked...)
+	result, err := store.setOp(op, keys, 0)
+	if err != nil {
+		return 0, err
+	}
+	store.putSet(dest, result)
+	return result.Len(), nil
+}
+
+// SInterCard returns the size of the intersection of the sets at keys,
+// counting no further than limit when it is positive.
+func (store *Store) SInterCard(keys []string, limit int) (int, error) {
+	store.lock(keys...)
+	defer store.unlock(keys...)
+	result, err := store.setOp("inter", keys, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.Len(), nil
+}
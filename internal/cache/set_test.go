@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+)
+
+// setOf returns a store holding at "set" the members 0 to n-1, with prefix
+// before each: an intset for small numbers, a listpack for small sets of
+// strings and a hash table for large ones.
+func setOf(t *testing.T, prefix string, n int) *Store {
+	store := newStore()
+	members := make([]string, n)
+	for i := range members {
+		members[i] = prefix + strconv.Itoa(i)
+	}
+	if _, err := store.SAdd("set", members); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestSRandMemberAndSPop(t *testing.T) {
+	for _, tc := range []struct {
+		name, prefix string
+		n int
+	}{{"intset", "", 10}, {"listpack", "m", 10}, {"hashtable", "m", 1000}} {
+		store := setOf(t, tc.prefix, tc.n)
+		picked, err := store.SRandMember("set", 7)
+		if err != nil || len(picked) != 7 {
+			t.Fatalf("%s: SRANDMEMBER 7 = %v, %v", tc.name, picked, err)
+		}
+		seen := map[string]bool{}
+		for _, member := range picked {
+			if seen[member] {
+				t.Fatalf("%s: SRANDMEMBER 7 repeated %s", tc.name, member)
+			}
+			seen[member] = true
+		}
+		if picked, _ := store.SRandMember("set", -30); len(picked) != 30 {
+			t.Fatalf("%s: SRANDMEMBER -30 returned %d members", tc.name, len(picked))
+		}
+		if picked, _ := store.SRandMember("set", int64(tc.n)+5); len(picked) != tc.n {
+			t.Fatalf("%s: SRANDMEMBER past the size returned %d members", tc.name, len(picked))
+		}
+
+		popped, err := store.SPop("set", 4)
+		if err != nil || len(popped) != 4 {
+			t.Fatalf("%s: SPOP 4 = %v, %v", tc.name, popped, err)
+		}
+		for _, member := range popped {
+			if found, _ := store.SIsMember("set", []string{member}); found[0] {
+				t.Fatalf("%s: %s is still a member after SPOP", tc.name, member)
+			}
+		}
+		if card, _ := store.SCard("set"); card != tc.n-4 {
+			t.Fatalf("%s: SCARD after SPOP 4 = %d", tc.name, card)
+		}
+		rest, _ := store.SPop("set", tc.n)
+		if len(rest) != tc.n-4 {
+			t.Fatalf("%s: SPOP of the rest returned %d members", tc.name, len(rest))
+		}
+		if store.GetType("set") != "none" {
+			t.Fatalf("%s: the emptied set was kept", tc.name)
+		}
+	}
+}
+
+func TestRandomIndexes(t *testing.T) {
+	for _, count := range []int{0, 1, 5, 100} {
+		seen := map[int]bool{}
+		for _, i := range randomIndexes(100, count) {
+			if i < 0 || i >= 100 || seen[i] {
+				t.Fatalf("randomIndexes(100, %d) picked %d twice or out of range", count, i)
+			}
+			seen[i] = true
+		}
+		if len(seen) != count {
+			t.Fatalf("randomIndexes(100, %d) picked %d indexes", count, len(seen))
+		}
+	}
+}
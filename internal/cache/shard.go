@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+)
+
+// shardCount is how many shards the keyspace is split into. Commands on keys
+// of different shards run in parallel.
+const shardCount = 64
+
+// shard holds the keys whose hash falls to it. expires indexes the keys
+// that have a ttl and retained the streams that have a retention to enforce.
+// Keys written while mu was held are collected in dirty and measured again
+// by unlock.
+type shard struct {
+	mu sync.Mutex
+	data map[string]storeData
+	expires map[string]struct{}
+	retained map[string]struct{}
+	dirty map[string]struct{}
+}
+
+// shardIndex hashes key with 32-bit FNV-1a.
+func shardIndex(key string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return int(h % shardCount)
+}
+
+func (store *Store) shard(key string) *shard {
+	return &store.shards[shardIndex(key)]
+}
+
+// shardsOf returns the shards of keys, each once, in ascending order. Every
+// command locks its shards in that order, so two commands on overlapping
+// keys cannot deadlock.
+func shardsOf(keys []string) []int {
+	indexes := make([]int, 0, len(keys))
+	seen := [shardCount]bool{}
+	for _, key := range keys {
+		i := shardIndex(key)
+		if !seen[i] {
+			seen[i] = true
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// lock locks the shards of keys, which must be every key the caller reads or
+// writes until it calls unlock with the same keys. Those are the key locks
+// the unexported helpers expect to be held.
+func (store *Store) lock(keys ...string) {
+	for _, i := range shardsOf(keys) {
+		store.shards[i].mu.Lock()
+	}
+}
+
+// unlock measures the keys the operation holding the locks of keys wrote
+// again and releases the locks.
+func (store *Store) unlock(keys ...string) {
+	for _, i := range shardsOf(keys) {
+		store.unlockShard(&store.shards[i])
+	}
+}
+
+// lockAll locks every shard, for operations over the whole keyspace or on
+// keys that cannot be known in advance.
+func (store *Store) lockAll() {
+	for i := range store.shards {
+		store.shards[i].mu.Lock()
+	}
+}
+
+func (store *Store) unlockAll() {
+	for i := range store.shards {
+		store.unlockShard(&store.shards[i])
+	}
+}
+
+// modified marks keys, whose values the caller changed in place, to be
+// measured again when their locks are released. Values stored with put are
+// marked already. The caller must hold the key locks.
+func (store *Store) modified(keys ...string) {
+	for _, key := range keys {
+		store.shard(key).dirty[key] = struct{}{}
+	}
+}
+
+func (store *Store) unlockShard(s *shard) {
+	for key := range s.dirty {
+		if data, ok := s.data[key]; ok {
+			size := data.memory(key)
+			store.used.Add(size - data.size)
+			data.size = size
+			s.data[key] = data
+		}
+	}
+	clear(s.dirty)
+	s.mu.Unlock()
+}
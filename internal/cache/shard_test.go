@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+)
+
+// usedMemory sums the sizes the keys are measured at now.
+func usedMemory(store *Store) int64 {
+	total := int64(0)
+	for i := range store.shards {
+		for key, data := range store.shards[i].data {
+			total += data.memory(key)
+		}
+	}
+	return total
+}
+
+func TestReadsDoNotMarkKeysDirty(t *testing.T) {
+	store := newStore()
+	store.Set("string", "value", 0)
+	store.Push("list", []string{"a", "b"}, false, false)
+	store.lock("string", "list")
+	store.lookup("string")
+	store.getList("list")
+	for _, key := range []string{"string", "list"} {
+		if _, ok := store.shard(key).dirty[key]; ok {
+			t.Fatalf("reading %s marked it dirty", key)
+		}
+	}
+	store.unlock("string", "list")
+}
+
+// Writes that change values in place keep the used memory in step with the
+// sizes of the keys.
+func TestUsedMemoryFollowsInPlaceWrites(t *testing.T) {
+	store := newStore()
+	values := make([]string, 200)
+	for i := range values {
+		values[i] = "element-" + strconv.Itoa(i)
+	}
+	for _, write := range []struct {
+		name string
+		run func()
+	}{
+		{"push", func() { store.Push("list", values, false, false) }},
+		{"push more", func() { store.Push("list", values, true, false) }},
+		{"pop", func() { store.Pop("list", 300, true) }},
+		{"lmove", func() { store.LMove("list", "other", true, true) }},
+		{"hset", func() { store.HSet("hash", []string{"field", "value"}, false) }},
+		{"hset more", func() { store.HSet("hash", values, false) }},
+		{"append", func() { store.Append("string", "0123456789") }},
+		{"append more", func() { store.Append("string", values[199]) }},
+		{"del", func() { store.Del("hash") }},
+	} {
+		write.run()
+		if used, want := store.used.Load(), usedMemory(store); used != want {
+			t.Fatalf("after %s the used memory is %d, the keys take %d", write.name, used, want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrSortNotFloat = fmt.Errorf("ERR One or more scores can't be converted into double")
+
+// SortOptions carries the modifiers of SORT. A By pattern without "*", such
+// as "nosort", leaves the elements in their stored order. Count is -1 for no
+// limit.
+type SortOptions struct {
+	By string
+	Offset int64
+	Count int64
+	Get []string
+	Desc bool
+	Alpha bool
+}
+
+// readsKeys reports whether a BY or GET pattern may name keys other than the
+// one being sorted, in which case SORT has to lock every shard.
+func (opts SortOptions) readsKeys() bool {
+	for _, pattern := range append([]string{opts.By}, opts.Get...) {
+		if strings.Contains(pattern, "*") || strings.Contains(pattern, "->") {
+			return true
+		}
+	}
+	return false
+}
+
+// sortElement is an element being sorted along with the value it is compared
+// by. by is nil when a BY pattern matched nothing.
+type sortElement struct {
+	value string
+	by *string
+	score float64
+}
+
+// lookupPattern resolves a SORT pattern for value: the first "*" is replaced
+// by value and a trailing "->field" reads that field of a hash instead of a
+// string. "#" stands for value itself. The caller must hold the locks of all
+// the shards when the pattern names keys, see readsKeys.
+func (store *Store) lookupPattern(pattern, value string) (string, bool) {
+	if pattern == "#" {
+		return value, true
+	}
+	star := strings.Index(pattern, "*")
+	if star == -1 {
+		return "", false
+	}
+	key, field := pattern, ""
+	if arrow := strings.Index(pattern[star+1:], "->"); arrow != -1 && star+1+arrow+2 < len(pattern) {
+		key, field = pattern[:star+1+arrow], pattern[star+1+arrow+2:]
+	}
+	key = key[:star] + value + key[star+1:]
+	data, ok := store.lookup(key)
+	if !ok {
+		return "", false
+	}
+	if field != "" {
+		if data.dataType != "hash" {
+			return "", false
+		}
+		return data.value.Hash.Get(field)
+	}
+	if data.dataType != "string" {
+		return "", false
+	}
+	return data.value.str(), true
+}
+
+// sort returns the sorted elements of the list, set or sorted set at key,
+// each replaced by what its GET patterns resolve to. The caller must hold
+// the lock of key, and those of all the shards when opts.readsKeys.
+func (store *Store) sort(key string, opts SortOptions) ([]*string, error) {
+	data, ok := store.lookup(key)
+	values := []string{}
+	dontSort := opts.By != "" && !strings.Contains(opts.By, "*")
+	if ok {
+		switch data.dataType {
+		case "list":
+			values = data.value.List.Values()
+		case "set":
+			values = data.value.Set.Members()
+			// Sets have no order of their own, so sort them anyway to
+			// answer the same way every time.
+			if dontSort {
+				dontSort, opts.By, opts.Alpha = false, "", true
+			}
+		case "zset":
+			for _, m := range data.value.ZSet.Range(ZRangeQuery{By: "rank", Start: 0, Stop: -1, Rev: dontSort && opts.Desc}) {
+				values = append(values, m.Member)
+			}
+		default:
+			return nil, ErrWrongType
+		}
+	}
+	elements := make([]sortElement, len(values))
+	for i, value := range values {
+		elements[i].value = value
+		if dontSort {
+			continue
+		}
+		v := value
+		by := &v
+		if opts.By != "" {
+			by = nil
+			if v, ok := store.lookupPattern(opts.By, value); ok {
+				by = &v
+			}
+		}
+		elements[i].by = by
+		if !opts.Alpha && by != nil {
+			score, ok := ParseFloat(*by)
+			if !ok {
+				return nil, ErrSortNotFloat
+			}
+			elements[i].score = score
+		}
+	}
+	if !dontSort {
+		sort.SliceStable(elements, func(i, j int) bool {
+			a, b := elements[i], elements[j]
+			cmp := 0
+			switch {
+			case !opts.Alpha:
+				if a.score < b.score {
+					cmp = -1
+				} else if a.score > b.score {
+					cmp = 1
+				}
+			case a.by == nil && b.by != nil:
+				cmp = -1
+			case a.by != nil && b.by == nil:
+				cmp = 1
+			case a.by != nil:
+				cmp = strings.Compare(*a.by, *b.by)
+			}
+			if cmp == 0 {
+				cmp = strings.Compare(a.value, b.value)
+			}
+			if opts.Desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+	}
+	start := min(max(opts.Offset, 0), int64(len(elements)))
+	end := int64(len(elements))
+	if opts.Count >= 0 {
+		end = min(start+opts.Count, end)
+	}
+	result := []*string{}
+	for _, element := range elements[start:end] {
+		if len(opts.Get) == 0 {
+			value := element.value
+			result = append(result, &value)
+			continue
+		}
+		for _, pattern := range opts.Get {
+			var got *string
+			if v, ok := store.lookupPattern(pattern, element.value); ok {
+				got = &v
+			}
+			result = append(result, got)
+		}
+	}
+	return result, nil
+}
+
+// Sort returns the elements of the list, set or sorted set at key sorted as
+// opts describes, nil standing for GET patterns that matched nothing. BY and
+// GET patterns may read any key, so it locks them all when there are any.
+func (store *Store) Sort(key string, opts SortOptions) ([]*string, error) {
+	if opts.readsKeys() {
+		store.lockAll()
+		defer store.unlockAll()
+	} else {
+		store.lock(key)
+		defer store.unlock(key)
+	}
+	return store.sort(key, opts)
+}
+
+// SortStore stores the result of Sort as a list at dest, deleting dest when it
+// is empty, and returns its length.
+func (store *Store) SortStore(key, dest string, opts SortOptions) (int, error) {
+	if opts.readsKeys() {
+		store.lockAll()
+		defer store.unlockAll()
+	} else {
+		store.lock(key, dest)
+		defer store.unlock(key, dest)
+	}
+	result, err := store.sort(key, opts)
+	if err != nil {
+		return 0, err
+	}
+	store.remove(dest)
+	if len(result) == 0 {
+		return 0, nil
+	}
+	list := NewDeque()
+	for _, value := range result {
+		if value == nil {
+			list.PushBack("")
+		} else {
+			list.PushBack(*value)
+		}
+	}
+	store.put(dest, storeData{
+		value: item{List: list},
+		dataType: "list",
+	})
+	return len(result), nil
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// otherShardKey returns a key whose shard is not the shard of any of keys.
+func otherShardKey(store *Store, keys ...string) string {
+	for i := 0; ; i++ {
+		key := "other" + strconv.Itoa(i)
+		taken := false
+		for _, k := range keys {
+			taken = taken || store.shard(k) == store.shard(key)
+		}
+		if !taken {
+			return key
+		}
+	}
+}
+
+// SORT locks every shard only when a BY or GET pattern can name other keys.
+func TestSortLocks(t *testing.T) {
+	store := newStore()
+	store.Push("list", []string{"3", "1", "2"}, false, false)
+	other := otherShardKey(store, "list", "dest")
+	tests := []struct {
+		opts SortOptions
+		all bool
+	}{
+		{SortOptions{Count: -1}, false},
+		{SortOptions{Count: -1, By: "nosort", Get: []string{"#"}}, false},
+		{SortOptions{Count: -1, By: "weight_*"}, true},
+		{SortOptions{Count: -1, Get: []string{"#", "hash->field"}}, true},
+	}
+	for _, test := range tests {
+		store.lock(other)
+		done := make(chan struct{})
+		go func() {
+			store.Sort("list", test.opts)
+			store.SortStore("list", "dest", test.opts)
+			close(done)
+		}()
+		select {
+		case <-done:
+			if test.all {
+				t.Fatalf("SORT %+v ran while another shard was locked", test.opts)
+			}
+			store.unlock(other)
+		case <-time.After(50 * time.Millisecond):
+			if !test.all {
+				t.Fatalf("SORT %+v waited for another shard", test.opts)
+			}
+			store.unlock(other)
+			<-done
+		}
+	}
+	result, err := store.Sort("list", SortOptions{Count: -1})
+	if err != nil || len(result) != 3 || *result[0] != "1" || *result[2] != "3" {
+		t.Fatalf("SORT list = %v, %v", result, err)
+	}
+}
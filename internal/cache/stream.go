@@ -0,0 +1,624 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidStreamID = fmt.Errorf("ERR Invalid stream ID specified as stream command argument")
+	ErrStreamIDZero = fmt.Errorf("ERR The ID specified in XADD must be greater than 0-0")
+	ErrStreamIDTooSmall = fmt.Errorf("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrStreamExhausted = fmt.Errorf("ERR The stream has exhausted the last possible ID, unable to add more items")
+	ErrInvalidStartID = fmt.Errorf("ERR invalid start ID for the interval")
+	ErrInvalidEndID = fmt.Errorf("ERR invalid end ID for the interval")
+	ErrSetIDSmallerThanTop = fmt.Errorf("ERR The ID specified in XSETID is smaller than the target stream top item")
+	ErrSetIDEntriesAdded = fmt.Errorf("ERR The entries_added specified in XSETID is smaller than the target stream length")
+	ErrSetIDMaxDeleted = fmt.Errorf("ERR The ID specified in XSETID is smaller than the provided max_deleted_entry_id")
+)
+
+// StreamID identifies a stream entry: the unix time in milliseconds it was
+// added at and a sequence number ordering entries added in the same
+// millisecond.
+type StreamID struct {
+	Ms uint64
+	Seq uint64
+}
+
+var MaxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Compare returns -1, 0 or 1 as id sorts before, equal to or after other.
+func (id StreamID) Compare(other StreamID) int {
+	switch {
+	case id.Ms < other.Ms || (id.Ms == other.Ms && id.Seq < other.Seq):
+		return -1
+	case id == other:
+		return 0
+	}
+	return 1
+}
+
+func (id StreamID) Less(other StreamID) bool {
+	return id.Compare(other) < 0
+}
+
+// Next returns the smallest ID after id. ok is false when id is the last
+// possible ID.
+func (id StreamID) Next() (StreamID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return StreamID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return StreamID{Ms: id.Ms + 1}, true
+	}
+	return id, false
+}
+
+// Prev returns the greatest ID before id. ok is false when id is 0-0.
+func (id StreamID) Prev() (StreamID, bool) {
+	switch {
+	case id.Seq > 0:
+		return StreamID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	case id.Ms > 0:
+		return StreamID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// ParseStreamID reads an ID given as "ms-seq", or as "ms" alone in which case
+// the sequence number is missingSeq.
+func ParseStreamID(s string, missingSeq uint64) (StreamID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, ErrInvalidStreamID
+	}
+	if !hasSeq {
+		return StreamID{Ms: ms, Seq: missingSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return StreamID{}, ErrInvalidStreamID
+	}
+	return StreamID{Ms: ms, Seq: seq}, nil
+}
+
+// ParseStreamBound reads the start or, when end is set, the end of an ID
+// range. "-" and "+" stand for the smallest and largest IDs, an ID without a
+// sequence number covers the whole millisecond and a "(" prefix excludes the
+// ID from the range.
+func ParseStreamBound(s string, end bool) (StreamID, error) {
+	if exclusive, ok := strings.CutPrefix(s, "("); ok {
+		id, err := ParseStreamBound(exclusive, end)
+		if err != nil || exclusive == "-" || exclusive == "+" {
+			return id, ErrInvalidStreamID
+		}
+		if end {
+			if id, ok = id.Prev(); !ok {
+				return id, ErrInvalidEndID
+			}
+		} else if id, ok = id.Next(); !ok {
+			return id, ErrInvalidStartID
+		}
+		return id, nil
+	}
+	switch {
+	case s == "-":
+		return StreamID{}, nil
+	case s == "+":
+		return MaxStreamID, nil
+	case end:
+		return ParseStreamID(s, math.MaxUint64)
+	}
+	return ParseStreamID(s, 0)
+}
+
+type StreamType struct {
+	Id StreamID
+	Data []string
+}
+
+// streamNodeMaxEntries bounds the entries of a stream node, like Redis's
+// stream-node-max-entries.
+const streamNodeMaxEntries = 100
+
+// streamNode is a run of consecutive entries, the counterpart of a listpack
+// in Redis's radix tree. Nodes are never empty.
+type streamNode struct {
+	entries []StreamType
+}
+
+func (node *streamNode) first() StreamID {
+	return node.entries[0].Id
+}
+
+func (node *streamNode) last() StreamID {
+	return node.entries[len(node.entries)-1].Id
+}
+
+// Stream holds entries in ID order, split into nodes so that seeking is a
+// binary search over nodes then within one, appending only touches the last
+// node and trimming drops whole nodes. It also remembers the last ID it
+// generated, which may belong to an entry that no longer exists, how many
+// entries were ever added, the greatest ID removed by XDEL and its consumer
+// groups.
+type Stream struct {
+	nodes []*streamNode
+	length int
+	lastID StreamID
+	entriesAdded uint64
+	maxDeletedID StreamID
+	groups map[string]*streamGroup
+	// retention is how many milliseconds of entries the stream keeps, 0
+	// meaning all of them and -1 deferring to the server default.
+	retention int64
+}
+
+func newStream() *Stream {
+	return &Stream{nodes: []*streamNode{}, groups: make(map[string]*streamGroup), retention: -1}
+}
+
+func (s *Stream) Len() int {
+	return s.length
+}
+
+func (s *Stream) LastID() StreamID {
+	return s.lastID
+}
+
+// seek returns the position of the first entry whose ID is not less than id
+// as a node index and an index within that node. The node index is
+// len(s.nodes) when there is no such entry.
+func (s *Stream) seek(id StreamID) (int, int) {
+	n := sort.Search(len(s.nodes), func(i int) bool { return !s.nodes[i].last().Less(id) })
+	if n == len(s.nodes) {
+		return n, 0
+	}
+	entries := s.nodes[n].entries
+	return n, sort.Search(len(entries), func(i int) bool { return !entries[i].Id.Less(id) })
+}
+
+// Range returns a copy of the entries with IDs between start and end
+// inclusive, so callers never share memory with later writes. Entries come
+// from the end of the range when rev is set, and at most count of them unless
+// count is negative.
+func (s *Stream) Range(start, end StreamID, count int, rev bool) []StreamType {
+	entries := []StreamType{}
+	if end.Less(start) || count == 0 {
+		return entries
+	}
+	if !rev {
+		n, i := s.seek(start)
+		for ; n < len(s.nodes); n, i = n+1, 0 {
+			for _, entry := range s.nodes[n].entries[i:] {
+				if end.Less(entry.Id) || len(entries) == count {
+					return entries
+				}
+				entries = append(entries, entry)
+			}
+		}
+		return entries
+	}
+	n, i := len(s.nodes), 0
+	if next, ok := end.Next(); ok {
+		n, i = s.seek(next)
+	}
+	for {
+		if i == 0 {
+			if n == 0 {
+				return entries
+			}
+			n--
+			i = len(s.nodes[n].entries)
+		}
+		i--
+		entry := s.nodes[n].entries[i]
+		if entry.Id.Less(start) || len(entries) == count {
+			return entries
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// nextID resolves the ID given to XADD: "*" for one generated from the
+// clock, "ms-*" for the next sequence number in a millisecond, or an
+// explicit ID that must be greater than any the stream generated before.
+func (s *Stream) nextID(spec string) (StreamID, error) {
+	if spec == "*" {
+		now := uint64(time.Now().UnixMilli())
+		if now > s.lastID.Ms {
+			return StreamID{Ms: now}, nil
+		}
+		id, ok := s.lastID.Next()
+		if !ok {
+			return id, ErrStreamExhausted
+		}
+		return id, nil
+	}
+	if msPart, ok := strings.CutSuffix(spec, "-*"); ok {
+		ms, err := strconv.ParseUint(msPart, 10, 64)
+		if err != nil {
+			return StreamID{}, ErrInvalidStreamID
+		}
+		id := StreamID{Ms: ms}
+		if ms == s.lastID.Ms {
+			if s.lastID.Seq == math.MaxUint64 {
+				return id, ErrStreamIDTooSmall
+			}
+			id.Seq = s.lastID.Seq + 1
+		}
+		if !s.lastID.Less(id) {
+			return id, ErrStreamIDTooSmall
+		}
+		return id, nil
+	}
+	id, err := ParseStreamID(spec, 0)
+	if err != nil {
+		return id, err
+	}
+	if id == (StreamID{}) {
+		return id, ErrStreamIDZero
+	}
+	if !s.lastID.Less(id) {
+		return id, ErrStreamIDTooSmall
+	}
+	return id, nil
+}
+
+func (s *Stream) append(id StreamID, fields []string) {
+	entry := StreamType{Id: id, Data: fields}
+	if len(s.nodes) == 0 || len(s.nodes[len(s.nodes)-1].entries) >= streamNodeMaxEntries {
+		s.nodes = append(s.nodes, &streamNode{entries: make([]StreamType, 0, streamNodeMaxEntries)})
+	}
+	last := s.nodes[len(s.nodes)-1]
+	last.entries = append(last.entries, entry)
+	s.length++
+	s.entriesAdded++
+	s.lastID = id
+}
+
+// StreamTrim is a trimming strategy: keep at most MaxLen entries or, when
+// MinID is set, drop entries with IDs smaller than Threshold. Approximate
+// trims only drop whole nodes, at most Limit entries of them: a negative
+// Limit stands for the default and 0 for no limit.
+type StreamTrim struct {
+	MinID bool
+	MaxLen int64
+	Threshold StreamID
+	Approx bool
+	Limit int64
+}
+
+// trim applies t and returns how many entries it removed.
+func (s *Stream) trim(t StreamTrim) int {
+	if t.Limit < 0 {
+		t.Limit = 100 * streamNodeMaxEntries
+	}
+	removed := 0
+	for len(s.nodes) > 0 {
+		node := s.nodes[0]
+		// drop is how many entries of the node the strategy removes.
+		drop := 0
+		if t.MinID {
+			drop = sort.Search(len(node.entries), func(i int) bool { return !node.entries[i].Id.Less(t.Threshold) })
+		} else if excess := int64(s.length) - t.MaxLen; excess > 0 {
+			drop = int(min(excess, int64(len(node.entries))))
+		}
+		if drop == 0 {
+			break
+		}
+		if drop < len(node.entries) {
+			if !t.Approx {
+				node.entries = append([]StreamType{}, node.entries[drop:]...)
+				s.length -= drop
+				removed += drop
+			}
+			break
+		}
+		if t.Approx && t.Limit > 0 && int64(removed+drop) > t.Limit {
+			break
+		}
+		s.nodes = s.nodes[1:]
+		s.length -= drop
+		removed += drop
+	}
+	return removed
+}
+
+// delete removes the entry with the given ID and reports whether it existed.
+func (s *Stream) delete(id StreamID) bool {
+	n, i := s.seek(id)
+	if n == len(s.nodes) || s.nodes[n].entries[i].Id != id {
+		return false
+	}
+	node := s.nodes[n]
+	node.entries = append(node.entries[:i:i], node.entries[i+1:]...)
+	if len(node.entries) == 0 {
+		s.nodes = append(s.nodes[:n:n], s.nodes[n+1:]...)
+	}
+	s.length--
+	if s.maxDeletedID.Less(id) {
+		s.maxDeletedID = id
+	}
+	return true
+}
+
+// getStream returns the stream stored at key, nil when the key does not
+// exist. The caller must hold the key locks.
+func (store *Store) getStream(key string) (*Stream, error) {
+	data, ok := store.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	if data.dataType != "stream" {
+		return nil, ErrWrongType
+	}
+	return data.value.Stream, nil
+}
+
+// XAddOptions carries the modifiers of an XADD command. Trim is nil when
+// the stream is not to be trimmed.
+type XAddOptions struct {
+	NoMkStream bool
+	Trim *StreamTrim
+}
+
+// XAdd appends an entry to the stream at key, creating it unless
+// opts.NoMkStream is set, and returns the ID it was given. ok is false when
+// the stream did not exist and was not created.
+func (store *Store) XAdd(key, id string, fields []string, opts XAddOptions) (StreamID, bool, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	stream, err := store.getStream(key)
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	if stream == nil {
+		if opts.NoMkStream {
+			return StreamID{}, false, nil
+		}
+		stream = newStream()
+	}
+	streamID, err := stream.nextID(id)
+	if err != nil {
+		return streamID, false, err
+	}
+	stream.append(streamID, fields)
+	if opts.Trim != nil {
+		stream.trim(*opts.Trim)
+	}
+	store.put(key, storeData{value: item{Stream: stream}, dataType: "stream", ttl: store.shard(key).data[key].ttl})
+	return streamID, true, nil
+}
+
+// XTrim trims the stream at key and returns how many entries were removed.
+func (store *Store) XTrim(key string, t StreamTrim) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	stream, err := store.getStream(key)
+	if err != nil || stream == nil {
+		return 0, err
+	}
+	return stream.trim(t), nil
+}
+
+// XConfigSet sets how many milliseconds of entries the stream at key keeps,
+// 0 keeping all of them and -1 restoring the server default.
+func (store *Store) XConfigSet(key string, retention int64) error {
+	store.lock(key)
+	defer store.unlock(key)
+	stream, err := store.getStream(key)
+	if err != nil {
+		return err
+	}
+	if stream == nil {
+		return ErrNoSuchKey
+	}
+	stream.retention = retention
+	store.indexRetention(key, stream)
+	return nil
+}
+
+func (store *Store) XConfigGet(key string) (int64, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	stream, err := store.getStream(key)
+	if err != nil {
+		return 0, err
+	}
+	if stream == nil {
+		return 0, ErrNoSuchKey
+	}
+	return stream.retention, nil
+}
+
+// StartStreamRetention trims, once a second, the entries of each stream that
+// are older than its retention, streams without one keeping
+// defaultRetention milliseconds of entries. onTrim is told the MINID each
+// trimmed stream was cut at.
+func (store *Store) StartStreamRetention(defaultRetention int64, onTrim func(key string, minID StreamID)) {
+	store.lockAll()
+	store.defaultRetention.Store(defaultRetention)
+	for i := range store.shards {
+		for key, data := range store.shards[i].data {
+			store.indexRetention(key, data.value.Stream)
+		}
+	}
+	store.unlockAll()
+	go store.retentionRoutine(onTrim)
+}
+
+// retention returns how many milliseconds of entries stream keeps, 0 for
+// all of them.
+func (store *Store) retention(stream *Stream) int64 {
+	if stream.retention == -1 {
+		return store.defaultRetention.Load()
+	}
+	return stream.retention
+}
+
+// indexRetention adds key to the streams expireStreams visits when stream,
+// the value at key or nil for other types, has a retention, and drops it
+// otherwise. The caller must hold the lock of the shard of key.
+func (store *Store) indexRetention(key string, stream *Stream) {
+	s := store.shard(key)
+	if stream != nil && store.retention(stream) > 0 {
+		s.retained[key] = struct{}{}
+	} else {
+		delete(s.retained, key)
+	}
+}
+
+// expireStreams trims the streams that hold entries older than their
+// retention and returns the MINID each was trimmed at. It only visits the
+// streams that have a retention, locking one shard at a time.
+func (store *Store) expireStreams() map[string]StreamID {
+	now := time.Now().UnixMilli()
+	trimmed := make(map[string]StreamID)
+	for i := range store.shards {
+		s := &store.shards[i]
+		s.mu.Lock()
+		for key := range s.retained {
+			data, ok := store.lookupKey(key, false)
+			if !ok {
+				continue
+			}
+			stream := data.value.Stream
+			retention := store.retention(stream)
+			if now <= retention {
+				continue
+			}
+			minID := StreamID{Ms: uint64(now - retention)}
+			if stream.trim(StreamTrim{MinID: true, Threshold: minID, Limit: 0}) > 0 {
+				store.modified(key)
+				trimmed[key] = minID
+			}
+		}
+		store.unlockShard(s)
+	}
+	return trimmed
+}
+
+// XDel removes the entries with the given IDs and returns how many existed.
+func (store *Store) XDel(key string, ids []StreamID) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	stream, err := store.getStream(key)
+	if err != nil || stream == nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, id := range ids {
+		if stream.delete(id) {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (store *Store) XLen(key string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	stream, err := store.getStream(key)
+	if err != nil || stream == nil {
+		return 0, err
+	}
+	return stream.Len(), nil
+}
+
+// XSetID overrides the last ID of the stream at key and, when given, its
+// entries-added counter and greatest deleted ID.
+func (store *Store) XSetID(key string, lastID StreamID, entriesAdded *uint64, maxDeletedID *StreamID) error {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	stream, err := store.getStream(key)
+	if err != nil {
+		return err
+	}
+	if stream == nil {
+		return ErrNoSuchKey
+	}
+	if maxDeletedID != nil && lastID.Less(*maxDeletedID) {
+		return ErrSetIDMaxDeleted
+	}
+	if entriesAdded != nil && *entriesAdded < uint64(stream.Len()) {
+		return ErrSetIDEntriesAdded
+	}
+	if stream.Len() > 0 && lastID.Less(stream.nodes[len(stream.nodes)-1].last()) {
+		return ErrSetIDSmallerThanTop
+	}
+	stream.lastID = lastID
+	if entriesAdded != nil {
+		stream.entriesAdded = *entriesAdded
+	}
+	if maxDeletedID != nil {
+		stream.maxDeletedID = *maxDeletedID
+	}
+	return nil
+}
+
+// XRange returns the entries of the stream at key with IDs between start and
+// end inclusive, as Stream.Range does.
+func (store *Store) XRange(key string, start, end StreamID, count int, rev bool) ([]StreamType, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	stream, err := store.getStream(key)
+	if err != nil || stream == nil {
+		return []StreamType{}, err
+	}
+	return stream.Range(start, end, count, rev), nil
+}
+
+// StreamInfo describes a stream for XINFO and for resolving the special IDs
+// of XREAD. First and Last are nil when the stream is empty.
+type StreamInfo struct {
+	Length int
+	Nodes int
+	LastID StreamID
+	EntriesAdded uint64
+	MaxDeletedID StreamID
+	Groups int
+	First *StreamType
+	Last *StreamType
+}
+
+// XInfo describes the stream at key, returning nil when it does not exist.
+func (store *Store) XInfo(key string) (*StreamInfo, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	stream, err := store.getStream(key)
+	if err != nil || stream == nil {
+		return nil, err
+	}
+	return stream.info(), nil
+}
+
+func (s *Stream) info() *StreamInfo {
+	info := &StreamInfo{
+		Length: s.Len(),
+		Nodes: len(s.nodes),
+		LastID: s.lastID,
+		EntriesAdded: s.entriesAdded,
+		MaxDeletedID: s.maxDeletedID,
+		Groups: len(s.groups),
+	}
+	if len(s.nodes) > 0 {
+		first := s.nodes[0].entries[0]
+		lastNode := s.nodes[len(s.nodes)-1]
+		last := lastNode.entries[len(lastNode.entries)-1]
+		info.First, info.Last = &first, &last
+	}
+	return info
+}
@@ -0,0 +1,53 @@
+package cache
+
+import "testing"
+
+func retained(store *Store, key string) bool {
+	_, ok := store.shard(key).retained[key]
+	return ok
+}
+
+// Only the streams with a retention, their own or the default, are indexed
+// for expireStreams to visit.
+func TestStreamRetentionIndex(t *testing.T) {
+	store := newStore()
+	for _, key := range []string{"kept", "trimmed"} {
+		if _, _, err := store.XAdd(key, "1-1", []string{"field", "value"}, XAddOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if retained(store, "kept") || retained(store, "trimmed") {
+		t.Fatal("streams without a retention were indexed")
+	}
+	if err := store.XConfigSet("trimmed", 1000); err != nil {
+		t.Fatal(err)
+	}
+	if !retained(store, "trimmed") || retained(store, "kept") {
+		t.Fatal("XCONFIG SET RETENTION did not index only its stream")
+	}
+	trimmed := store.expireStreams()
+	if len(trimmed) != 1 || trimmed["trimmed"] == (StreamID{}) {
+		t.Fatalf("expireStreams trimmed %v, want only \"trimmed\"", trimmed)
+	}
+	if n, _ := store.XLen("kept"); n != 1 {
+		t.Fatalf("the stream without a retention lost entries, %d left", n)
+	}
+
+	store.Del("trimmed")
+	if retained(store, "trimmed") {
+		t.Fatal("a deleted stream stayed indexed")
+	}
+	store.defaultRetention.Store(1000)
+	if err := store.XConfigSet("kept", -1); err != nil {
+		t.Fatal(err)
+	}
+	if !retained(store, "kept") {
+		t.Fatal("a stream under the default retention was not indexed")
+	}
+	if err := store.XConfigSet("kept", 0); err != nil {
+		t.Fatal(err)
+	}
+	if retained(store, "kept") {
+		t.Fatal("a stream keeping all its entries stayed indexed")
+	}
+}
@@ -0,0 +1,808 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	ErrNoGroup = fmt.Errorf("NOGROUP No such consumer group")
+	ErrBusyGroup = fmt.Errorf("BUSYGROUP Consumer Group name already exists")
+	ErrGroupNoKey = fmt.Errorf("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+)
+
+// entriesReadUnknown marks a group whose count of read entries cannot be
+// told, e.g. after its last ID was moved.
+const entriesReadUnknown = -1
+
+// pendingEntry is an entry delivered to a consumer of a group and not yet
+// acknowledged.
+type pendingEntry struct {
+	id StreamID
+	consumer *streamConsumer
+	deliveryTime int64
+	deliveryCount uint64
+}
+
+type streamConsumer struct {
+	name string
+	seenTime int64
+	activeTime int64
+	pending map[StreamID]*pendingEntry
+}
+
+// streamGroup is a consumer group. Its pending entries list is kept sorted
+// by ID, and each consumer indexes the entries delivered to it.
+type streamGroup struct {
+	lastID StreamID
+	entriesRead int64
+	pel []*pendingEntry
+	consumers map[string]*streamConsumer
+}
+
+func newStreamGroup(lastID StreamID, entriesRead int64) *streamGroup {
+	return &streamGroup{lastID: lastID, entriesRead: entriesRead, pel: []*pendingEntry{}, consumers: make(map[string]*streamConsumer)}
+}
+
+// pelSeek returns the index of the first pending entry whose ID is not less
+// than id.
+func (g *streamGroup) pelSeek(id StreamID) int {
+	return sort.Search(len(g.pel), func(i int) bool { return !g.pel[i].id.Less(id) })
+}
+
+func (g *streamGroup) pelGet(id StreamID) *pendingEntry {
+	if i := g.pelSeek(id); i < len(g.pel) && g.pel[i].id == id {
+		return g.pel[i]
+	}
+	return nil
+}
+
+func (g *streamGroup) pelAdd(pe *pendingEntry) {
+	i := g.pelSeek(pe.id)
+	g.pel = append(g.pel, nil)
+	copy(g.pel[i+1:], g.pel[i:])
+	g.pel[i] = pe
+	pe.consumer.pending[pe.id] = pe
+}
+
+func (g *streamGroup) pelRemove(pe *pendingEntry) {
+	i := g.pelSeek(pe.id)
+	g.pel = append(g.pel[:i], g.pel[i+1:]...)
+	delete(pe.consumer.pending, pe.id)
+}
+
+// assign hands a pending entry over to consumer.
+func (g *streamGroup) assign(pe *pendingEntry, consumer *streamConsumer) {
+	delete(pe.consumer.pending, pe.id)
+	pe.consumer = consumer
+	consumer.pending[pe.id] = pe
+}
+
+// consumer returns the named consumer, creating it if needed, and marks it
+// as seen.
+func (g *streamGroup) consumer(name string, now int64) *streamConsumer {
+	consumer, ok := g.consumers[name]
+	if !ok {
+		consumer = &streamConsumer{name: name, activeTime: -1, pending: make(map[StreamID]*pendingEntry)}
+		g.consumers[name] = consumer
+	}
+	consumer.seenTime = now
+	return consumer
+}
+
+// get returns the entry with the given ID.
+func (s *Stream) get(id StreamID) (StreamType, bool) {
+	n, i := s.seek(id)
+	if n == len(s.nodes) || s.nodes[n].entries[i].Id != id {
+		return StreamType{}, false
+	}
+	return s.nodes[n].entries[i], true
+}
+
+func (s *Stream) firstID() StreamID {
+	if len(s.nodes) == 0 {
+		return StreamID{}
+	}
+	return s.nodes[0].first()
+}
+
+// hasTombstonesFrom reports whether entries at or after start may have been
+// removed by XDEL.
+func (s *Stream) hasTombstonesFrom(start StreamID) bool {
+	if s.Len() == 0 || s.maxDeletedID == (StreamID{}) {
+		return false
+	}
+	return !s.maxDeletedID.Less(start)
+}
+
+// entriesUpTo estimates how many entries were ever added up to id, returning
+// entriesReadUnknown when deletions make that impossible to tell.
+func (s *Stream) entriesUpTo(id StreamID) int64 {
+	if s.entriesAdded == 0 {
+		return 0
+	}
+	added := int64(s.entriesAdded)
+	if s.Len() == 0 && !s.lastID.Less(id) {
+		return added
+	}
+	switch id.Compare(s.lastID) {
+	case 0:
+		return added
+	case 1:
+		return entriesReadUnknown
+	}
+	first := s.firstID()
+	if s.maxDeletedID == (StreamID{}) || s.maxDeletedID.Less(first) {
+		switch id.Compare(first) {
+		case -1:
+			return added - int64(s.Len())
+		case 0:
+			return added - int64(s.Len()) + 1
+		}
+	}
+	return entriesReadUnknown
+}
+
+// lag returns how many entries the group has yet to read, or -1 when it
+// cannot be told.
+func (s *Stream) lag(g *streamGroup) int64 {
+	if s.entriesAdded == 0 {
+		return 0
+	}
+	if g.entriesRead != entriesReadUnknown && !s.hasTombstonesFrom(g.lastID) {
+		return int64(s.entriesAdded) - g.entriesRead
+	}
+	if read := s.entriesUpTo(g.lastID); read != entriesReadUnknown {
+		return int64(s.entriesAdded) - read
+	}
+	return -1
+}
+
+// advance moves the last ID of g to id, an entry just delivered.
+func (s *Stream) advance(g *streamGroup, id StreamID) {
+	if g.entriesRead != entriesReadUnknown && !s.hasTombstonesFrom(id) {
+		g.entriesRead++
+	} else if s.entriesAdded > 0 {
+		g.entriesRead = s.entriesUpTo(id)
+	}
+	g.lastID = id
+}
+
+// getGroup returns the stream at key and its named group. The caller must
+// hold the key locks.
+func (store *Store) getGroup(key, group string) (*Stream, *streamGroup, error) {
+	stream, err := store.getStream(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stream == nil || stream.groups[group] == nil {
+		return stream, nil, ErrNoGroup
+	}
+	return stream, stream.groups[group], nil
+}
+
+// resolveGroupID reads the ID given to XGROUP CREATE or SETID, "$" standing
+// for the last ID of the stream.
+func resolveGroupID(stream *Stream, id string) (StreamID, error) {
+	if id == "$" {
+		return stream.lastID, nil
+	}
+	return ParseStreamID(id, 0)
+}
+
+// XGroupCreate creates a consumer group starting after id, creating the
+// stream as well when mkStream is set.
+func (store *Store) XGroupCreate(key, group, id string, mkStream bool, entriesRead int64) error {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	stream, err := store.getStream(key)
+	if err != nil {
+		return err
+	}
+	lastID, err := resolveGroupID(newStream(), id)
+	if stream != nil {
+		lastID, err = resolveGroupID(stream, id)
+	}
+	if err != nil {
+		return err
+	}
+	if stream == nil {
+		if !mkStream {
+			return ErrGroupNoKey
+		}
+		stream = newStream()
+		store.put(key, storeData{value: item{Stream: stream}, dataType: "stream"})
+	}
+	if stream.groups[group] != nil {
+		return ErrBusyGroup
+	}
+	stream.groups[group] = newStreamGroup(lastID, entriesRead)
+	return nil
+}
+
+// XGroupSetID moves the last ID of a group.
+func (store *Store) XGroupSetID(key, group, id string, entriesRead int64) error {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	stream, err := store.getStream(key)
+	if err != nil {
+		return err
+	}
+	if stream == nil {
+		return ErrGroupNoKey
+	}
+	g := stream.groups[group]
+	if g == nil {
+		return ErrNoGroup
+	}
+	lastID, err := resolveGroupID(stream, id)
+	if err != nil {
+		return err
+	}
+	g.lastID, g.entriesRead = lastID, entriesRead
+	return nil
+}
+
+func (store *Store) XGroupDestroy(key, group string) (bool, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	stream, err := store.getStream(key)
+	if err != nil {
+		return false, err
+	}
+	if stream == nil {
+		return false, ErrGroupNoKey
+	}
+	if stream.groups[group] == nil {
+		return false, nil
+	}
+	delete(stream.groups, group)
+	return true, nil
+}
+
+// XGroupCreateConsumer adds a consumer to a group and reports whether it was
+// new.
+func (store *Store) XGroupCreateConsumer(key, group, consumer string) (bool, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	stream, err := store.getStream(key)
+	if err != nil {
+		return false, err
+	}
+	if stream == nil {
+		return false, ErrGroupNoKey
+	}
+	g := stream.groups[group]
+	if g == nil {
+		return false, ErrNoGroup
+	}
+	if g.consumers[consumer] != nil {
+		return false, nil
+	}
+	g.consumer(consumer, time.Now().UnixMilli())
+	return true, nil
+}
+
+// XGroupDelConsumer removes a consumer from a group, dropping the entries
+// pending for it, and returns how many there were.
+func (store *Store) XGroupDelConsumer(key, group, consumer string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	stream, err := store.getStream(key)
+	if err != nil {
+		return 0, err
+	}
+	if stream == nil {
+		return 0, ErrGroupNoKey
+	}
+	g := stream.groups[group]
+	if g == nil {
+		return 0, ErrNoGroup
+	}
+	c := g.consumers[consumer]
+	if c == nil {
+		return 0, nil
+	}
+	pending := len(c.pending)
+	for _, pe := range c.pending {
+		g.pelRemove(pe)
+	}
+	delete(g.consumers, consumer)
+	return pending, nil
+}
+
+// GroupRead is the outcome of XREADGROUP on one stream. Entries read from
+// the history have nil Data when they were deleted from the stream. LastID
+// and EntriesRead are the state of the group afterwards and Time the
+// delivery time given to new entries.
+type GroupRead struct {
+	Entries []StreamType
+	LastID StreamID
+	EntriesRead int64
+	Time int64
+}
+
+// GroupReadQuery is what XREADGROUP reads from one stream: the entries after
+// the last one delivered to the group or, when History is set, the entries
+// pending for the consumer after ID.
+type GroupReadQuery struct {
+	Key string
+	History bool
+	ID StreamID
+}
+
+// XReadGroup reads the streams of queries for consumer of group. New entries
+// become pending unless noAck is set. Every stream is checked before any is
+// read, so when one is not a stream or has no such group nothing is read,
+// and the index of that query is returned with the error.
+func (store *Store) XReadGroup(queries []GroupReadQuery, group, consumer string, count int, noAck bool) ([]GroupRead, int, error) {
+	keys := make([]string, len(queries))
+	for i, q := range queries {
+		keys[i] = q.Key
+	}
+	store.lock(keys...)
+	defer store.unlock(keys...)
+	streams := make([]*Stream, len(queries))
+	groups := make([]*streamGroup, len(queries))
+	for i, q := range queries {
+		stream, g, err := store.getGroup(q.Key, group)
+		if err != nil {
+			return nil, i, err
+		}
+		streams[i], groups[i] = stream, g
+	}
+	now := time.Now().UnixMilli()
+	reads := make([]GroupRead, len(queries))
+	for i, q := range queries {
+		reads[i] = readGroup(streams[i], groups[i], consumer, q.History, q.ID, count, noAck, now)
+		store.modified(q.Key)
+	}
+	return reads, 0, nil
+}
+
+// readGroup serves XREADGROUP on one stream at time now.
+func readGroup(stream *Stream, g *streamGroup, consumer string, history bool, id StreamID, count int, noAck bool, now int64) GroupRead {
+	c := g.consumer(consumer, now)
+	read := GroupRead{Entries: []StreamType{}, Time: now}
+	if history {
+		for i := g.pelSeek(id); i < len(g.pel) && len(read.Entries) != count; i++ {
+			pe := g.pel[i]
+			if pe.consumer != c || pe.id == id {
+				continue
+			}
+			entry, ok := stream.get(pe.id)
+			if !ok {
+				entry = StreamType{Id: pe.id}
+			}
+			pe.deliveryTime = now
+			pe.deliveryCount++
+			read.Entries = append(read.Entries, entry)
+		}
+	} else if start, ok := g.lastID.Next(); ok {
+		read.Entries = stream.Range(start, MaxStreamID, count, false)
+		for _, entry := range read.Entries {
+			stream.advance(g, entry.Id)
+			if noAck {
+				continue
+			}
+			if pe := g.pelGet(entry.Id); pe != nil {
+				g.assign(pe, c)
+				pe.deliveryTime, pe.deliveryCount = now, 1
+			} else {
+				g.pelAdd(&pendingEntry{id: entry.Id, consumer: c, deliveryTime: now, deliveryCount: 1})
+			}
+		}
+		if len(read.Entries) > 0 {
+			c.activeTime = now
+		}
+	}
+	read.LastID, read.EntriesRead = g.lastID, g.entriesRead
+	return read
+}
+
+// XAck removes entries from the pending entries list of a group and returns
+// how many were pending.
+func (store *Store) XAck(key, group string, ids []StreamID) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	_, g, err := store.getGroup(key, group)
+	if err == ErrNoGroup {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	acked := 0
+	for _, id := range ids {
+		if pe := g.pelGet(id); pe != nil {
+			g.pelRemove(pe)
+			acked++
+		}
+	}
+	return acked, nil
+}
+
+// PendingEntry is an entry of a pending entries list as XPENDING reports it.
+// DeliveryTime is in unix milliseconds.
+type PendingEntry struct {
+	Id StreamID
+	Consumer string
+	DeliveryTime int64
+	DeliveryCount uint64
+}
+
+// PendingSummary sums up the pending entries list of a group: its size, its
+// smallest and greatest IDs and how many entries each consumer has pending.
+type PendingSummary struct {
+	Count int
+	Min StreamID
+	Max StreamID
+	Consumers []string
+	Counts []int
+}
+
+func (store *Store) XPendingSummary(key, group string) (PendingSummary, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	_, g, err := store.getGroup(key, group)
+	if err != nil {
+		return PendingSummary{}, err
+	}
+	summary := PendingSummary{Count: len(g.pel), Consumers: []string{}, Counts: []int{}}
+	if len(g.pel) == 0 {
+		return summary, nil
+	}
+	summary.Min, summary.Max = g.pel[0].id, g.pel[len(g.pel)-1].id
+	names := make([]string, 0, len(g.consumers))
+	for name, c := range g.consumers {
+		if len(c.pending) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		summary.Consumers = append(summary.Consumers, name)
+		summary.Counts = append(summary.Counts, len(g.consumers[name].pending))
+	}
+	return summary, nil
+}
+
+// PendingQuery selects pending entries by ID range, optionally only those
+// idle for at least MinIdle milliseconds or delivered to Consumer, returning
+// at most Count of them.
+type PendingQuery struct {
+	Start StreamID
+	End StreamID
+	Count int
+	MinIdle int64
+	Consumer string
+}
+
+func (store *Store) XPending(key, group string, q PendingQuery) ([]PendingEntry, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	_, g, err := store.getGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	entries := []PendingEntry{}
+	for i := g.pelSeek(q.Start); i < len(g.pel) && len(entries) < q.Count; i++ {
+		pe := g.pel[i]
+		if q.End.Less(pe.id) {
+			break
+		}
+		if (q.Consumer != "" && pe.consumer.name != q.Consumer) || now-pe.deliveryTime < q.MinIdle {
+			continue
+		}
+		entries = append(entries, PendingEntry{Id: pe.id, Consumer: pe.consumer.name, DeliveryTime: pe.deliveryTime, DeliveryCount: pe.deliveryCount})
+	}
+	return entries, nil
+}
+
+// XClaimOptions carries the modifiers of XCLAIM. DeliveryTime is the unix
+// time in milliseconds claimed entries get, RetryCount their delivery count
+// when positive, and LastID, when set, a last ID for the group to move to if
+// it is greater than its current one.
+type XClaimOptions struct {
+	DeliveryTime int64
+	RetryCount int64
+	Force bool
+	JustID bool
+	LastID *StreamID
+}
+
+// claim hands pe over to c. The delivery count is only bumped when the
+// entry is actually delivered.
+func (g *streamGroup) claim(pe *pendingEntry, c *streamConsumer, deliveryTime int64, justID bool) {
+	g.assign(pe, c)
+	pe.deliveryTime = deliveryTime
+	if !justID {
+		pe.deliveryCount++
+	}
+}
+
+// XClaim gives consumer the pending entries among ids idle for at least
+// minIdle milliseconds. Entries deleted from the stream are dropped from the
+// pending entries list and returned separately.
+func (store *Store) XClaim(key, group, consumer string, minIdle int64, ids []StreamID, opts XClaimOptions) ([]StreamType, []StreamID, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	stream, g, err := store.getGroup(key, group)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now().UnixMilli()
+	if opts.LastID != nil && g.lastID.Less(*opts.LastID) {
+		g.lastID = *opts.LastID
+	}
+	c := g.consumer(consumer, now)
+	claimed, deleted := []StreamType{}, []StreamID{}
+	for _, id := range ids {
+		pe := g.pelGet(id)
+		entry, exists := stream.get(id)
+		if pe == nil {
+			if !opts.Force || !exists {
+				continue
+			}
+			pe = &pendingEntry{id: id, consumer: c, deliveryTime: now}
+			g.pelAdd(pe)
+		}
+		if !exists {
+			g.pelRemove(pe)
+			deleted = append(deleted, id)
+			continue
+		}
+		if minIdle > 0 && now-pe.deliveryTime < minIdle {
+			continue
+		}
+		g.claim(pe, c, opts.DeliveryTime, opts.JustID)
+		if opts.RetryCount > 0 {
+			pe.deliveryCount = uint64(opts.RetryCount)
+		}
+		if !opts.JustID {
+			c.activeTime = now
+		}
+		claimed = append(claimed, entry)
+	}
+	return claimed, deleted, nil
+}
+
+// XAutoClaim scans the pending entries list of a group from start, giving
+// consumer up to count entries idle for at least minIdle milliseconds. It
+// returns the ID to resume the scan from, 0-0 once it is complete, the
+// claimed entries and those dropped because they were deleted.
+func (store *Store) XAutoClaim(key, group, consumer string, minIdle int64, start StreamID, count int, justID bool) (StreamID, []StreamType, []StreamID, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	stream, g, err := store.getGroup(key, group)
+	if err != nil {
+		return StreamID{}, nil, nil, err
+	}
+	now := time.Now().UnixMilli()
+	c := g.consumer(consumer, now)
+	claimed, deleted := []StreamType{}, []StreamID{}
+	attempts := count * 10
+	i := g.pelSeek(start)
+	for ; i < len(g.pel) && attempts > 0 && len(claimed) < count; attempts-- {
+		pe := g.pel[i]
+		if minIdle > 0 && now-pe.deliveryTime < minIdle {
+			i++
+			continue
+		}
+		entry, exists := stream.get(pe.id)
+		if !exists {
+			g.pelRemove(pe)
+			deleted = append(deleted, pe.id)
+			continue
+		}
+		g.claim(pe, c, now, justID)
+		if !justID {
+			c.activeTime = now
+		}
+		claimed = append(claimed, entry)
+		i++
+	}
+	next := StreamID{}
+	if i < len(g.pel) {
+		next = g.pel[i].id
+	}
+	return next, claimed, deleted, nil
+}
+
+// StreamState is the complete state of a stream, as loaded from an RDB file.
+type StreamState struct {
+	Entries []StreamType
+	LastID StreamID
+	EntriesAdded uint64
+	MaxDeletedID StreamID
+	Groups []GroupState
+}
+
+// GroupState is the state of a consumer group. Pending entries name the
+// consumer they were delivered to.
+type GroupState struct {
+	Name string
+	LastID StreamID
+	EntriesRead int64
+	Pending []PendingEntry
+	Consumers []ConsumerState
+}
+
+type ConsumerState struct {
+	Name string
+	SeenTime int64
+	ActiveTime int64
+}
+
+// RestoreStream stores a stream with its consumer groups at key, replacing
+// whatever the key held.
+func (store *Store) RestoreStream(key string, state StreamState) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.put(key, storeData{value: item{Stream: newStreamFrom(state)}, dataType: "stream"})
+}
+
+// newStreamFrom builds a stream from its state as an RDB file holds it.
+func newStreamFrom(state StreamState) *Stream {
+	stream := newStream()
+	for _, entry := range state.Entries {
+		stream.append(entry.Id, entry.Data)
+	}
+	stream.lastID = state.LastID
+	stream.entriesAdded = state.EntriesAdded
+	stream.maxDeletedID = state.MaxDeletedID
+	for _, gs := range state.Groups {
+		g := newStreamGroup(gs.LastID, gs.EntriesRead)
+		for _, cs := range gs.Consumers {
+			c := g.consumer(cs.Name, cs.SeenTime)
+			c.activeTime = cs.ActiveTime
+		}
+		for _, pe := range gs.Pending {
+			c := g.consumers[pe.Consumer]
+			if c == nil {
+				c = g.consumer(pe.Consumer, 0)
+			}
+			g.pelAdd(&pendingEntry{id: pe.Id, consumer: c, deliveryTime: pe.DeliveryTime, deliveryCount: pe.DeliveryCount})
+		}
+		stream.groups[gs.Name] = g
+	}
+	return stream
+}
+
+// GroupInfo describes a consumer group for XINFO. EntriesRead and Lag are -1
+// when they cannot be told. Pending lists pending entries only when details
+// were asked for.
+type GroupInfo struct {
+	Name string
+	LastID StreamID
+	EntriesRead int64
+	Lag int64
+	PendingCount int
+	Pending []PendingEntry
+	Consumers []ConsumerInfo
+}
+
+// ConsumerInfo describes a consumer for XINFO. ActiveTime is -1 when the
+// consumer never read or claimed an entry.
+type ConsumerInfo struct {
+	Name string
+	SeenTime int64
+	ActiveTime int64
+	PendingCount int
+	Pending []PendingEntry
+}
+
+// groupInfo describes g, listing up to count pending entries for the group
+// and for each consumer when count is not negative, all of them when it is
+// 0.
+func (s *Stream) groupInfo(name string, g *streamGroup, count int) GroupInfo {
+	info := GroupInfo{Name: name, LastID: g.lastID, EntriesRead: g.entriesRead, Lag: s.lag(g), PendingCount: len(g.pel), Consumers: []ConsumerInfo{}}
+	limit := func(n int) int {
+		if count > 0 {
+			return min(n, count)
+		}
+		return n
+	}
+	if count >= 0 {
+		info.Pending = []PendingEntry{}
+		for _, pe := range g.pel[:limit(len(g.pel))] {
+			info.Pending = append(info.Pending, PendingEntry{Id: pe.id, Consumer: pe.consumer.name, DeliveryTime: pe.deliveryTime, DeliveryCount: pe.deliveryCount})
+		}
+	}
+	names := make([]string, 0, len(g.consumers))
+	for consumer := range g.consumers {
+		names = append(names, consumer)
+	}
+	sort.Strings(names)
+	for _, consumer := range names {
+		c := g.consumers[consumer]
+		ci := ConsumerInfo{Name: consumer, SeenTime: c.seenTime, ActiveTime: c.activeTime, PendingCount: len(c.pending)}
+		if count >= 0 {
+			ci.Pending = []PendingEntry{}
+			for _, pe := range g.pel {
+				if len(ci.Pending) == limit(len(c.pending)) {
+					break
+				}
+				if pe.consumer == c {
+					ci.Pending = append(ci.Pending, PendingEntry{Id: pe.id, Consumer: consumer, DeliveryTime: pe.deliveryTime, DeliveryCount: pe.deliveryCount})
+				}
+			}
+		}
+		info.Consumers = append(info.Consumers, ci)
+	}
+	return info
+}
+
+// groupInfos describes the groups of s sorted by name, as groupInfo does.
+func (s *Stream) groupInfos(count int) []GroupInfo {
+	names := make([]string, 0, len(s.groups))
+	for name := range s.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	infos := make([]GroupInfo, len(names))
+	for i, name := range names {
+		infos[i] = s.groupInfo(name, s.groups[name], count)
+	}
+	return infos
+}
+
+// XInfoFull describes the stream at key along with its first count entries
+// and its groups with up to count pending entries each, count 0 meaning no
+// limit.
+func (store *Store) XInfoFull(key string, count int) (*StreamInfo, []StreamType, []GroupInfo, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	stream, err := store.getStream(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if stream == nil {
+		return nil, nil, nil, ErrNoSuchKey
+	}
+	limit := -1
+	if count > 0 {
+		limit = count
+	}
+	return stream.info(), stream.Range(StreamID{}, MaxStreamID, limit, false), stream.groupInfos(count), nil
+}
+
+func (store *Store) XInfoGroups(key string) ([]GroupInfo, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	stream, err := store.getStream(key)
+	if err != nil {
+		return nil, err
+	}
+	if stream == nil {
+		return nil, ErrNoSuchKey
+	}
+	return stream.groupInfos(-1), nil
+}
+
+func (store *Store) XInfoConsumers(key, group string) ([]ConsumerInfo, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	stream, err := store.getStream(key)
+	if err != nil {
+		return nil, err
+	}
+	if stream == nil {
+		return nil, ErrNoSuchKey
+	}
+	g := stream.groups[group]
+	if g == nil {
+		return nil, ErrNoGroup
+	}
+	return stream.groupInfo(group, g, -1).Consumers, nil
+}
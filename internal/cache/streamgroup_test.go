@@ -0,0 +1,31 @@
+package cache
+
+import "testing"
+
+// XREADGROUP over several streams reads none of them when one lacks the
+// group, so no entry is left pending for a reply that is an error.
+func TestXReadGroupChecksEveryStreamFirst(t *testing.T) {
+	store := newStore()
+	for _, key := range []string{"a", "b"} {
+		if _, _, err := store.XAdd(key, "*", []string{"field", "value"}, XAddOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.XGroupCreate("a", "g", "0", false, -1); err != nil {
+		t.Fatal(err)
+	}
+	queries := []GroupReadQuery{{Key: "a"}, {Key: "b"}}
+	_, failed, err := store.XReadGroup(queries, "g", "alice", -1, false)
+	if err != ErrNoGroup || failed != 1 {
+		t.Fatalf("got query %d failing with %v, want query 1 with %v", failed, err, ErrNoGroup)
+	}
+	summary, err := store.XPendingSummary("a", "g")
+	if err != nil || summary.Count != 0 {
+		t.Fatalf("%d entries pending after the failed read, %v", summary.Count, err)
+	}
+
+	reads, _, err := store.XReadGroup(queries[:1], "g", "alice", -1, false)
+	if err != nil || len(reads) != 1 || len(reads[0].Entries) != 1 {
+		t.Fatalf("reading a alone = %+v, %v", reads, err)
+	}
+}
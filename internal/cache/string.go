@@ -0,0 +1,377 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const maxStringSize = 512 * 1024 * 1024
+
+var (
+	ErrNotInteger = fmt.Errorf("ERR value is not an integer or out of range")
+	ErrNotFloat = fmt.Errorf("ERR value is not a valid float")
+	ErrOverflow = fmt.Errorf("ERR increment or decrement would overflow")
+	ErrStringTooLong = fmt.Errorf("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	ErrLCSNotString = fmt.Errorf("ERR The specified keys must contain string values")
+	ErrLCSTooLarge = fmt.Errorf("ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len")
+)
+
+// ParseInt parses s the way Redis parses integers stored in strings: no
+// sign other than a leading '-', no leading zeros and no surrounding spaces.
+func ParseInt(s string) (int64, bool) {
+	if len(s) == 0 || len(s) > 20 {
+		return 0, false
+	}
+	digits := s
+	if s[0] == '-' {
+		digits = s[1:]
+	}
+	if len(digits) == 0 || (digits[0] == '0' && len(s) > 1) {
+		return 0, false
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// ParseFloat parses s as a finite or infinite float, rejecting NaN and
+// surrounding spaces.
+func ParseFloat(s string) (float64, bool) {
+	if len(s) == 0 || s[0] == ' ' || s[len(s)-1] == ' ' {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+// FormatFloat renders f in the shortest form that parses back to the same
+// value, the way INCRBYFLOAT replies.
+func FormatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// getString returns the string stored at key. The caller must hold the key locks.
+func (store *Store) getString(key string) (storeData, bool, error) {
+	data, ok := store.lookup(key)
+	if !ok {
+		return storeData{}, false, nil
+	}
+	if data.dataType != "string" {
+		return storeData{}, false, ErrWrongType
+	}
+	return data, true, nil
+}
+
+// putString replaces the string at key keeping its ttl. The caller must hold
+// the key locks.
+func (store *Store) putString(key, value string, ttl int64) {
+	store.put(key, storeData{
+		value: newStringItem(value),
+		dataType: "string",
+		ttl: ttl,
+	})
+}
+
+func (store *Store) IncrBy(key string, delta int64) (int64, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	data, ok, err := store.getString(key)
+	if err != nil {
+		return 0, err
+	}
+	current := int64(0)
+	if ok {
+		current, ok = ParseInt(data.value.str())
+		if !ok {
+			return 0, ErrNotInteger
+		}
+	}
+	if (delta < 0 && current < math.MinInt64-delta) || (delta > 0 && current > math.MaxInt64-delta) {
+		return 0, ErrOverflow
+	}
+	current += delta
+	store.putString(key, strconv.FormatInt(current, 10), data.ttl)
+	return current, nil
+}
+
+func (store *Store) IncrByFloat(key string, delta float64) (string, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	data, ok, err := store.getString(key)
+	if err != nil {
+		return "", err
+	}
+	current := float64(0)
+	if ok {
+		current, ok = ParseFloat(data.value.str())
+		if !ok {
+			return "", ErrNotFloat
+		}
+	}
+	current += delta
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return "", fmt.Errorf("ERR increment would produce NaN or Infinity")
+	}
+	value := FormatFloat(current)
+	store.putString(key, value, data.ttl)
+	return value, nil
+}
+
+func (store *Store) Append(key, value string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	data, _, err := store.getString(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(data.value.str())+len(value) > maxStringSize {
+		return 0, ErrStringTooLong
+	}
+	newValue := data.value.str() + value
+	store.putString(key, newValue, data.ttl)
+	return len(newValue), nil
+}
+
+func (store *Store) StrLen(key string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	data, _, err := store.getString(key)
+	if err != nil {
+		return 0, err
+	}
+	return len(data.value.str()), nil
+}
+
+func (store *Store) GetRange(key string, start, end int64) (string, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	data, _, err := store.getString(key)
+	if err != nil {
+		return "", err
+	}
+	value := data.value.str()
+	length := int64(len(value))
+	if length == 0 || (start < 0 && end < 0 && start > end) {
+		return "", nil
+	}
+	if start < 0 {
+		start = length + start
+	}
+	if end < 0 {
+		end = length + end
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if start > end {
+		return "", nil
+	}
+	return value[start : end+1], nil
+}
+
+func (store *Store) SetRange(key string, offset int64, value string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	data, ok, err := store.getString(key)
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("ERR offset is out of range")
+	}
+	current := data.value.str()
+	if len(value) == 0 {
+		return len(current), nil
+	}
+	if offset+int64(len(value)) > maxStringSize {
+		return 0, ErrStringTooLong
+	}
+	buf := []byte(current)
+	if need := int(offset) + len(value); need > len(buf) {
+		buf = append(buf, make([]byte, need-len(buf))...)
+	}
+	copy(buf[offset:], value)
+	ttl := int64(0)
+	if ok {
+		ttl = data.ttl
+	}
+	store.putString(key, string(buf), ttl)
+	return len(buf), nil
+}
+
+// MGet returns the string at each key, nil for missing keys and keys of
+// another type.
+func (store *Store) MGet(keys []string) []*string {
+	store.lock(keys...)
+	defer store.unlock(keys...)
+	values := make([]*string, len(keys))
+	for i, key := range keys {
+		data, ok, err := store.getString(key)
+		if ok && err == nil {
+			value := data.value.str()
+			values[i] = &value
+		}
+	}
+	return values
+}
+
+// MSet sets each key/value pair in pairs, and with nx only if none of the keys
+// exist. It reports whether the values were written.
+func (store *Store) MSet(pairs []string, nx bool) bool {
+	keys := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		keys = append(keys, pairs[i])
+	}
+	store.lock(keys...)
+	defer store.unlock(keys...)
+	if nx {
+		for i := 0; i < len(pairs); i += 2 {
+			if _, ok := store.lookup(pairs[i]); ok {
+				return false
+			}
+		}
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		store.putString(pairs[i], pairs[i+1], 0)
+	}
+	return true
+}
+
+func (store *Store) GetDel(key string) (string, bool, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	data, ok, err := store.getString(key)
+	if !ok || err != nil {
+		return "", false, err
+	}
+	store.remove(key)
+	return data.value.str(), true, nil
+}
+
+// GetEx returns the string at key and updates its expiry: to expireAt when it
+// is positive, removing it when persist is set, leaving it otherwise.
+func (store *Store) GetEx(key string, expireAt int64, persist bool) (string, bool, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	data, ok, err := store.getString(key)
+	if !ok || err != nil {
+		return "", false, err
+	}
+	if expireAt > 0 {
+		data.ttl = expireAt
+	} else if persist {
+		data.ttl = 0
+	}
+	store.put(key, data)
+	return data.value.str(), true, nil
+}
+
+// LCSMatch is a run of bytes common to both strings of LCS, given as the
+// inclusive ranges it spans in each.
+type LCSMatch struct {
+	A [2]int
+	B [2]int
+	Len int
+}
+
+// LCSResult is the longest common subsequence of two strings, its length and
+// the matching runs it is made of, last first.
+type LCSResult struct {
+	Sequence string
+	Len int
+	Matches []LCSMatch
+}
+
+// LCS computes the longest common subsequence of the strings at key1 and
+// key2, missing keys counting as empty strings. Only runs of at least
+// minMatchLen bytes are listed in Matches.
+func (store *Store) LCS(key1, key2 string, minMatchLen int) (LCSResult, error) {
+	store.lock(key1, key2)
+	a, _, errA := store.getString(key1)
+	b, _, errB := store.getString(key2)
+	store.unlock(key1, key2)
+	if errA != nil || errB != nil {
+		return LCSResult{}, ErrLCSNotString
+	}
+	return lcs(a.value.str(), b.value.str(), minMatchLen)
+}
+
+// lcs fills the dynamic programming table of a and b, refusing tables that
+// would take more than maxStringSize bytes, then walks it back from the end
+// collecting the subsequence and its runs.
+func lcs(a, b string, minMatchLen int) (LCSResult, error) {
+	cols := len(b) + 1
+	if uint64(len(a)+1)*uint64(cols)*4 > maxStringSize {
+		return LCSResult{}, ErrLCSTooLarge
+	}
+	table := make([]uint32, (len(a)+1)*cols)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				table[i*cols+j] = table[(i-1)*cols+j-1] + 1
+			} else {
+				table[i*cols+j] = max(table[(i-1)*cols+j], table[i*cols+j-1])
+			}
+		}
+	}
+	res := LCSResult{Len: int(table[len(a)*cols+len(b)]), Matches: []LCSMatch{}}
+	sequence := make([]byte, res.Len)
+	idx := res.Len
+	// inRun tells whether match holds a run being extended backwards.
+	inRun := false
+	match := LCSMatch{}
+	for i, j := len(a), len(b); i > 0 && j > 0; {
+		emit := false
+		if a[i-1] == b[j-1] {
+			idx--
+			sequence[idx] = a[i-1]
+			if !inRun {
+				match = LCSMatch{A: [2]int{i - 1, i - 1}, B: [2]int{j - 1, j - 1}}
+				inRun = true
+			} else {
+				match.A[0], match.B[0] = i-1, j-1
+			}
+			emit = i == 1 || j == 1
+			i--
+			j--
+		} else {
+			if table[(i-1)*cols+j] > table[i*cols+j-1] {
+				i--
+			} else {
+				j--
+			}
+			emit = inRun
+		}
+		if emit {
+			match.Len = match.A[1] - match.A[0] + 1
+			if match.Len >= minMatchLen {
+				res.Matches = append(res.Matches, match)
+			}
+			inRun = false
+		}
+	}
+	res.Sequence = string(sequence)
+	return res, nil
+}
@@ -0,0 +1,707 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrMinMaxNotFloat = fmt.Errorf("ERR min or max is not a float")
+	ErrMinMaxNotLex = fmt.Errorf("ERR min or max not valid string range item")
+)
+
+type ZMember struct {
+	Member string
+	Score float64
+}
+
+// ScoreRange is a range of scores whose ends may be exclusive.
+type ScoreRange struct {
+	Min float64
+	Max float64
+	MinEx bool
+	MaxEx bool
+}
+
+// ParseScoreRange reads a range given as two scores, each optionally
+// prefixed with "(" to exclude it.
+func ParseScoreRange(min, max string) (ScoreRange, error) {
+	r := ScoreRange{}
+	var ok bool
+	if r.Min, r.MinEx, ok = parseScoreBound(min); !ok {
+		return r, ErrMinMaxNotFloat
+	}
+	if r.Max, r.MaxEx, ok = parseScoreBound(max); !ok {
+		return r, ErrMinMaxNotFloat
+	}
+	return r, nil
+}
+
+func parseScoreBound(bound string) (float64, bool, bool) {
+	exclusive := strings.HasPrefix(bound, "(")
+	score, ok := ParseFloat(strings.TrimPrefix(bound, "("))
+	return score, exclusive, ok
+}
+
+func (r ScoreRange) aboveMin(score float64) bool {
+	if r.MinEx {
+		return score > r.Min
+	}
+	return score >= r.Min
+}
+
+func (r ScoreRange) belowMax(score float64) bool {
+	if r.MaxEx {
+		return score < r.Max
+	}
+	return score <= r.Max
+}
+
+func (r ScoreRange) empty() bool {
+	return r.Min > r.Max || (r.Min == r.Max && (r.MinEx || r.MaxEx))
+}
+
+// lexBound is one end of a LexRange. inf is -1 for "-", 1 for "+" and 0 for
+// a string bound.
+type lexBound struct {
+	value string
+	exclusive bool
+	inf int
+}
+
+// LexRange is a range of members compared byte by byte, for sorted sets
+// whose members all have the same score.
+type LexRange struct {
+	Min lexBound
+	Max lexBound
+}
+
+// ParseLexRange reads a range whose ends are "-", "+", or a string prefixed
+// with "[" (inclusive) or "(" (exclusive).
+func ParseLexRange(min, max string) (LexRange, error) {
+	r := LexRange{}
+	var ok bool
+	if r.Min, ok = parseLexBound(min); !ok {
+		return r, ErrMinMaxNotLex
+	}
+	if r.Max, ok = parseLexBound(max); !ok {
+		return r, ErrMinMaxNotLex
+	}
+	return r, nil
+}
+
+func parseLexBound(bound string) (lexBound, bool) {
+	switch {
+	case bound == "-":
+		return lexBound{inf: -1}, true
+	case bound == "+":
+		return lexBound{inf: 1}, true
+	case strings.HasPrefix(bound, "["):
+		return lexBound{value: bound[1:]}, true
+	case strings.HasPrefix(bound, "("):
+		return lexBound{value: bound[1:], exclusive: true}, true
+	}
+	return lexBound{}, false
+}
+
+func (r LexRange) aboveMin(member string) bool {
+	switch r.Min.inf {
+	case -1:
+		return true
+	case 1:
+		return false
+	}
+	if r.Min.exclusive {
+		return member > r.Min.value
+	}
+	return member >= r.Min.value
+}
+
+func (r LexRange) belowMax(member string) bool {
+	switch r.Max.inf {
+	case 1:
+		return true
+	case -1:
+		return false
+	}
+	if r.Max.exclusive {
+		return member < r.Max.value
+	}
+	return member <= r.Max.value
+}
+
+func (r LexRange) empty() bool {
+	if r.Min.inf == 1 || r.Max.inf == -1 {
+		return true
+	}
+	if r.Min.inf != 0 || r.Max.inf != 0 {
+		return false
+	}
+	return r.Min.value > r.Max.value || (r.Min.value == r.Max.value && (r.Min.exclusive || r.Max.exclusive))
+}
+
+// ZRangeQuery selects members of a sorted set by rank (Start and Stop), by
+// score or lexicographically, optionally in reverse order and, for score and
+// lex ranges, skipping Offset members and returning at most Count of them
+// when Count is not negative.
+type ZRangeQuery struct {
+	By string
+	Start int64
+	Stop int64
+	Score ScoreRange
+	Lex LexRange
+	Rev bool
+	Offset int64
+	Count int64
+}
+
+// FormatScore renders a score the way Redis replies with it.
+func FormatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+	abs := math.Abs(score)
+	if abs == 0 || (abs >= 1e-4 && abs < 1e21) {
+		return strconv.FormatFloat(score, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(score, 'e', -1, 64)
+}
+
+// ZSet is a sorted set: a map from member to score for O(1) lookups and a
+// skiplist for ordered and rank-based access. Small sets instead keep their
+// members in a slice sorted by score then member, like Redis's listpack,
+// until they grow past zset-max-listpack-entries members or hold a member
+// longer than zset-max-listpack-value.
+type ZSet struct {
+	lp []ZMember
+	dict map[string]float64
+	zsl *skiplist
+}
+
+func NewZSet() *ZSet {
+	return &ZSet{lp: []ZMember{}}
+}
+
+func (z *ZSet) IsListpack() bool {
+	return z.lp != nil
+}
+
+func (z *ZSet) convert() {
+	z.dict = make(map[string]float64, len(z.lp))
+	z.zsl = newSkiplist()
+	for _, m := range z.lp {
+		z.dict[m.Member] = m.Score
+		z.zsl.insert(m.Score, m.Member)
+	}
+	z.lp = nil
+}
+
+// find returns the index of member in the listpack, or -1.
+func (z *ZSet) find(member string) int {
+	for i, m := range z.lp {
+		if m.Member == member {
+			return i
+		}
+	}
+	return -1
+}
+
+func (z *ZSet) Len() int {
+	if z.IsListpack() {
+		return len(z.lp)
+	}
+	return len(z.dict)
+}
+
+func (z *ZSet) Score(member string) (float64, bool) {
+	if z.IsListpack() {
+		if i := z.find(member); i != -1 {
+			return z.lp[i].Score, true
+		}
+		return 0, false
+	}
+	score, ok := z.dict[member]
+	return score, ok
+}
+
+// Set adds member with score, or moves it to score if it already exists.
+func (z *ZSet) Set(member string, score float64) {
+	if z.IsListpack() {
+		i := z.find(member)
+		if i == -1 && (len(z.lp) >= threshold("zset-max-listpack-entries") || len(member) > threshold("zset-max-listpack-value")) {
+			z.convert()
+		} else {
+			if i != -1 {
+				z.lp = append(z.lp[:i], z.lp[i+1:]...)
+			}
+			at := sort.Search(len(z.lp), func(j int) bool {
+				return z.lp[j].Score > score || (z.lp[j].Score == score && z.lp[j].Member >= member)
+			})
+			z.lp = append(z.lp, ZMember{})
+			copy(z.lp[at+1:], z.lp[at:])
+			z.lp[at] = ZMember{Member: member, Score: score}
+			return
+		}
+	}
+	if old, ok := z.dict[member]; ok {
+		if old == score {
+			return
+		}
+		z.zsl.delete(old, member)
+	}
+	z.dict[member] = score
+	z.zsl.insert(score, member)
+}
+
+func (z *ZSet) Remove(member string) bool {
+	if z.IsListpack() {
+		i := z.find(member)
+		if i != -1 {
+			z.lp = append(z.lp[:i], z.lp[i+1:]...)
+		}
+		return i != -1
+	}
+	score, ok := z.dict[member]
+	if !ok {
+		return false
+	}
+	delete(z.dict, member)
+	z.zsl.delete(score, member)
+	return true
+}
+
+// Rank returns the 0-based rank of member, counted from the highest score
+// when rev is set.
+func (z *ZSet) Rank(member string, rev bool) (int, bool) {
+	if z.IsListpack() {
+		i := z.find(member)
+		if i == -1 {
+			return 0, false
+		}
+		if rev {
+			return len(z.lp) - 1 - i, true
+		}
+		return i, true
+	}
+	score, ok := z.dict[member]
+	if !ok {
+		return 0, false
+	}
+	rank := z.zsl.rank(score, member)
+	if rev {
+		return z.Len() - rank, true
+	}
+	return rank - 1, true
+}
+
+// At returns the member with the given 0-based rank.
+func (z *ZSet) At(rank int) ZMember {
+	if z.IsListpack() {
+		return z.lp[rank]
+	}
+	node := z.zsl.byRank(rank + 1)
+	return ZMember{Member: node.member, Score: node.score}
+}
+
+// inRange returns a predicate telling whether a member matches a score or
+// lex query.
+func (q ZRangeQuery) inRange() func(score float64, member string) bool {
+	if q.By == "lex" {
+		return func(score float64, member string) bool { return q.Lex.aboveMin(member) && q.Lex.belowMax(member) }
+	}
+	return func(score float64, member string) bool { return q.Score.aboveMin(score) && q.Score.belowMax(score) }
+}
+
+// emptyQuery tells whether a score or lex query cannot select anything.
+func (q ZRangeQuery) emptyQuery() bool {
+	return (q.By == "lex" && q.Lex.empty()) || (q.By == "score" && q.Score.empty()) || q.Offset < 0 || q.Count == 0
+}
+
+// rangeListpack is rangeNodes for the listpack encoding, walking the members
+// in order as Redis does.
+func (z *ZSet) rangeListpack(q ZRangeQuery) []ZMember {
+	n := len(z.lp)
+	at := func(i int) ZMember {
+		if q.Rev {
+			return z.lp[n-1-i]
+		}
+		return z.lp[i]
+	}
+	members := []ZMember{}
+	if q.By == "rank" {
+		first, last, ok := listRange(n, q.Start, q.Stop)
+		for i := first; ok && i <= last; i++ {
+			members = append(members, at(i))
+		}
+		return members
+	}
+	if q.emptyQuery() {
+		return members
+	}
+	inRange := q.inRange()
+	skip := q.Offset
+	for i := 0; i < n && (q.Count < 0 || int64(len(members)) < q.Count); i++ {
+		m := at(i)
+		if !inRange(m.Score, m.Member) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		members = append(members, m)
+	}
+	return members
+}
+
+// rangeNodes returns the nodes selected by q in the order they are replied.
+func (z *ZSet) rangeNodes(q ZRangeQuery) []*skiplistNode {
+	zsl := z.zsl
+	nodes := []*skiplistNode{}
+	step := func(node *skiplistNode) *skiplistNode {
+		if q.Rev {
+			return node.backward
+		}
+		return node.level[0].forward
+	}
+	if q.By == "rank" {
+		first, last, ok := listRange(zsl.length, q.Start, q.Stop)
+		if !ok {
+			return nodes
+		}
+		node := zsl.byRank(first + 1)
+		if q.Rev {
+			node = zsl.byRank(zsl.length - first)
+		}
+		for i := first; i <= last && node != nil; i++ {
+			nodes = append(nodes, node)
+			node = step(node)
+		}
+		return nodes
+	}
+	if q.emptyQuery() {
+		return nodes
+	}
+	inRange := q.inRange()
+	var node *skiplistNode
+	if q.By == "lex" {
+		if q.Rev {
+			node = zsl.lastWhere(func(n *skiplistNode) bool { return q.Lex.belowMax(n.member) })
+		} else {
+			node = zsl.firstWhere(func(n *skiplistNode) bool { return !q.Lex.aboveMin(n.member) })
+		}
+	} else {
+		if q.Rev {
+			node = zsl.lastWhere(func(n *skiplistNode) bool { return q.Score.belowMax(n.score) })
+		} else {
+			node = zsl.firstWhere(func(n *skiplistNode) bool { return !q.Score.aboveMin(n.score) })
+		}
+	}
+	if node != nil && q.Offset > 0 {
+		rank := zsl.rank(node.score, node.member)
+		if q.Rev {
+			rank -= int(q.Offset)
+		} else {
+			rank += int(q.Offset)
+		}
+		node = nil
+		if rank >= 1 && rank <= zsl.length {
+			node = zsl.byRank(rank)
+		}
+	}
+	for node != nil && inRange(node.score, node.member) && (q.Count < 0 || int64(len(nodes)) < q.Count) {
+		nodes = append(nodes, node)
+		node = step(node)
+	}
+	return nodes
+}
+
+func (z *ZSet) Range(q ZRangeQuery) []ZMember {
+	if z.IsListpack() {
+		return z.rangeListpack(q)
+	}
+	nodes := z.rangeNodes(q)
+	members := make([]ZMember, len(nodes))
+	for i, node := range nodes {
+		members[i] = ZMember{Member: node.member, Score: node.score}
+	}
+	return members
+}
+
+// Count returns how many members fall within a score or lex query, using
+// ranks rather than walking the range.
+func (z *ZSet) Count(q ZRangeQuery) int {
+	q.Rev, q.Offset, q.Count = false, 0, -1
+	if z.IsListpack() {
+		return len(z.rangeListpack(q))
+	}
+	q.Count = 1
+	first := z.rangeNodes(q)
+	if len(first) == 0 {
+		return 0
+	}
+	q.Rev = true
+	last := z.rangeNodes(q)
+	return z.zsl.rank(last[0].score, last[0].member) - z.zsl.rank(first[0].score, first[0].member) + 1
+}
+
+// ZAddOptions carries the flags of a ZADD command.
+type ZAddOptions struct {
+	NX bool
+	XX bool
+	GT bool
+	LT bool
+	Incr bool
+}
+
+// getZSet returns the sorted set stored at key, nil when the key does not
+// exist. The caller must hold the key locks.
+func (store *Store) getZSet(key string) (*ZSet, error) {
+	data, ok := store.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	if data.dataType != "zset" {
+		return nil, ErrWrongType
+	}
+	return data.value.ZSet, nil
+}
+
+// putZSet stores zset at key, or deletes the key when it is empty. The caller
+// must hold the key locks.
+func (store *Store) putZSet(key string, zset *ZSet) {
+	if zset.Len() == 0 {
+		store.remove(key)
+		return
+	}
+	data, ok := store.lookup(key)
+	if !ok || data.dataType != "zset" {
+		data = storeData{dataType: "zset"}
+	}
+	data.value = item{ZSet: zset}
+	store.put(key, data)
+}
+
+// ZAdd adds or updates members under opts. It returns how many members were
+// added and how many existing ones changed score; with Incr, score is the
+// member's new score and ok is false when the flags prevented the update.
+func (store *Store) ZAdd(key string, members []ZMember, opts ZAddOptions) (added, changed int, score float64, ok bool, err error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	zset, err := store.getZSet(key)
+	if err != nil {
+		return 0, 0, 0, false, err
+	}
+	if zset == nil {
+		if opts.XX {
+			return 0, 0, 0, false, nil
+		}
+		zset = NewZSet()
+	}
+	for _, m := range members {
+		current, exists := zset.Score(m.Member)
+		newScore := m.Score
+		if exists {
+			if opts.NX {
+				continue
+			}
+			if opts.Incr {
+				newScore += current
+				if math.IsNaN(newScore) {
+					return 0, 0, 0, false, fmt.Errorf("ERR resulting score is not a number (NaN)")
+				}
+			}
+			if (opts.GT && newScore <= current) || (opts.LT && newScore >= current) {
+				continue
+			}
+			if newScore != current {
+				changed++
+			}
+		} else {
+			if opts.XX {
+				continue
+			}
+			added++
+		}
+		zset.Set(m.Member, newScore)
+		score, ok = newScore, true
+	}
+	store.putZSet(key, zset)
+	return added, changed, score, ok, nil
+}
+
+func (store *Store) ZRem(key string, members []string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	zset, err := store.getZSet(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+	removed := 0
+	for _, member := range members {
+		if zset.Remove(member) {
+			removed++
+		}
+	}
+	store.putZSet(key, zset)
+	return removed, nil
+}
+
+// ZScore returns the score of each member, nil for missing ones.
+func (store *Store) ZScore(key string, members []string) ([]*float64, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	zset, err := store.getZSet(key)
+	if err != nil {
+		return nil, err
+	}
+	scores := make([]*float64, len(members))
+	if zset == nil {
+		return scores, nil
+	}
+	for i, member := range members {
+		if score, ok := zset.Score(member); ok {
+			scores[i] = &score
+		}
+	}
+	return scores, nil
+}
+
+func (store *Store) ZCard(key string) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	zset, err := store.getZSet(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+	return zset.Len(), nil
+}
+
+// ZCount counts the members matched by a score or lex query.
+func (store *Store) ZCount(key string, q ZRangeQuery) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	zset, err := store.getZSet(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+	return zset.Count(q), nil
+}
+
+// ZRank returns the rank and score of member. ok is false when it does not
+// exist.
+func (store *Store) ZRank(key, member string, rev bool) (int, float64, bool, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	zset, err := store.getZSet(key)
+	if err != nil || zset == nil {
+		return 0, 0, false, err
+	}
+	rank, ok := zset.Rank(member, rev)
+	score, _ := zset.Score(member)
+	return rank, score, ok, nil
+}
+
+func (store *Store) ZRange(key string, q ZRangeQuery) ([]ZMember, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	zset, err := store.getZSet(key)
+	if err != nil || zset == nil {
+		return []ZMember{}, err
+	}
+	return zset.Range(q), nil
+}
+
+// ZRangeStore stores the members of src selected by q into dst and returns
+// how many there are.
+func (store *Store) ZRangeStore(dst, src string, q ZRangeQuery) (int, error) {
+	store.lock(dst, src)
+	defer store.unlock(dst, src)
+	zset, err := store.getZSet(src)
+	if err != nil {
+		return 0, err
+	}
+	result := NewZSet()
+	if zset != nil {
+		for _, m := range zset.Range(q) {
+			result.Set(m.Member, m.Score)
+		}
+	}
+	store.remove(dst)
+	store.putZSet(dst, result)
+	return result.Len(), nil
+}
+
+// ZPop removes and returns up to count members with the lowest scores, or
+// the highest when max is set.
+func (store *Store) ZPop(key string, count int, max bool) ([]ZMember, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	zset, err := store.getZSet(key)
+	if err != nil || zset == nil {
+		return []ZMember{}, err
+	}
+	// A stop of count-1 = -1 would range to the last member.
+	if count == 0 {
+		return []ZMember{}, nil
+	}
+	popped := zset.Range(ZRangeQuery{By: "rank", Start: 0, Stop: int64(count) - 1, Rev: max})
+	for _, m := range popped {
+		zset.Remove(m.Member)
+	}
+	store.putZSet(key, zset)
+	return popped, nil
+}
+
+// ZRandMember picks count members of the sorted set at key: distinct ones
+// when count is positive, possibly repeated ones when it is negative.
+func (store *Store) ZRandMember(key string, count int64) ([]ZMember, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	zset, err := store.getZSet(key)
+	if err != nil || zset == nil {
+		return []ZMember{}, err
+	}
+	length := zset.Len()
+	picked := []ZMember{}
+	if count < 0 {
+		for i := int64(0); i < -count; i++ {
+			picked = append(picked, zset.At(rand.Intn(length)))
+		}
+		return picked, nil
+	}
+	if count > int64(length) {
+		count = int64(length)
+	}
+	for _, i := range randomIndexes(length, int(count)) {
+		picked = append(picked, zset.At(i))
+	}
+	return picked, nil
+}
+
+// ZRemRange removes the members selected by a rank, score or lex query.
+func (store *Store) ZRemRange(key string, q ZRangeQuery) (int, error) {
+	store.lock(key)
+	defer store.unlock(key)
+	store.modified(key)
+	zset, err := store.getZSet(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+	removed := zset.Range(q)
+	for _, m := range removed {
+		zset.Remove(m.Member)
+	}
+	store.putZSet(key, zset)
+	return len(removed), nil
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestZPopZeroCount(t *testing.T) {
+	store := newStore()
+	members := []ZMember{{Member: "a", Score: 1}, {Member: "b", Score: 2}}
+	if _, _, _, _, err := store.ZAdd("z", members, ZAddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, max := range []bool{false, true} {
+		popped, err := store.ZPop("z", 0, max)
+		if err != nil || len(popped) != 0 {
+			t.Fatalf("ZPop with count 0, max %v = %v, %v", max, popped, err)
+		}
+	}
+	if card, _ := store.ZCard("z"); card != 2 {
+		t.Fatalf("ZCARD after popping 0 members = %d, want 2", card)
+	}
+	store.Set("string", "value", 0)
+	if _, err := store.ZPop("string", 0, false); err != ErrWrongType {
+		t.Fatalf("ZPop with count 0 of a string = %v, want %v", err, ErrWrongType)
+	}
+}
+
+func TestZRandMember(t *testing.T) {
+	for _, n := range []int{10, 500} {
+		store := newStore()
+		members := make([]ZMember, n)
+		for i := range members {
+			members[i] = ZMember{Member: "m" + strconv.Itoa(i), Score: float64(i)}
+		}
+		store.ZAdd("z", members, ZAddOptions{})
+		picked, err := store.ZRandMember("z", 7)
+		if err != nil || len(picked) != 7 {
+			t.Fatalf("%d members: ZRANDMEMBER 7 = %v, %v", n, picked, err)
+		}
+		seen := map[string]bool{}
+		for _, m := range picked {
+			if seen[m.Member] || m.Member != "m"+strconv.Itoa(int(m.Score)) {
+				t.Fatalf("%d members: ZRANDMEMBER 7 returned %v", n, picked)
+			}
+			seen[m.Member] = true
+		}
+		if picked, _ := store.ZRandMember("z", int64(n)+3); len(picked) != n {
+			t.Fatalf("%d members: ZRANDMEMBER past the size returned %d", n, len(picked))
+		}
+		if picked, _ := store.ZRandMember("z", -20); len(picked) != 20 {
+			t.Fatalf("%d members: ZRANDMEMBER -20 returned %d", n, len(picked))
+		}
+	}
+}
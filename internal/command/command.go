@@ -1,9 +1,16 @@
 package command
 
+import (
+	"fmt"
+	"strings"
+)
 
+// Command is a command with its name and arguments lowercased, as handlers
+// compare them. raw keeps them as the client sent them.
 type Command struct {
 	name string
 	args []string
+	raw []string
 }
 
 const (
@@ -11,17 +18,21 @@ const (
 )
 
 func NewCommand(respArr []string) (*Command, error) {
-	cmd := &Command{}
-	cmd.name = respArr[0]
-	cmd.args = respArr[1:]
+	if len(respArr) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	cmd := &Command{raw: respArr}
+	cmd.name = strings.ToLower(respArr[0])
+	for _, arg := range respArr[1:] {
+		cmd.args = append(cmd.args, strings.ToLower(arg))
+	}
 	return cmd, nil
 }
 
+// CmdToSlice returns the command as the client sent it, for propagation and
+// replication offsets.
 func (c *Command) CmdToSlice() []string {
-	cmd := make([]string, 0)
-	cmd = append(cmd, string(c.name))
-	cmd = append(cmd, c.args...)
-	return cmd
+	return append([]string{}, c.raw...)
 }
 
 func (c *Command) GetArgs() []string {
@@ -34,4 +45,9 @@ func (c *Command) GetName() string {
 
 func (c *Command) GetArg(index int) string {
 	return c.args[index]
+}
+
+// GetRawArg returns an argument as the client sent it, for binary values.
+func (c *Command) GetRawArg(index int) string {
+	return c.raw[index+1]
 }
\ No newline at end of file
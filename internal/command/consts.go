@@ -13,7 +13,133 @@ const (
 	CONFIG = "config"
 	KEYS = "keys"
 	TYPE = "type"
+	OBJECT = "object"
+	TOUCH = "touch"
+	MEMORY = "memory"
+	DUMP = "dump"
+	RESTORE = "restore"
 	XADD = "xadd"
 	XRANGE = "xrange"
+	XREVRANGE = "xrevrange"
 	XREAD = "xread"
+	XLEN = "xlen"
+	XDEL = "xdel"
+	XTRIM = "xtrim"
+	XSETID = "xsetid"
+	XGROUP = "xgroup"
+	XREADGROUP = "xreadgroup"
+	XACK = "xack"
+	XPENDING = "xpending"
+	XCLAIM = "xclaim"
+	XAUTOCLAIM = "xautoclaim"
+	XINFO = "xinfo"
+	XCONFIG = "xconfig"
+	SORT = "sort"
+	SORT_RO = "sort_ro"
+	LCS = "lcs"
+	INCR = "incr"
+	DECR = "decr"
+	INCRBY = "incrby"
+	DECRBY = "decrby"
+	INCRBYFLOAT = "incrbyfloat"
+	APPEND = "append"
+	STRLEN = "strlen"
+	GETRANGE = "getrange"
+	SETRANGE = "setrange"
+	MGET = "mget"
+	MSET = "mset"
+	MSETNX = "msetnx"
+	GETSET = "getset"
+	GETDEL = "getdel"
+	GETEX = "getex"
+	SETNX = "setnx"
+	SETEX = "setex"
+	PSETEX = "psetex"
+	SETBIT = "setbit"
+	GETBIT = "getbit"
+	BITCOUNT = "bitcount"
+	BITPOS = "bitpos"
+	BITOP = "bitop"
+	BITFIELD = "bitfield"
+	BITFIELD_RO = "bitfield_ro"
+	PFADD = "pfadd"
+	PFCOUNT = "pfcount"
+	PFMERGE = "pfmerge"
+	LPUSH = "lpush"
+	RPUSH = "rpush"
+	LPUSHX = "lpushx"
+	RPUSHX = "rpushx"
+	LPOP = "lpop"
+	RPOP = "rpop"
+	LLEN = "llen"
+	LRANGE = "lrange"
+	LINDEX = "lindex"
+	LSET = "lset"
+	LREM = "lrem"
+	LTRIM = "ltrim"
+	LINSERT = "linsert"
+	LPOS = "lpos"
+	LMOVE = "lmove"
+	RPOPLPUSH = "rpoplpush"
+	LMPOP = "lmpop"
+	BLPOP = "blpop"
+	BRPOP = "brpop"
+	BLMOVE = "blmove"
+	BRPOPLPUSH = "brpoplpush"
+	BLMPOP = "blmpop"
+	CLIENT = "client"
+	HSET = "hset"
+	HSETNX = "hsetnx"
+	HGET = "hget"
+	HMGET = "hmget"
+	HDEL = "hdel"
+	HGETALL = "hgetall"
+	HEXISTS = "hexists"
+	HKEYS = "hkeys"
+	HVALS = "hvals"
+	HLEN = "hlen"
+	HSTRLEN = "hstrlen"
+	HINCRBY = "hincrby"
+	HINCRBYFLOAT = "hincrbyfloat"
+	HRANDFIELD = "hrandfield"
+	SADD = "sadd"
+	SREM = "srem"
+	SMEMBERS = "smembers"
+	SISMEMBER = "sismember"
+	SMISMEMBER = "smismember"
+	SCARD = "scard"
+	SPOP = "spop"
+	SRANDMEMBER = "srandmember"
+	SMOVE = "smove"
+	SINTER = "sinter"
+	SUNION = "sunion"
+	SDIFF = "sdiff"
+	SINTERSTORE = "sinterstore"
+	SUNIONSTORE = "sunionstore"
+	SDIFFSTORE = "sdiffstore"
+	SINTERCARD = "sintercard"
+	ZADD = "zadd"
+	ZINCRBY = "zincrby"
+	ZREM = "zrem"
+	ZSCORE = "zscore"
+	ZMSCORE = "zmscore"
+	ZCARD = "zcard"
+	ZCOUNT = "zcount"
+	ZLEXCOUNT = "zlexcount"
+	ZRANK = "zrank"
+	ZREVRANK = "zrevrank"
+	ZRANGE = "zrange"
+	ZRANGESTORE = "zrangestore"
+	ZPOPMIN = "zpopmin"
+	ZPOPMAX = "zpopmax"
+	ZRANDMEMBER = "zrandmember"
+	ZREMRANGEBYRANK = "zremrangebyrank"
+	ZREMRANGEBYSCORE = "zremrangebyscore"
+	ZREMRANGEBYLEX = "zremrangebylex"
+	GEOADD = "geoadd"
+	GEOPOS = "geopos"
+	GEODIST = "geodist"
+	GEOHASH = "geohash"
+	GEOSEARCH = "geosearch"
+	GEOSEARCHSTORE = "geosearchstore"
 )
@@ -0,0 +1,24 @@
+package command
+
+// denyOOM lists the commands that may grow the dataset, which are refused
+// while used memory is over maxmemory and nothing can be evicted.
+var denyOOM = map[string]bool{
+	SET: true, SETNX: true, SETEX: true, PSETEX: true, APPEND: true, SETRANGE: true,
+	INCR: true, DECR: true, INCRBY: true, DECRBY: true, INCRBYFLOAT: true,
+	MSET: true, MSETNX: true, GETSET: true, SETBIT: true, BITOP: true, BITFIELD: true,
+	PFADD: true, PFMERGE: true,
+	LPUSH: true, RPUSH: true, LPUSHX: true, RPUSHX: true, LINSERT: true, LSET: true,
+	LMOVE: true, RPOPLPUSH: true, BLMOVE: true, BRPOPLPUSH: true,
+	HSET: true, HSETNX: true, HINCRBY: true, HINCRBYFLOAT: true,
+	SADD: true, SMOVE: true, SINTERSTORE: true, SUNIONSTORE: true, SDIFFSTORE: true,
+	ZADD: true, ZINCRBY: true, ZRANGESTORE: true,
+	GEOADD: true, GEOSEARCHSTORE: true,
+	XADD: true, XGROUP: true, XSETID: true,
+	SORT: true, RESTORE: true,
+}
+
+// DenyOOM tells whether the command named name is refused when the server
+// is out of memory.
+func DenyOOM(name string) bool {
+	return denyOOM[name]
+}
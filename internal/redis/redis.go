@@ -71,7 +71,16 @@ func NewNode() Node {
 	host := flag.String("replicaof", "", "Host of master Node")
 	dir := flag.String("dir", "", "Directory to store RDB file")
 	fileName := flag.String("dbfilename", "", "Name of RDB file")
+	streamRetention := flag.Int64("stream-retention-ms", 0, "Milliseconds of entries streams keep by default, 0 keeping all")
+	maxMemory := flag.String("maxmemory", "0", "Bytes the keys may take before eviction, 0 for no limit")
+	maxMemoryPolicy := flag.String("maxmemory-policy", "noeviction", "How keys are evicted past maxmemory")
 	flag.Parse()
+	for name, value := range map[string]string{"maxmemory": *maxMemory, "maxmemory-policy": *maxMemoryPolicy} {
+		if err := cache.SetMemoryConfig(name, value); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	}
 	rdbFile := RDBfile{
 		fileName: *fileName,
 		dir: *dir,
@@ -98,11 +107,32 @@ func NewNode() Node {
 	} else {
 		node = newMaster(l, net.IPv4(0, 0, 0, 0).String(), *port, rdbFile)
 	}
+	if node.IsMaster() {
+		// Replicas trim through the XTRIM the master sends them.
+		node.GetCache().StartStreamRetention(*streamRetention, func(key string, minID cache.StreamID) {
+			for _, slave := range node.GetSlaveConn() {
+				slave.Write([]byte(resp.ToRESPArray([]string{"XTRIM", key, "MINID", minID.String()})))
+			}
+		})
+	}
 	if rdbFile.fileName != "" || rdbFile.dir != "" {
 		data := resp.LoadValuesFromRDBFile(rdbFile.dir + "/" + rdbFile.fileName)
-		cache := node.GetCache()
+		store := node.GetCache()
 		for _, value := range data {
-			cache.Set(value.Key, value.Value, value.ExpireTime)
+			switch value.Type {
+			case "hash":
+				store.HSet(value.Key, value.Hash, false)
+				if value.ExpireTime > 0 {
+					store.PExpireAt(value.Key, value.ExpireTime)
+				}
+			case "stream":
+				store.RestoreStream(value.Key, *value.Stream)
+				if value.ExpireTime > 0 {
+					store.PExpireAt(value.Key, value.ExpireTime)
+				}
+			default:
+				store.SetWithOptions(value.Key, value.Value, cache.SetOptions{ExpireAt: value.ExpireTime})
+			}
 		}
 	}
 	return node
@@ -0,0 +1,105 @@
+package resp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+)
+
+// dumpRDBVersion is the RDB version DUMP payloads are stamped with, that of
+// Redis 7.2. Payloads up to the newest version RDB files are read in are
+// restored.
+const dumpRDBVersion = 11
+
+var ErrDumpPayload = fmt.Errorf("ERR DUMP payload version or checksum are wrong")
+
+// crc64Table is for the CRC-64 variant Redis checksums DUMP payloads and RDB
+// files with: the Jones polynomial, reflected, with no initial or final xor.
+var crc64Table = func() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		crc := uint64(i)
+		for j := 0; j < 8; j++ {
+			if crc&1 == 1 {
+				crc = crc>>1 ^ 0x95ac9329ac4bc9b5
+			} else {
+				crc >>= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+func crc64(data []byte) uint64 {
+	crc := uint64(0)
+	for _, b := range data {
+		crc = crc64Table[byte(crc)^b] ^ crc>>8
+	}
+	return crc
+}
+
+func writeRDBLength(buf *bytes.Buffer, length int) {
+	switch {
+	case length < 1<<6:
+		buf.WriteByte(byte(length))
+	case length < 1<<14:
+		buf.WriteByte(byte(length>>8) | 0b01000000)
+		buf.WriteByte(byte(length))
+	default:
+		buf.WriteByte(0x80)
+		binary.Write(buf, binary.BigEndian, uint32(length))
+	}
+}
+
+func writeRDBString(buf *bytes.Buffer, str string) {
+	writeRDBLength(buf, len(str))
+	buf.WriteString(str)
+}
+
+// EncodeDump serializes v the way DUMP does: the value as an RDB file holds
+// it, then the RDB version and the checksum of all that, both little endian.
+func EncodeDump(v cache.Value) string {
+	buf := &bytes.Buffer{}
+	switch v.Type {
+	case "string":
+		buf.WriteByte(RDB_TYPE_STRING)
+		writeRDBString(buf, v.Value)
+	case "hash":
+		buf.WriteByte(RDB_TYPE_HASH)
+		writeRDBLength(buf, len(v.Hash)/2)
+		for _, str := range v.Hash {
+			writeRDBString(buf, str)
+		}
+	}
+	binary.Write(buf, binary.LittleEndian, uint16(dumpRDBVersion))
+	binary.Write(buf, binary.LittleEndian, crc64(buf.Bytes()))
+	return buf.String()
+}
+
+// DecodeDump reads back a payload of DUMP, from this server or from Redis,
+// after checking its version and checksum.
+func DecodeDump(payload string) (cache.Value, error) {
+	if len(payload) < 10 {
+		return cache.Value{}, ErrDumpPayload
+	}
+	body := []byte(payload[:len(payload)-8])
+	version := binary.LittleEndian.Uint16(body[len(body)-2:])
+	if version > 12 || binary.LittleEndian.Uint64([]byte(payload[len(payload)-8:])) != crc64(body) {
+		return cache.Value{}, ErrDumpPayload
+	}
+	rdb := RDB{file: bytes.NewReader(body[:len(body)-2])}
+	valueType, err := rdb.ReadByte()
+	if err != nil {
+		return cache.Value{}, fmt.Errorf("ERR Bad data format")
+	}
+	data := RDBData{}
+	rdb.readValue(valueType, &data)
+	if rdb.err != nil {
+		return cache.Value{}, fmt.Errorf("ERR Bad data format")
+	}
+	return cache.Value{Type: data.Type, Value: data.Value, Hash: data.Hash, Stream: data.Stream}, nil
+}
+
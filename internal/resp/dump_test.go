@@ -0,0 +1,150 @@
+package resp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+)
+
+// lzfCompress is a minimal LZF compressor for building test payloads: it
+// only back-references the previous byte, which covers the long runs of
+// zero registers in a dense HyperLogLog.
+func lzfCompress(data []byte) []byte {
+	out := []byte{}
+	literal := []byte{}
+	flush := func() {
+		for len(literal) > 0 {
+			n := min(len(literal), 32)
+			out = append(out, byte(n-1))
+			out = append(out, literal[:n]...)
+			literal = literal[n:]
+		}
+	}
+	for i := 0; i < len(data); {
+		run := 0
+		for i > 0 && i+run < len(data) && data[i+run] == data[i-1] && run < 264 {
+			run++
+		}
+		if run < 3 {
+			literal = append(literal, data[i])
+			i++
+			continue
+		}
+		flush()
+		n := run - 2
+		if n < 7 {
+			out = append(out, byte(n<<5), 0)
+		} else {
+			out = append(out, 7<<5, byte(n-7), 0)
+		}
+		i += run
+	}
+	flush()
+	return out
+}
+
+func dumpPayload(body []byte) string {
+	buf := bytes.NewBuffer(body)
+	binary.Write(buf, binary.LittleEndian, uint16(11))
+	binary.Write(buf, binary.LittleEndian, crc64(buf.Bytes()))
+	return buf.String()
+}
+
+// The payload Redis documents for DUMP of the integer 10.
+func TestDecodeDumpRedisPayload(t *testing.T) {
+	v, err := DecodeDump("\x00\xc0\n\t\x00\xbem\x06\x89Z(\x00\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Type != "string" || v.Value != "10" {
+		t.Fatalf("got %+v, want the string 10", v)
+	}
+}
+
+func TestDecodeDumpRejectsBadChecksum(t *testing.T) {
+	if _, err := DecodeDump("\x00\xc0\n\t\x00\xbem\x06\x89Z(\x00\x0b"); err != ErrDumpPayload {
+		t.Fatalf("got %v, want %v", err, ErrDumpPayload)
+	}
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	for _, v := range []cache.Value{
+		{Type: "string", Value: "binary\r\n\x00\xff"},
+		{Type: "hash", Hash: []string{"name", "ada", "age", "36"}},
+	} {
+		got, err := DecodeDump(EncodeDump(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Type != v.Type || got.Value != v.Value || len(got.Hash) != len(v.Hash) {
+			t.Fatalf("got %+v, want %+v", got, v)
+		}
+	}
+}
+
+func TestLZFDecompress(t *testing.T) {
+	data := append([]byte("HYLL"), make([]byte, 1000)...)
+	data = append(data, "tail"...)
+	got, err := lzfDecompress(lzfCompress(data), len(data))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("round trip failed: %v", err)
+	}
+	if _, err := lzfDecompress([]byte{0xe0, 0x05, 0x00}, 14); err == nil {
+		t.Fatal("a reference before the start of the output was accepted")
+	}
+}
+
+// HyperLogLogs as Redis lays them out, each holding one register at 1: a
+// sparse one stored plainly, and a dense one LZF compressed as Redis
+// compresses strings in RDB files and DUMP payloads.
+func TestRestoreHyperLogLog(t *testing.T) {
+	header := []byte{'H', 'Y', 'L', 'L', 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x80}
+	sparse := append(append([]byte{}, header...), 0x40, 0x63, 0x80, 0x7f, 0x9a)
+	sparsePayload := dumpPayload(append([]byte{RDB_TYPE_STRING, byte(len(sparse))}, sparse...))
+
+	dense := append([]byte{}, header...)
+	dense[4] = 0
+	dense = append(dense, make([]byte, 12288)...)
+	dense[16] = 1
+	compressed := lzfCompress(dense)
+	body := &bytes.Buffer{}
+	body.WriteByte(RDB_TYPE_STRING)
+	body.WriteByte(0xc0 | RDB_ENC_LZF)
+	writeRDBLength(body, len(compressed))
+	writeRDBLength(body, len(dense))
+	body.Write(compressed)
+	densePayload := dumpPayload(body.Bytes())
+
+	c := cache.NewCache()
+	for key, payload := range map[string]string{"sparse": sparsePayload, "dense": densePayload} {
+		v, err := DecodeDump(payload)
+		if err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		if err := c.Restore(key, v, cache.RestoreOptions{IdleTime: -1, Freq: -1}); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		count, err := c.PFCount([]string{key})
+		if err != nil || count != 1 {
+			t.Fatalf("%s: PFCOUNT = %d, %v, want 1", key, count, err)
+		}
+		if _, err := c.PFAdd(key, []string{"a", "b", "c"}); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		dumped, _, err := c.Dump(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := DecodeDump(EncodeDump(dumped))
+		if err != nil || again.Value != dumped.Value {
+			t.Fatalf("%s: dump did not round trip: %v", key, err)
+		}
+	}
+	// Register 100 of the sparse one, register 0 of the dense one, a, b and c.
+	count, err := c.PFCount([]string{"sparse", "dense"})
+	if err != nil || count != 5 {
+		t.Fatalf("PFCOUNT of the union = %d, %v, want 5", count, err)
+	}
+}
@@ -0,0 +1,70 @@
+package resp
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+// parseListpack decodes the entries of a listpack, the compact encoding Redis
+// uses for small collections in RDB files: a 6 byte header, the entries, each
+// followed by its length for backwards traversal, and a 0xFF terminator.
+func parseListpack(blob []byte) []string {
+	entries := []string{}
+	p := 6
+	for p < len(blob) && blob[p] != 0xFF {
+		entry, size := listpackEntry(blob[p:])
+		if size == 0 {
+			break
+		}
+		entries = append(entries, entry)
+		p += size + listpackBacklenSize(size)
+	}
+	return entries
+}
+
+// listpackEntry decodes the entry at the start of b and returns it along
+// with the size of its encoding and data.
+func listpackEntry(b []byte) (string, int) {
+	signed := func(v uint64, bits uint) string {
+		shift := 64 - bits
+		return strconv.FormatInt(int64(v<<shift)>>shift, 10)
+	}
+	switch {
+	case b[0]&0x80 == 0:
+		return strconv.Itoa(int(b[0] & 0x7f)), 1
+	case b[0]&0xc0 == 0x80:
+		n := int(b[0] & 0x3f)
+		return string(b[1 : 1+n]), 1 + n
+	case b[0]&0xe0 == 0xc0:
+		return signed(uint64(b[0]&0x1f)<<8|uint64(b[1]), 13), 2
+	case b[0]&0xf0 == 0xe0:
+		n := int(b[0]&0x0f)<<8 | int(b[1])
+		return string(b[2 : 2+n]), 2 + n
+	case b[0] == 0xf0:
+		n := int(binary.LittleEndian.Uint32(b[1:5]))
+		return string(b[5 : 5+n]), 5 + n
+	case b[0] == 0xf1:
+		return signed(uint64(binary.LittleEndian.Uint16(b[1:3])), 16), 3
+	case b[0] == 0xf2:
+		return signed(uint64(b[1])|uint64(b[2])<<8|uint64(b[3])<<16, 24), 4
+	case b[0] == 0xf3:
+		return signed(uint64(binary.LittleEndian.Uint32(b[1:5])), 32), 5
+	case b[0] == 0xf4:
+		return strconv.FormatInt(int64(binary.LittleEndian.Uint64(b[1:9])), 10), 9
+	}
+	return "", 0
+}
+
+func listpackBacklenSize(size int) int {
+	switch {
+	case size < 1<<7:
+		return 1
+	case size < 1<<14:
+		return 2
+	case size < 1<<21:
+		return 3
+	case size < 1<<28:
+		return 4
+	}
+	return 5
+}
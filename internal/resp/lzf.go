@@ -0,0 +1,50 @@
+package resp
+
+import "fmt"
+
+var errLZFCorrupt = fmt.Errorf("corrupt LZF compressed string")
+
+// lzfDecompress expands LZF data, the compression Redis applies to strings in
+// RDB files, into exactly size bytes. Each control byte starts either a run of
+// up to 32 literal bytes or a back-reference copying bytes already output.
+func lzfDecompress(in []byte, size int) ([]byte, error) {
+	out := make([]byte, 0, size)
+	for ip := 0; ip < len(in); {
+		ctrl := int(in[ip])
+		ip++
+		if ctrl < 1<<5 {
+			n := ctrl + 1
+			if ip+n > len(in) || len(out)+n > size {
+				return nil, errLZFCorrupt
+			}
+			out = append(out, in[ip:ip+n]...)
+			ip += n
+			continue
+		}
+		n := ctrl >> 5
+		if n == 7 {
+			if ip >= len(in) {
+				return nil, errLZFCorrupt
+			}
+			n += int(in[ip])
+			ip++
+		}
+		if ip >= len(in) {
+			return nil, errLZFCorrupt
+		}
+		ref := len(out) - (ctrl&0x1f)<<8 - int(in[ip]) - 1
+		ip++
+		n += 2
+		if ref < 0 || len(out)+n > size {
+			return nil, errLZFCorrupt
+		}
+		// The reference may overlap what it produces, so copy byte by byte.
+		for i := 0; i < n; i++ {
+			out = append(out, out[ref+i])
+		}
+	}
+	if len(out) != size {
+		return nil, errLZFCorrupt
+	}
+	return out, nil
+}
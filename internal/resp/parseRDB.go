@@ -2,23 +2,33 @@ package resp
 
 import (
 	"encoding/binary"
+	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
 )
 
 type RDBData struct {
 	Key string
+	Type string
 	Value string
+	Hash []string
+	Stream *cache.StreamState
 	ExpireTime int64
 }
 
 type RDB struct {
-	file *os.File
+	file io.Reader
 	selectedDB int
 	hashTableSize int
 	expireHashTableSize int
 	data []RDBData
+	// err is set once the file turns out to be corrupt; nothing read
+	// after it can be trusted.
+	err error
 }
 
 const (
@@ -30,6 +40,22 @@ const (
 	OP_AUX = 0xFA
 )
 
+const (
+	RDB_TYPE_STRING = 0
+	RDB_TYPE_HASH = 4
+	RDB_TYPE_STREAM_LISTPACKS = 15
+	RDB_TYPE_HASH_LISTPACK = 16
+	RDB_TYPE_STREAM_LISTPACKS_2 = 19
+	RDB_TYPE_STREAM_LISTPACKS_3 = 21
+)
+
+const (
+	RDB_ENC_INT8 = 0
+	RDB_ENC_INT16 = 1
+	RDB_ENC_INT32 = 2
+	RDB_ENC_LZF = 3
+)
+
 func LoadValuesFromRDBFile(filePath string) []RDBData {
 	rdb := RDB{
 		data: []RDBData{},
@@ -51,8 +77,15 @@ func LoadValuesFromRDBFile(filePath string) []RDBData {
 		case OP_AUX:
 			rdb.Aux()
 		}
+		if rdb.err != nil {
+			break main
+		}
 	}
 	rdb.CloseRDBFile()
+	if rdb.err != nil {
+		fmt.Println("Failed to load RDB file:", rdb.err.Error())
+		return nil
+	}
 	return rdb.data
 }
 
@@ -65,6 +98,9 @@ func (rdb *RDB) OpenRDBFile(filePath string) {
 }
 
 func (rdb *RDB) VerifyRDBFile() bool {
+	if rdb.file == nil {
+		return false
+	}
 	sanityCheckByte := make([]byte, 9)
 	_, err := rdb.file.Read(sanityCheckByte)
 	if err != nil {
@@ -77,7 +113,7 @@ func (rdb *RDB) VerifyRDBFile() bool {
 	if err != nil {
 		return false
 	}
-	if version < 2 || version > 7 {
+	if version < 2 || version > 12 {
 		return false
 	}
 	return true
@@ -105,30 +141,51 @@ func (rdb *RDB) ReadByte() (byte, error) {
 
 func (rdb *RDB) ReadKeyVal() {
 	expireTime := int64(0)
-	switch rdb.ReadOpCode() {
+	valueType := rdb.ReadOpCode()
+	switch valueType {
 	case OP_EXPIRETIME_MS:
 		expireTimeByte := make([]byte, 8)
 		rdb.file.Read(expireTimeByte)
-		expireTime = int64(binary.LittleEndian.Uint32(expireTimeByte)) * 1000
-		_, _ = rdb.ReadByte()
+		expireTime = int64(binary.LittleEndian.Uint64(expireTimeByte))
+		valueType, _ = rdb.ReadByte()
 	case OP_EXPIRETIME:
 		expireTimeByte := make([]byte, 4)
 		rdb.file.Read(expireTimeByte)
 		expireTime = int64(binary.LittleEndian.Uint32(expireTimeByte)) * 1000
-		_, _ = rdb.ReadByte()
+		valueType, _ = rdb.ReadByte()
+	}
+	data := RDBData{Key: rdb.ReadRDBString(), ExpireTime: expireTime}
+	rdb.readValue(valueType, &data)
+	if rdb.err != nil {
+		return
 	}
 	if expireTime > 0 && expireTime < time.Now().UnixMilli() {
-		rdb.DiscardKeyValue()
 		return
 	}
-	key := rdb.ReadRDBString()
-	value := rdb.ReadRDBString()
-	rdb.data = append(rdb.data, RDBData{key, value, expireTime})
+	rdb.data = append(rdb.data, data)
 }
 
-func (rdb *RDB) DiscardKeyValue() {
-	_ = rdb.ReadRDBString()
-	_ = rdb.ReadRDBString()
+// readValue reads a value of valueType into data.
+func (rdb *RDB) readValue(valueType byte, data *RDBData) {
+	switch valueType {
+	case RDB_TYPE_STRING:
+		data.Type = "string"
+		data.Value = rdb.ReadRDBString()
+	case RDB_TYPE_HASH:
+		data.Type = "hash"
+		length := rdb.ReadLengthEncoded()
+		for i := 0; i < length*2; i++ {
+			data.Hash = append(data.Hash, rdb.ReadRDBString())
+		}
+	case RDB_TYPE_HASH_LISTPACK:
+		data.Type = "hash"
+		data.Hash = parseListpack([]byte(rdb.ReadRDBString()))
+	case RDB_TYPE_STREAM_LISTPACKS, RDB_TYPE_STREAM_LISTPACKS_2, RDB_TYPE_STREAM_LISTPACKS_3:
+		data.Type = "stream"
+		data.Stream = rdb.readStream(valueType)
+	default:
+		rdb.err = fmt.Errorf("unsupported value type %d", valueType)
+	}
 }
 
 func (rdb *RDB) ResizeDB() {
@@ -136,7 +193,7 @@ func (rdb *RDB) ResizeDB() {
 	expireHashTableSize := rdb.ReadLengthEncoded()
 	rdb.hashTableSize = hashTableSize
 	rdb.expireHashTableSize = expireHashTableSize
-	for i := 0; i < hashTableSize; i++ {
+	for i := 0; i < hashTableSize && rdb.err == nil; i++ {
 		rdb.ReadKeyVal()
 	}
 }
@@ -148,31 +205,83 @@ func (rdb *RDB) Aux() {
 }
 
 func (rdb *RDB) CloseRDBFile() {
-	rdb.file.Close()
+	if file, ok := rdb.file.(io.Closer); ok {
+		file.Close()
+	}
 }
 
 func (rdb *RDB) ReadRDBString() string {
-	length := rdb.ReadLengthEncoded()
+	length, encoded := rdb.readLength()
+	if encoded {
+		return rdb.readEncodedString(length)
+	}
 	str := make([]byte, length)
 	rdb.file.Read(str)
 	return string(str)
 }
 
+// readEncodedString reads a string stored as an integer or LZF compressed.
+func (rdb *RDB) readEncodedString(encoding int) string {
+	var buf []byte
+	switch encoding {
+	case RDB_ENC_INT8:
+		buf = make([]byte, 1)
+		rdb.file.Read(buf)
+		return strconv.Itoa(int(int8(buf[0])))
+	case RDB_ENC_INT16:
+		buf = make([]byte, 2)
+		rdb.file.Read(buf)
+		return strconv.Itoa(int(int16(binary.LittleEndian.Uint16(buf))))
+	case RDB_ENC_INT32:
+		buf = make([]byte, 4)
+		rdb.file.Read(buf)
+		return strconv.Itoa(int(int32(binary.LittleEndian.Uint32(buf))))
+	case RDB_ENC_LZF:
+		compressedLength := rdb.ReadLengthEncoded()
+		length := rdb.ReadLengthEncoded()
+		buf = make([]byte, compressedLength)
+		if _, err := io.ReadFull(rdb.file, buf); err != nil {
+			rdb.err = err
+			return ""
+		}
+		str, err := lzfDecompress(buf, length)
+		if err != nil {
+			rdb.err = err
+			return ""
+		}
+		return string(str)
+	}
+	rdb.err = fmt.Errorf("unknown string encoding %d", encoding)
+	return ""
+}
+
 func (rdb *RDB) ReadLengthEncoded() int {
+	length, _ := rdb.readLength()
+	return length
+}
+
+// readLength reads a length-encoded value. encoded is set when the value is
+// the format of a specially encoded string rather than a length.
+func (rdb *RDB) readLength() (int, bool) {
 	lengthByte := make([]byte, 1)
 	rdb.file.Read(lengthByte)
 	switch lengthByte[0] >> 6 {
 	case 0b00:
-		return int(lengthByte[0] & 0b00111111)
+		return int(lengthByte[0] & 0b00111111), false
 	case 0b01:
 		rest := make([]byte, 1)
 		rdb.file.Read(rest)
-		return int(binary.LittleEndian.Uint16([]byte{lengthByte[0] & 0b00111111, rest[0]}))
+		return int(lengthByte[0]&0b00111111)<<8 | int(rest[0]), false
 	case 0b10:
+		if lengthByte[0] == 0x81 {
+			rest := make([]byte, 8)
+			rdb.file.Read(rest)
+			return int(binary.BigEndian.Uint64(rest)), false
+		}
 		rest := make([]byte, 4)
 		rdb.file.Read(rest)
-		return int(binary.LittleEndian.Uint32(rest))
+		return int(binary.BigEndian.Uint32(rest)), false
 	default:
-		return int(binary.LittleEndian.Uint16([]byte{lengthByte[0] & 0b00111111, 0}))
+		return int(lengthByte[0] & 0b00111111), true
 	}
-}
\ No newline at end of file
+}
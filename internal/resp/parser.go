@@ -2,60 +2,79 @@ package resp
 
 import (
 	"bufio"
+	"fmt"
+	"io"
 	"strconv"
 	"strings"
 )
 
-type RespType [][]string
+var errProtocol = fmt.Errorf("Protocol error")
 
-type RespScanner struct {
-	*bufio.Scanner
-}
-
-func NewResp(data []byte) RespType {
-	respScanner := newRespScanner(data)
-	resp := respScanner.Parse()
-	return resp
-}
-
-func newRespScanner(data []byte) *RespScanner {
-	return &RespScanner{
-		Scanner: bufio.NewScanner(strings.NewReader(string(data))),
-	}
-}
-
-func (scanner *RespScanner) Parse() RespType {
-	resp := make(RespType, 0)
-	for scanner.Scan() {
-		line := scanner.Text()
-		length, _ := strconv.Atoi(line[1:])
+// ReadCommand reads the next command off r, an array of bulk strings read by
+// their length so that they may hold any bytes. Replies, such as the
+// +FULLRESYNC a master sends a replica, and the RDB file that follows it are
+// skipped.
+func ReadCommand(r *bufio.Reader) ([]string, error) {
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			continue
+		}
 		switch line[0] {
 		case '*':
-			resp = append(resp, scanner.parseArray(length))
+			length, err := strconv.Atoi(line[1:])
+			if err != nil {
+				return nil, errProtocol
+			}
+			if length <= 0 {
+				continue
+			}
+			args := make([]string, length)
+			for i := range args {
+				if args[i], err = readBulkString(r); err != nil {
+					return nil, err
+				}
+			}
+			return args, nil
 		case '$':
-			scanner.Scan()
-			scanner.Scan()
-			temp := strings.Split(scanner.Text(), "*")
-			if len(temp) == 2 {
-				arrlen, _ := strconv.Atoi(temp[1])
-				resp = append(resp, scanner.parseArray(arrlen))
+			// An RDB file is sent as a bulk string without the final CRLF.
+			length, err := strconv.Atoi(line[1:])
+			if err != nil || length < 0 {
+				return nil, errProtocol
+			}
+			if _, err := io.CopyN(io.Discard, r, int64(length)); err != nil {
+				return nil, err
 			}
 		}
 	}
-	return resp
 }
 
-func (scanner *RespScanner) parseArray(length int) []string {
-	resp := make([]string, 0)
-	for i := 0; i < length; i++ {
-		resp = append(resp, scanner.parseBulkString())
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
 	}
-	return resp
+	return strings.TrimRight(line, "\r\n"), nil
 }
 
-func (scanner *RespScanner) parseBulkString() string {
-	scanner.Scan()
-	scanner.Scan()
-	str := scanner.Text()
-	return strings.ToLower(str)
-}
\ No newline at end of file
+func readBulkString(r *bufio.Reader) (string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return "", err
+	}
+	if line == "" || line[0] != '$' {
+		return "", errProtocol
+	}
+	length, err := strconv.Atoi(line[1:])
+	if err != nil || length < 0 {
+		return "", errProtocol
+	}
+	buf := make([]byte, length+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf[:length]), nil
+}
@@ -0,0 +1,145 @@
+package resp
+
+import (
+	"encoding/binary"
+	"strconv"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+)
+
+const (
+	streamItemDeleted = 1
+	streamItemSameFields = 2
+)
+
+// readStream reads a stream: its nodes, each a master ID and a listpack of
+// entries, its metadata, and its consumer groups with their pending entries
+// and consumers. Later RDB types add fields, hence valueType.
+func (rdb *RDB) readStream(valueType byte) *cache.StreamState {
+	state := &cache.StreamState{Entries: []cache.StreamType{}}
+	nodes := rdb.ReadLengthEncoded()
+	for i := 0; i < nodes; i++ {
+		master := rdb.ReadRDBString()
+		listpack := parseListpack([]byte(rdb.ReadRDBString()))
+		if len(master) != 16 {
+			continue
+		}
+		masterID := cache.StreamID{Ms: binary.BigEndian.Uint64([]byte(master[:8])), Seq: binary.BigEndian.Uint64([]byte(master[8:]))}
+		state.Entries = append(state.Entries, parseStreamNode(masterID, listpack)...)
+	}
+	length := rdb.ReadLengthEncoded()
+	state.LastID = rdb.readStreamID()
+	state.EntriesAdded = uint64(length)
+	if valueType >= RDB_TYPE_STREAM_LISTPACKS_2 {
+		rdb.readStreamID()
+		state.MaxDeletedID = rdb.readStreamID()
+		state.EntriesAdded = uint64(rdb.ReadLengthEncoded())
+	}
+	groups := rdb.ReadLengthEncoded()
+	for i := 0; i < groups; i++ {
+		group := cache.GroupState{Name: rdb.ReadRDBString(), LastID: rdb.readStreamID(), EntriesRead: -1}
+		if valueType >= RDB_TYPE_STREAM_LISTPACKS_2 {
+			group.EntriesRead = int64(rdb.ReadLengthEncoded())
+		}
+		pending := rdb.ReadLengthEncoded()
+		// Pending entries only learn their consumer from the consumers that
+		// follow them.
+		index := make(map[cache.StreamID]int, pending)
+		for j := 0; j < pending; j++ {
+			entry := cache.PendingEntry{Id: rdb.readRawStreamID(), DeliveryTime: rdb.readMillis()}
+			entry.DeliveryCount = uint64(rdb.ReadLengthEncoded())
+			index[entry.Id] = len(group.Pending)
+			group.Pending = append(group.Pending, entry)
+		}
+		consumers := rdb.ReadLengthEncoded()
+		for j := 0; j < consumers; j++ {
+			consumer := cache.ConsumerState{Name: rdb.ReadRDBString(), SeenTime: rdb.readMillis(), ActiveTime: -1}
+			if valueType >= RDB_TYPE_STREAM_LISTPACKS_3 {
+				consumer.ActiveTime = rdb.readMillis()
+			}
+			owned := rdb.ReadLengthEncoded()
+			for k := 0; k < owned; k++ {
+				if idx, ok := index[rdb.readRawStreamID()]; ok {
+					group.Pending[idx].Consumer = consumer.Name
+				}
+			}
+			group.Consumers = append(group.Consumers, consumer)
+		}
+		state.Groups = append(state.Groups, group)
+	}
+	return state
+}
+
+// parseStreamNode decodes the entries of a stream node. The listpack starts
+// with a master entry, the entry count, the deleted count and the master
+// field names, then holds each entry as flags, ID deltas from the master ID,
+// its fields, or only its values when it shares the master fields, and the
+// number of listpack elements it took.
+func parseStreamNode(master cache.StreamID, lp []string) []cache.StreamType {
+	entries := []cache.StreamType{}
+	atoi := func(i int) int64 {
+		if i >= len(lp) {
+			return 0
+		}
+		n, _ := strconv.ParseInt(lp[i], 10, 64)
+		return n
+	}
+	if len(lp) < 3 {
+		return entries
+	}
+	numFields := int(atoi(2))
+	if 3+numFields > len(lp) {
+		return entries
+	}
+	masterFields := lp[3 : 3+numFields]
+	p := 4 + numFields
+	for p+3 <= len(lp) {
+		flags := atoi(p)
+		id := cache.StreamID{Ms: master.Ms + uint64(atoi(p+1)), Seq: master.Seq + uint64(atoi(p+2))}
+		p += 3
+		fields := []string{}
+		if flags&streamItemSameFields != 0 {
+			if p+numFields > len(lp) {
+				break
+			}
+			for i, field := range masterFields {
+				fields = append(fields, field, lp[p+i])
+			}
+			p += numFields
+		} else {
+			n := int(atoi(p))
+			p++
+			if p+2*n > len(lp) {
+				break
+			}
+			fields = append(fields, lp[p:p+2*n]...)
+			p += 2 * n
+		}
+		p++
+		if flags&streamItemDeleted == 0 {
+			entries = append(entries, cache.StreamType{Id: id, Data: fields})
+		}
+	}
+	return entries
+}
+
+func (rdb *RDB) readStreamID() cache.StreamID {
+	ms := rdb.ReadLengthEncoded()
+	seq := rdb.ReadLengthEncoded()
+	return cache.StreamID{Ms: uint64(ms), Seq: uint64(seq)}
+}
+
+// readRawStreamID reads an ID stored as two big endian 64 bit integers.
+func (rdb *RDB) readRawStreamID() cache.StreamID {
+	buf := make([]byte, 16)
+	rdb.file.Read(buf)
+	return cache.StreamID{Ms: binary.BigEndian.Uint64(buf[:8]), Seq: binary.BigEndian.Uint64(buf[8:])}
+}
+
+// readMillis reads a unix time in milliseconds stored as a little endian 64
+// bit integer.
+func (rdb *RDB) readMillis() int64 {
+	buf := make([]byte, 8)
+	rdb.file.Read(buf)
+	return int64(binary.LittleEndian.Uint64(buf))
+}
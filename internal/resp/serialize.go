@@ -46,22 +46,39 @@ func ToStreamRESPArray(arr []cache.StreamType) string {
 	resp := "*" + strconv.Itoa(len(arr)) + CLRF
 	for _, stream := range arr {
 		resp += "*" + "2" + CLRF
-		resp += ToRESPBulkString(stream.Id)
-		resp += ToRESPArray(stream.Data)
+		resp += ToRESPBulkString(stream.Id.String())
+		if stream.Data == nil {
+			resp += ToRESPNullArray()
+		} else {
+			resp += ToRESPArray(stream.Data)
+		}
 	}
 	return resp
 }
 
-func ToRESPStreamWithName(streamMap map[string][]cache.StreamType) string {
-	resp := "*" + strconv.Itoa(len(streamMap)) + CLRF
-	for streamName, stream := range streamMap {
+// ToRESPStreamWithName replies with each stream name followed by its
+// entries, keeping the order of names.
+func ToRESPStreamWithName(names []string, streams [][]cache.StreamType) string {
+	resp := "*" + strconv.Itoa(len(names)) + CLRF
+	for i, name := range names {
 		resp += "*2" + CLRF
-		resp += ToRESPBulkString(streamName)
-		resp += "*" + strconv.Itoa(len(stream)) + CLRF
-		for _, stream := range stream {
-			resp += "*" + "2" + CLRF
-			resp += ToRESPBulkString(stream.Id)
-			resp += ToRESPArray(stream.Data)
+		resp += ToRESPBulkString(name)
+		resp += ToStreamRESPArray(streams[i])
+	}
+	return resp
+}
+
+func ToRESPArrayLen(n int) string {
+	return "*" + strconv.Itoa(n) + CLRF
+}
+
+func ToRESPNullableArray(arr []*string) string {
+	resp := ToRESPArrayLen(len(arr))
+	for _, str := range arr {
+		if str == nil {
+			resp += ToRESPNullBulkString()
+		} else {
+			resp += ToRESPBulkString(*str)
 		}
 	}
 	return resp
@@ -0,0 +1,84 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+)
+
+const ErrSyntax = "ERR syntax error"
+
+// maxRandomRepeats bounds the negative counts of SRANDMEMBER, HRANDFIELD and
+// ZRANDMEMBER, for which members may repeat and the reply grows with the
+// count rather than the collection: a reply of more members than this could
+// not be built in memory.
+const maxRandomRepeats = 1 << 28
+
+var errValueOutOfRange = fmt.Errorf("ERR value is out of range, must be positive")
+
+func wrongArgs(name string) string {
+	return fmt.Sprintf("ERR wrong number of arguments for '%s' command", name)
+}
+
+// parseExpireAt converts an EX/PX/EXAT/PXAT option value into an absolute
+// unix time in milliseconds.
+func parseExpireAt(unit, value, cmdName string) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, cache.ErrNotInteger
+	}
+	invalid := fmt.Errorf("ERR invalid expire time in '%s' command", cmdName)
+	if n <= 0 {
+		return 0, invalid
+	}
+	if unit == "ex" || unit == "exat" {
+		if n > math.MaxInt64/1000 {
+			return 0, invalid
+		}
+		n *= 1000
+	}
+	if unit == "ex" || unit == "px" {
+		now := time.Now().UnixMilli()
+		if n > math.MaxInt64-now {
+			return 0, invalid
+		}
+		n += now
+	}
+	return n, nil
+}
+
+// parseInt parses an integer argument, replying with the standard error when
+// it is not one.
+func parseInt(value string) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, cache.ErrNotInteger
+	}
+	return n, nil
+}
+
+// parseTimeout reads the timeout of a blocking command, given in seconds as a
+// float for list pops and in milliseconds otherwise.
+func parseTimeout(value string, seconds bool) (time.Duration, error) {
+	if seconds {
+		timeout, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(timeout) || math.IsInf(timeout, 0) {
+			return 0, fmt.Errorf("ERR timeout is not a float or out of range")
+		}
+		if timeout < 0 {
+			return 0, fmt.Errorf("ERR timeout is negative")
+		}
+		return time.Duration(timeout * float64(time.Second)), nil
+	}
+	timeout, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("ERR timeout is not an integer or out of range")
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("ERR timeout is negative")
+	}
+	return time.Duration(timeout) * time.Millisecond, nil
+}
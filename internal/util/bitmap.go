@@ -0,0 +1,268 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/resp"
+)
+
+func parseBitOffset(value string) (int64, error) {
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || offset < 0 || offset > int64(cache.MaxBitOffset) {
+		return 0, cache.ErrBitOffset
+	}
+	return offset, nil
+}
+
+// parseBitUnit reads the optional BYTE|BIT argument of BITCOUNT and BITPOS.
+func parseBitUnit(args []string) (bool, bool) {
+	if len(args) == 0 {
+		return false, true
+	}
+	if len(args) > 1 {
+		return false, false
+	}
+	switch strings.ToLower(args[0]) {
+	case "byte":
+		return false, true
+	case "bit":
+		return true, true
+	}
+	return false, false
+}
+
+func handleSetBit(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 3 {
+		return resp.ToRESPError(wrongArgs("setbit"))
+	}
+	offset, err := parseBitOffset(cmd.GetArg(1))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if cmd.GetArg(2) != "0" && cmd.GetArg(2) != "1" {
+		return resp.ToRESPError("ERR bit is not an integer or out of range")
+	}
+	old, err := c.SetBit(cmd.GetArg(0), offset, int(cmd.GetArg(2)[0]-'0'))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPInteger(old)
+}
+
+func handleGetBit(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) != 2 {
+		return resp.ToRESPError(wrongArgs("getbit"))
+	}
+	offset, err := parseBitOffset(cmd.GetArg(1))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	bit, err := c.GetBit(cmd.GetArg(0), offset)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPInteger(bit)
+}
+
+func handleBitCount(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 1 {
+		return resp.ToRESPError(wrongArgs("bitcount"))
+	}
+	start, end := int64(0), int64(-1)
+	hasRange, bitUnit := false, false
+	if len(args) > 1 {
+		if len(args) < 3 {
+			return resp.ToRESPError(ErrSyntax)
+		}
+		var err error
+		if start, err = parseInt(args[1]); err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		if end, err = parseInt(args[2]); err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		var ok bool
+		if bitUnit, ok = parseBitUnit(args[3:]); !ok {
+			return resp.ToRESPError(ErrSyntax)
+		}
+		hasRange = true
+	}
+	count, err := c.BitCount(args[0], start, end, hasRange, bitUnit)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPInteger(int(count))
+}
+
+func handleBitPos(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 2 {
+		return resp.ToRESPError(wrongArgs("bitpos"))
+	}
+	if args[1] != "0" && args[1] != "1" {
+		return resp.ToRESPError("ERR The bit argument must be 1 or 0.")
+	}
+	start, end := int64(0), int64(-1)
+	hasEnd, bitUnit := false, false
+	var err error
+	if len(args) > 2 {
+		if start, err = parseInt(args[2]); err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+	}
+	if len(args) > 3 {
+		if end, err = parseInt(args[3]); err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		var ok bool
+		if bitUnit, ok = parseBitUnit(args[4:]); !ok {
+			return resp.ToRESPError(ErrSyntax)
+		}
+		hasEnd = true
+	}
+	pos, err := c.BitPos(args[0], int(args[1][0]-'0'), start, end, hasEnd, bitUnit)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPInteger(int(pos))
+}
+
+func handleBitOp(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 3 {
+		return resp.ToRESPError(wrongArgs("bitop"))
+	}
+	op := strings.ToLower(args[0])
+	switch op {
+	case "and", "or", "xor":
+	case "not":
+		if len(args) != 3 {
+			return resp.ToRESPError("ERR BITOP NOT must be called with a single source key.")
+		}
+	default:
+		return resp.ToRESPError(ErrSyntax)
+	}
+	length, err := c.BitOp(op, args[1], args[2:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPInteger(length)
+}
+
+// parseBitFieldType reads a BITFIELD type such as i16 or u8.
+func parseBitFieldType(value string) (bool, int, bool) {
+	if len(value) < 2 {
+		return false, 0, false
+	}
+	signed := value[0] == 'i' || value[0] == 'I'
+	if !signed && value[0] != 'u' && value[0] != 'U' {
+		return false, 0, false
+	}
+	width, err := strconv.Atoi(value[1:])
+	if err != nil || width < 1 || (signed && width > 64) || (!signed && width > 63) {
+		return false, 0, false
+	}
+	return signed, width, true
+}
+
+// parseBitFieldOffset reads a BITFIELD offset, where #N means N times the
+// field width.
+func parseBitFieldOffset(value string, width int) (int64, error) {
+	multiply := strings.HasPrefix(value, "#")
+	if multiply {
+		value = value[1:]
+	}
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, cache.ErrBitOffset
+	}
+	if multiply {
+		if offset > int64(cache.MaxBitOffset)/int64(width) {
+			return 0, cache.ErrBitOffset
+		}
+		offset *= int64(width)
+	}
+	if offset+int64(width)-1 > int64(cache.MaxBitOffset) {
+		return 0, cache.ErrBitOffset
+	}
+	return offset, nil
+}
+
+// handleBitField serves BITFIELD and its read-only variant BITFIELD_RO.
+func handleBitField(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	readOnly := cmd.GetName() == command.BITFIELD_RO
+	if len(args) < 1 {
+		return resp.ToRESPError(wrongArgs(cmd.GetName()))
+	}
+	ops := []cache.BitFieldOp{}
+	overflow := "wrap"
+	for i := 1; i < len(args); i++ {
+		subcommand := strings.ToLower(args[i])
+		if subcommand == "overflow" {
+			if i+1 >= len(args) {
+				return resp.ToRESPError(ErrSyntax)
+			}
+			overflow = strings.ToLower(args[i+1])
+			if overflow != "wrap" && overflow != "sat" && overflow != "fail" {
+				return resp.ToRESPError("ERR Invalid OVERFLOW type specified")
+			}
+			i++
+			continue
+		}
+		need := 3
+		if subcommand == "get" {
+			need = 2
+		} else if subcommand != "set" && subcommand != "incrby" {
+			return resp.ToRESPError(ErrSyntax)
+		}
+		if i+need >= len(args) {
+			return resp.ToRESPError(ErrSyntax)
+		}
+		signed, width, ok := parseBitFieldType(args[i+1])
+		if !ok {
+			return resp.ToRESPError("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+		}
+		offset, err := parseBitFieldOffset(args[i+2], width)
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		op := cache.BitFieldOp{Op: subcommand, Signed: signed, Bits: width, Offset: offset, Overflow: overflow}
+		if need == 3 {
+			if op.Value, err = parseInt(args[i+3]); err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+		}
+		if readOnly && subcommand != "get" {
+			return resp.ToRESPError("ERR BITFIELD_RO only supports the GET subcommand")
+		}
+		ops = append(ops, op)
+		i += need
+	}
+	results, err := c.BitField(args[0], ops)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	for _, op := range ops {
+		if op.Op != "get" {
+			propagate(redis, cmd)
+			break
+		}
+	}
+	res := resp.ToRESPArrayLen(len(results))
+	for _, value := range results {
+		if value == nil {
+			res += resp.ToRESPNullBulkString()
+		} else {
+			res += resp.ToRESPInteger(int(*value))
+		}
+	}
+	return res
+}
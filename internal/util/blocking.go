@@ -0,0 +1,183 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// blockedClient is a client parked by a blocking command. try attempts to
+// serve the command and reports whether it could. undo, when set, gives back
+// what a successful try took if the reply cannot be delivered.
+type blockedClient struct {
+	client *Client
+	keys []string
+	try func() (string, bool)
+	undo func()
+	reply chan string
+	timeoutReply string
+}
+
+// Blocker parks clients whose command cannot be served yet. Every write to a
+// key wakes the clients blocked on it in the order they blocked, retrying
+// their command until one of them can no longer be served.
+type Blocker struct {
+	mu sync.Mutex
+	queues map[string][]*blockedClient
+	clients map[*Client]*blockedClient
+	pending []string
+}
+
+var blocker = NewBlocker()
+
+func NewBlocker() *Blocker {
+	return &Blocker{
+		queues: make(map[string][]*blockedClient),
+		clients: make(map[*Client]*blockedClient),
+	}
+}
+
+// Block serves the command with try, or parks the client until a write to one
+// of keys lets try succeed. It gives up with timeoutReply once timeout
+// expires, a zero timeout waiting forever, and returns "" if the client
+// disconnects first. Commands that take elements away pass undo to put them
+// back when the client disconnects after being served; it runs with b.mu
+// held, so it must only signal keys.
+func (b *Blocker) Block(client *Client, keys []string, timeout time.Duration, try func() (string, bool), undo func(), timeoutReply string) string {
+	b.mu.Lock()
+	if res, ok := try(); ok {
+		b.drain()
+		b.mu.Unlock()
+		return res
+	}
+	bc := &blockedClient{
+		client: client,
+		keys: keys,
+		try: try,
+		undo: undo,
+		reply: make(chan string, 1),
+		timeoutReply: timeoutReply,
+	}
+	for _, key := range keys {
+		b.queues[key] = append(b.queues[key], bc)
+	}
+	b.clients[client] = bc
+	b.mu.Unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case res := <-bc.reply:
+		return res
+	case <-expired:
+		return b.cancel(bc, timeoutReply)
+	case <-client.Done():
+		b.disconnect(bc)
+		return ""
+	}
+}
+
+// cancel stops bc from waiting and returns reply, unless a write served it in
+// the meantime, in which case that reply wins.
+func (b *Blocker) cancel(bc *blockedClient, reply string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.clients[bc.client] != bc {
+		return <-bc.reply
+	}
+	b.remove(bc)
+	return reply
+}
+
+// disconnect stops bc from waiting for a client that went away. If a write
+// served it in the meantime, the reply has nowhere to go: what it took is
+// given back and handed to the next client blocked on the keys.
+func (b *Blocker) disconnect(bc *blockedClient) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.clients[bc.client] == bc {
+		b.remove(bc)
+		return
+	}
+	<-bc.reply
+	if bc.undo != nil {
+		bc.undo()
+		b.pending = append(b.pending, bc.keys...)
+		b.drain()
+	}
+}
+
+// Unblock releases a blocked client as if it timed out, or with an error
+// when withError is set. It reports whether the client was blocked.
+func (b *Blocker) Unblock(client *Client, withError bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bc, ok := b.clients[client]
+	if !ok {
+		return false
+	}
+	b.remove(bc)
+	if withError {
+		bc.reply <- "-UNBLOCKED client unblocked via CLIENT UNBLOCK\r\n"
+	} else {
+		bc.reply <- bc.timeoutReply
+	}
+	return true
+}
+
+// Wake serves the clients blocked on key after it was written.
+func (b *Blocker) Wake(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, key)
+	b.drain()
+}
+
+// signal marks key as written from inside a try function, where b.mu is
+// already held; the key is served once the current one is done.
+func (b *Blocker) signal(key string) {
+	b.pending = append(b.pending, key)
+}
+
+func (b *Blocker) drain() {
+	for len(b.pending) > 0 {
+		key := b.pending[0]
+		b.pending = b.pending[1:]
+		for i := 0; i < len(b.queues[key]); {
+			bc := b.queues[key][i]
+			res, ok := bc.try()
+			if !ok {
+				i++
+				continue
+			}
+			b.remove(bc)
+			bc.reply <- res
+		}
+	}
+}
+
+func (b *Blocker) remove(bc *blockedClient) {
+	delete(b.clients, bc.client)
+	for _, key := range bc.keys {
+		queue := b.queues[key]
+		for i, other := range queue {
+			if other == bc {
+				queue = append(queue[:i:i], queue[i+1:]...)
+				break
+			}
+		}
+		if len(queue) == 0 {
+			delete(b.queues, key)
+		} else {
+			b.queues[key] = queue
+		}
+	}
+}
+
+// wakeKey serves the clients blocked on key after a command wrote it.
+func wakeKey(key string) {
+	blocker.Wake(key)
+}
@@ -0,0 +1,62 @@
+package util
+
+import "testing"
+
+// A client that disconnects after a write served it but before it got the
+// reply must not take the element with it: the next client blocked on the
+// key gets it instead.
+func TestDisconnectAfterServedHandsElementOn(t *testing.T) {
+	b := NewBlocker()
+	list := []string{}
+	park := func(client *Client, got *string, undo func()) *blockedClient {
+		bc := &blockedClient{
+			client: client,
+			keys: []string{"list"},
+			try: func() (string, bool) {
+				if len(list) == 0 {
+					return "", false
+				}
+				*got, list = list[0], list[1:]
+				return *got, true
+			},
+			undo: undo,
+			reply: make(chan string, 1),
+		}
+		b.queues["list"] = append(b.queues["list"], bc)
+		b.clients[client] = bc
+		return bc
+	}
+	gone, waiting := NewClient(nil), NewClient(nil)
+	defer gone.Close()
+	defer waiting.Close()
+	var goneGot, waitingGot string
+	served := park(gone, &goneGot, func() { list = append([]string{goneGot}, list...) })
+	next := park(waiting, &waitingGot, nil)
+
+	list = append(list, "a")
+	b.Wake("list")
+	gone.Close()
+	b.disconnect(served)
+
+	select {
+	case res := <-next.reply:
+		if res != "a" {
+			t.Fatalf("next waiter got %q, want \"a\"", res)
+		}
+	default:
+		t.Fatal("the element was not handed to the next waiter")
+	}
+	if len(list) != 0 || len(b.clients) != 0 {
+		t.Fatalf("list %v, %d clients still blocked", list, len(b.clients))
+	}
+}
+
+func TestDisconnectWhileWaitingUnblocks(t *testing.T) {
+	b := NewBlocker()
+	client := NewClient(nil)
+	client.Close()
+	res := b.Block(client, []string{"list"}, 0, func() (string, bool) { return "", false }, nil, "timeout")
+	if res != "" || len(b.clients) != 0 || len(b.queues) != 0 {
+		t.Fatalf("got %q with %d clients blocked", res, len(b.clients))
+	}
+}
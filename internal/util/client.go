@@ -0,0 +1,73 @@
+package util
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Client is a connection served by the node, together with the state that
+// commands keep about it between calls.
+type Client struct {
+	id int64
+	conn net.Conn
+	closed chan struct{}
+	closeOnce sync.Once
+	// noTouch keeps the commands of the client from updating the access
+	// time and frequency of keys, as set by CLIENT NO-TOUCH.
+	noTouch bool
+}
+
+var (
+	nextClientId atomic.Int64
+	clientsMu sync.Mutex
+	clients = map[int64]*Client{}
+)
+
+func NewClient(conn net.Conn) *Client {
+	client := &Client{
+		id: nextClientId.Add(1),
+		conn: conn,
+		closed: make(chan struct{}),
+	}
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	clients[client.id] = client
+	return client
+}
+
+func getClient(id int64) *Client {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	return clients[id]
+}
+
+func (client *Client) GetId() int64 {
+	return client.id
+}
+
+func (client *Client) GetConn() net.Conn {
+	return client.conn
+}
+
+func (client *Client) NoTouch() bool {
+	return client.noTouch
+}
+
+func (client *Client) SetNoTouch(noTouch bool) {
+	client.noTouch = noTouch
+}
+
+// Done is closed once the client has disconnected.
+func (client *Client) Done() <-chan struct{} {
+	return client.closed
+}
+
+func (client *Client) Close() {
+	client.closeOnce.Do(func() {
+		clientsMu.Lock()
+		delete(clients, client.id)
+		clientsMu.Unlock()
+		close(client.closed)
+	})
+}
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/resp"
+)
+
+func handleDump(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) != 1 {
+		return resp.ToRESPError(wrongArgs("dump"))
+	}
+	v, ok, err := c.Dump(cmd.GetArg(0))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if !ok {
+		return resp.ToRESPNullBulkString()
+	}
+	return resp.ToRESPBulkString(resp.EncodeDump(v))
+}
+
+// handleRestore serves RESTORE key ttl payload [REPLACE] [ABSTTL] [IDLETIME
+// seconds] [FREQ frequency]. The payload is read as sent, not lowercased. It
+// is propagated with an absolute ttl and REPLACE.
+func handleRestore(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 3 {
+		return resp.ToRESPError(wrongArgs("restore"))
+	}
+	key := args[0]
+	ttl, err := parseInt(args[1])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if ttl < 0 {
+		return resp.ToRESPError("ERR Invalid TTL value, must be >= 0")
+	}
+	opts := cache.RestoreOptions{IdleTime: -1, Freq: -1}
+	absTTL := false
+	for i := 3; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "replace":
+			opts.Replace = true
+		case "absttl":
+			absTTL = true
+		case "idletime":
+			if i+1 >= len(args) || opts.Freq >= 0 {
+				return resp.ToRESPError(ErrSyntax)
+			}
+			idle, err := parseInt(args[i+1])
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			if idle < 0 {
+				return resp.ToRESPError("ERR Invalid IDLETIME value, must be >= 0")
+			}
+			opts.IdleTime = idle
+			i++
+		case "freq":
+			if i+1 >= len(args) || opts.IdleTime >= 0 {
+				return resp.ToRESPError(ErrSyntax)
+			}
+			freq, err := parseInt(args[i+1])
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			if freq < 0 || freq > 255 {
+				return resp.ToRESPError("ERR Invalid FREQ value, must be >= 0 and <= 255")
+			}
+			opts.Freq = int(freq)
+			i++
+		default:
+			return resp.ToRESPError(ErrSyntax)
+		}
+	}
+	v, err := resp.DecodeDump(cmd.GetRawArg(2))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if ttl > 0 {
+		opts.ExpireAt = ttl
+		if !absTTL {
+			opts.ExpireAt += time.Now().UnixMilli()
+		}
+		// A key restored already expired is not created at all.
+		if opts.ExpireAt < time.Now().UnixMilli() {
+			if opts.Replace {
+				c.Del(key)
+				propagateArgs(redis, []string{"DEL", key})
+			}
+			return resp.ToRESPSimpleString("OK")
+		}
+	}
+	if err := c.Restore(key, v, opts); err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagated := []string{"RESTORE", key, strconv.FormatInt(opts.ExpireAt, 10), cmd.GetRawArg(2), "REPLACE", "ABSTTL"}
+	if opts.IdleTime >= 0 {
+		propagated = append(propagated, "IDLETIME", strconv.FormatInt(opts.IdleTime, 10))
+	}
+	if opts.Freq >= 0 {
+		propagated = append(propagated, "FREQ", strconv.Itoa(opts.Freq))
+	}
+	propagateArgs(redis, propagated)
+	return resp.ToRESPSimpleString("OK")
+}
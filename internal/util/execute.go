@@ -3,13 +3,22 @@ package util
 import (
 	"net"
 
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
 	"github.com/codecrafters-io/redis-starter-go/internal/command"
 	"github.com/codecrafters-io/redis-starter-go/internal/redis"
 	"github.com/codecrafters-io/redis-starter-go/internal/resp"
 )
 
-func Execute(redis redis.Node, conn net.Conn, cmd command.Command) {
+func Execute(redis redis.Node, client *Client, cmd command.Command) {
 	c := redis.GetCache()
+	if client.NoTouch() && cmd.GetName() != command.TOUCH {
+		c = c.NoTouch()
+	}
+	conn := client.GetConn()
+	if redis.IsMaster() && !evict(redis, c) && command.DenyOOM(cmd.GetName()) {
+		conn.Write([]byte(resp.ToRESPError(cache.ErrOOM.Error())))
+		return
+	}
 	switch cmd.GetName() {
 	case command.PING:
 		if redis.IsSlave() {
@@ -22,27 +31,201 @@ func Execute(redis redis.Node, conn net.Conn, cmd command.Command) {
 	case command.TYPE:
 		conn.Write([]byte(handleType(cmd, c)))
 	case command.XADD:
-		res := handleXADD(cmd, c, redis)
-		if redis.IsSlave() {
-			redis.UpdateOffset(len(resp.ToRESPArray(cmd.CmdToSlice())))
-		} else {
-			conn.Write([]byte(res))
-		}
-	case command.XRANGE:
+		writeReply(redis, conn, cmd, handleXADD(cmd, c, redis))
+	case command.XRANGE, command.XREVRANGE:
 		conn.Write([]byte(handleXRANGE(cmd, c)))
 	case command.XREAD:
-		conn.Write([]byte(handleXREAD(cmd, c)))
+		conn.Write([]byte(handleXREAD(cmd, c, client)))
+	case command.XLEN:
+		conn.Write([]byte(handleXLEN(cmd, c)))
+	case command.XDEL:
+		writeReply(redis, conn, cmd, handleXDEL(cmd, c, redis))
+	case command.XTRIM:
+		writeReply(redis, conn, cmd, handleXTRIM(cmd, c, redis))
+	case command.XSETID:
+		writeReply(redis, conn, cmd, handleXSETID(cmd, c, redis))
+	case command.XCONFIG:
+		writeReply(redis, conn, cmd, handleXCONFIG(cmd, c, redis))
+	case command.XGROUP:
+		writeReply(redis, conn, cmd, handleXGROUP(cmd, c, redis))
+	case command.XREADGROUP:
+		writeReply(redis, conn, cmd, handleXREADGROUP(cmd, c, redis, client))
+	case command.XACK:
+		writeReply(redis, conn, cmd, handleXACK(cmd, c, redis))
+	case command.XPENDING:
+		conn.Write([]byte(handleXPENDING(cmd, c)))
+	case command.XCLAIM:
+		writeReply(redis, conn, cmd, handleXCLAIM(cmd, c, redis))
+	case command.XAUTOCLAIM:
+		writeReply(redis, conn, cmd, handleXAUTOCLAIM(cmd, c, redis))
+	case command.XINFO:
+		conn.Write([]byte(handleXINFO(cmd, c)))
 	case command.KEYS:
 		conn.Write([]byte(handleKeys(c)))
 	case command.SET:
-		res := handleSet(cmd, c, redis)
-		if redis.IsSlave() {
-			redis.UpdateOffset(len(resp.ToRESPArray(cmd.CmdToSlice())))
-		} else {
-			conn.Write([]byte(res))
-		}
+		writeReply(redis, conn, cmd, handleSet(cmd, c, redis))
+	case command.INCR, command.DECR, command.INCRBY, command.DECRBY:
+		writeReply(redis, conn, cmd, handleIncrBy(cmd, c, redis))
+	case command.INCRBYFLOAT:
+		writeReply(redis, conn, cmd, handleIncrByFloat(cmd, c, redis))
+	case command.APPEND:
+		writeReply(redis, conn, cmd, handleAppend(cmd, c, redis))
+	case command.STRLEN:
+		conn.Write([]byte(handleStrLen(cmd, c)))
+	case command.GETRANGE:
+		conn.Write([]byte(handleGetRange(cmd, c)))
+	case command.SETRANGE:
+		writeReply(redis, conn, cmd, handleSetRange(cmd, c, redis))
+	case command.MGET:
+		conn.Write([]byte(handleMGet(cmd, c)))
+	case command.MSET, command.MSETNX:
+		writeReply(redis, conn, cmd, handleMSet(cmd, c, redis))
+	case command.GETSET:
+		writeReply(redis, conn, cmd, handleGetSet(cmd, c, redis))
+	case command.GETDEL:
+		writeReply(redis, conn, cmd, handleGetDel(cmd, c, redis))
+	case command.GETEX:
+		writeReply(redis, conn, cmd, handleGetEx(cmd, c, redis))
+	case command.SETNX:
+		writeReply(redis, conn, cmd, handleSetNX(cmd, c, redis))
+	case command.SETEX, command.PSETEX:
+		writeReply(redis, conn, cmd, handleSetEx(cmd, c, redis))
+	case command.SETBIT:
+		writeReply(redis, conn, cmd, handleSetBit(cmd, c, redis))
+	case command.GETBIT:
+		conn.Write([]byte(handleGetBit(cmd, c)))
+	case command.BITCOUNT:
+		conn.Write([]byte(handleBitCount(cmd, c)))
+	case command.BITPOS:
+		conn.Write([]byte(handleBitPos(cmd, c)))
+	case command.BITOP:
+		writeReply(redis, conn, cmd, handleBitOp(cmd, c, redis))
+	case command.BITFIELD:
+		writeReply(redis, conn, cmd, handleBitField(cmd, c, redis))
+	case command.BITFIELD_RO:
+		conn.Write([]byte(handleBitField(cmd, c, redis)))
+	case command.PFADD:
+		writeReply(redis, conn, cmd, handlePFAdd(cmd, c, redis))
+	case command.PFCOUNT:
+		conn.Write([]byte(handlePFCount(cmd, c)))
+	case command.PFMERGE:
+		writeReply(redis, conn, cmd, handlePFMerge(cmd, c, redis))
+	case command.LPUSH, command.RPUSH, command.LPUSHX, command.RPUSHX:
+		writeReply(redis, conn, cmd, handlePush(cmd, c, redis))
+	case command.LPOP, command.RPOP:
+		writeReply(redis, conn, cmd, handlePop(cmd, c, redis))
+	case command.LLEN:
+		conn.Write([]byte(handleLLen(cmd, c)))
+	case command.LRANGE:
+		conn.Write([]byte(handleLRange(cmd, c)))
+	case command.LINDEX:
+		conn.Write([]byte(handleLIndex(cmd, c)))
+	case command.LSET:
+		writeReply(redis, conn, cmd, handleLSet(cmd, c, redis))
+	case command.LREM:
+		writeReply(redis, conn, cmd, handleLRem(cmd, c, redis))
+	case command.LTRIM:
+		writeReply(redis, conn, cmd, handleLTrim(cmd, c, redis))
+	case command.LINSERT:
+		writeReply(redis, conn, cmd, handleLInsert(cmd, c, redis))
+	case command.LPOS:
+		conn.Write([]byte(handleLPos(cmd, c)))
+	case command.LMOVE, command.RPOPLPUSH:
+		writeReply(redis, conn, cmd, handleLMove(cmd, c, redis))
+	case command.LMPOP:
+		writeReply(redis, conn, cmd, handleLMPop(cmd, c, redis))
+	case command.BLPOP, command.BRPOP:
+		writeReply(redis, conn, cmd, handleBPop(cmd, c, redis, client))
+	case command.BLMOVE, command.BRPOPLPUSH:
+		writeReply(redis, conn, cmd, handleBLMove(cmd, c, redis, client))
+	case command.BLMPOP:
+		writeReply(redis, conn, cmd, handleBLMPop(cmd, c, redis, client))
+	case command.CLIENT:
+		conn.Write([]byte(handleClient(cmd, client)))
+	case command.HSET, command.HSETNX:
+		writeReply(redis, conn, cmd, handleHSet(cmd, c, redis))
+	case command.HGET, command.HEXISTS, command.HSTRLEN:
+		conn.Write([]byte(handleHGet(cmd, c)))
+	case command.HMGET:
+		conn.Write([]byte(handleHMGet(cmd, c)))
+	case command.HDEL:
+		writeReply(redis, conn, cmd, handleHDel(cmd, c, redis))
+	case command.HGETALL, command.HKEYS, command.HVALS:
+		conn.Write([]byte(handleHGetAll(cmd, c)))
+	case command.HLEN:
+		conn.Write([]byte(handleHLen(cmd, c)))
+	case command.HINCRBY:
+		writeReply(redis, conn, cmd, handleHIncrBy(cmd, c, redis))
+	case command.HINCRBYFLOAT:
+		writeReply(redis, conn, cmd, handleHIncrByFloat(cmd, c, redis))
+	case command.HRANDFIELD:
+		conn.Write([]byte(handleHRandField(cmd, c)))
+	case command.SADD:
+		writeReply(redis, conn, cmd, handleSAdd(cmd, c, redis))
+	case command.SREM:
+		writeReply(redis, conn, cmd, handleSRem(cmd, c, redis))
+	case command.SMEMBERS:
+		conn.Write([]byte(handleSMembers(cmd, c)))
+	case command.SISMEMBER, command.SMISMEMBER:
+		conn.Write([]byte(handleSIsMember(cmd, c)))
+	case command.SCARD:
+		conn.Write([]byte(handleSCard(cmd, c)))
+	case command.SPOP:
+		writeReply(redis, conn, cmd, handleSPop(cmd, c, redis))
+	case command.SRANDMEMBER:
+		conn.Write([]byte(handleSRandMember(cmd, c)))
+	case command.SMOVE:
+		writeReply(redis, conn, cmd, handleSMove(cmd, c, redis))
+	case command.SINTER, command.SUNION, command.SDIFF:
+		conn.Write([]byte(handleSetOp(cmd, c)))
+	case command.SINTERSTORE, command.SUNIONSTORE, command.SDIFFSTORE:
+		writeReply(redis, conn, cmd, handleSetOpStore(cmd, c, redis))
+	case command.SINTERCARD:
+		conn.Write([]byte(handleSInterCard(cmd, c)))
+	case command.ZADD:
+		writeReply(redis, conn, cmd, handleZAdd(cmd, c, redis))
+	case command.ZINCRBY:
+		writeReply(redis, conn, cmd, handleZIncrBy(cmd, c, redis))
+	case command.ZREM:
+		writeReply(redis, conn, cmd, handleZRem(cmd, c, redis))
+	case command.ZSCORE, command.ZMSCORE:
+		conn.Write([]byte(handleZScore(cmd, c)))
+	case command.ZCARD:
+		conn.Write([]byte(handleZCard(cmd, c)))
+	case command.ZCOUNT, command.ZLEXCOUNT:
+		conn.Write([]byte(handleZCount(cmd, c)))
+	case command.ZRANK, command.ZREVRANK:
+		conn.Write([]byte(handleZRank(cmd, c)))
+	case command.ZRANGE:
+		conn.Write([]byte(handleZRange(cmd, c)))
+	case command.ZRANGESTORE:
+		writeReply(redis, conn, cmd, handleZRangeStore(cmd, c, redis))
+	case command.ZPOPMIN, command.ZPOPMAX:
+		writeReply(redis, conn, cmd, handleZPop(cmd, c, redis))
+	case command.ZRANDMEMBER:
+		conn.Write([]byte(handleZRandMember(cmd, c)))
+	case command.ZREMRANGEBYRANK, command.ZREMRANGEBYSCORE, command.ZREMRANGEBYLEX:
+		writeReply(redis, conn, cmd, handleZRemRange(cmd, c, redis))
+	case command.GEOADD:
+		writeReply(redis, conn, cmd, handleGeoAdd(cmd, c, redis))
+	case command.GEOPOS:
+		conn.Write([]byte(handleGeoPos(cmd, c)))
+	case command.GEODIST:
+		conn.Write([]byte(handleGeoDist(cmd, c)))
+	case command.GEOHASH:
+		conn.Write([]byte(handleGeoHash(cmd, c)))
+	case command.GEOSEARCH:
+		conn.Write([]byte(handleGeoSearch(cmd, c)))
+	case command.GEOSEARCHSTORE:
+		writeReply(redis, conn, cmd, handleGeoSearchStore(cmd, c, redis))
+	case command.SORT:
+		writeReply(redis, conn, cmd, handleSort(cmd, c, redis, false))
+	case command.SORT_RO:
+		conn.Write([]byte(handleSort(cmd, c, redis, true)))
+	case command.LCS:
+		conn.Write([]byte(handleLCS(cmd, c)))
 	case command.DEL:
-		conn.Write([]byte(handleDel(cmd, c)))
+		writeReply(redis, conn, cmd, handleDel(cmd, c))
 	case command.GET:
 		conn.Write([]byte(handleGet(cmd, c)))
 	case command.INFO:
@@ -59,10 +242,30 @@ func Execute(redis redis.Node, conn net.Conn, cmd command.Command) {
 			conn.Write([]byte(resp.ToRESPError("Invalid Configuration")))
 		}
 	case command.WAIT:
-		conn.Write([]byte(handleWait(cmd, redis)))
+		conn.Write([]byte(handleWait(cmd, redis, client)))
+	case command.OBJECT:
+		conn.Write([]byte(handleObject(cmd, c)))
+	case command.DUMP:
+		conn.Write([]byte(handleDump(cmd, c)))
+	case command.RESTORE:
+		writeReply(redis, conn, cmd, handleRestore(cmd, c, redis))
+	case command.MEMORY:
+		conn.Write([]byte(handleMemory(cmd, c)))
+	case command.TOUCH:
+		conn.Write([]byte(handleTouch(cmd, c)))
 	case command.CONFIG:
 		conn.Write([]byte(handleConfig(cmd, redis)))
 	default:
 		conn.Write([]byte(resp.ToRESPError("Invalid Command")))
 	}
-}
\ No newline at end of file
+}
+
+// writeReply answers a write command. A replica applies the writes streamed
+// from its master silently and only advances its replication offset.
+func writeReply(redis redis.Node, conn net.Conn, cmd command.Command, res string) {
+	if redis.IsSlave() {
+		redis.UpdateOffset(len(resp.ToRESPArray(cmd.CmdToSlice())))
+	} else {
+		conn.Write([]byte(res))
+	}
+}
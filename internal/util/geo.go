@@ -0,0 +1,323 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/resp"
+)
+
+// parseGeoUnit returns how many meters one unit is.
+func parseGeoUnit(unit string) (float64, error) {
+	switch strings.ToLower(unit) {
+	case "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "ft":
+		return 0.3048, nil
+	case "mi":
+		return 1609.34, nil
+	}
+	return 0, fmt.Errorf("ERR unsupported unit provided. please use M, KM, FT, MI")
+}
+
+// parseLonLat reads a position and checks that it can be indexed.
+func parseLonLat(lonArg, latArg string) (float64, float64, error) {
+	lon, ok := cache.ParseFloat(lonArg)
+	if !ok {
+		return 0, 0, cache.ErrNotFloat
+	}
+	lat, ok := cache.ParseFloat(latArg)
+	if !ok {
+		return 0, 0, cache.ErrNotFloat
+	}
+	if !cache.ValidLonLat(lon, lat) {
+		return 0, 0, fmt.Errorf("ERR invalid longitude,latitude pair %f,%f", lon, lat)
+	}
+	return lon, lat, nil
+}
+
+// formatCoord renders a coordinate with 17 decimals, less trailing zeros.
+func formatCoord(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 17, 64)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+func formatDist(dist float64) string {
+	return strconv.FormatFloat(dist, 'f', 4, 64)
+}
+
+func handleGeoAdd(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 4 {
+		return resp.ToRESPError(wrongArgs("geoadd"))
+	}
+	opts := cache.ZAddOptions{}
+	ch := false
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "nx":
+			opts.NX = true
+		case "xx":
+			opts.XX = true
+		case "ch":
+			ch = true
+		default:
+			break options
+		}
+	}
+	rest := args[i:]
+	if len(rest) == 0 || len(rest)%3 != 0 || (opts.NX && opts.XX) {
+		return resp.ToRESPError(ErrSyntax)
+	}
+	members := []cache.ZMember{}
+	for j := 0; j < len(rest); j += 3 {
+		lon, lat, err := parseLonLat(rest[j], rest[j+1])
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		members = append(members, cache.ZMember{Member: rest[j+2], Score: cache.GeoEncode(lon, lat)})
+	}
+	added, changed, _, _, err := c.ZAdd(args[0], members, opts)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if added+changed > 0 {
+		propagate(redis, cmd)
+	}
+	if ch {
+		return resp.ToRESPInteger(added + changed)
+	}
+	return resp.ToRESPInteger(added)
+}
+
+func handleGeoPos(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) < 1 {
+		return resp.ToRESPError(wrongArgs("geopos"))
+	}
+	positions, err := c.GeoPos(cmd.GetArg(0), cmd.GetArgs()[1:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	res := resp.ToRESPArrayLen(len(positions))
+	for _, pos := range positions {
+		if pos == nil {
+			res += resp.ToRESPNullArray()
+		} else {
+			res += resp.ToRESPArray([]string{formatCoord(pos[0]), formatCoord(pos[1])})
+		}
+	}
+	return res
+}
+
+func handleGeoDist(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 3 || len(args) > 4 {
+		return resp.ToRESPError(wrongArgs("geodist"))
+	}
+	unit := 1.0
+	if len(args) == 4 {
+		var err error
+		if unit, err = parseGeoUnit(args[3]); err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+	}
+	positions, err := c.GeoPos(args[0], args[1:3])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if positions[0] == nil || positions[1] == nil {
+		return resp.ToRESPNullBulkString()
+	}
+	dist := cache.GeoDistance(positions[0][0], positions[0][1], positions[1][0], positions[1][1])
+	return resp.ToRESPBulkString(formatDist(dist / unit))
+}
+
+func handleGeoHash(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) < 1 {
+		return resp.ToRESPError(wrongArgs("geohash"))
+	}
+	scores, err := c.ZScore(cmd.GetArg(0), cmd.GetArgs()[1:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	hashes := make([]*string, len(scores))
+	for i, score := range scores {
+		if score != nil {
+			hash := cache.GeoHashString(*score)
+			hashes[i] = &hash
+		}
+	}
+	return resp.ToRESPNullableArray(hashes)
+}
+
+// geoSearchOptions is a parsed GEOSEARCH or GEOSEARCHSTORE. unit is the
+// number of meters in the unit distances are given and replied in.
+type geoSearchOptions struct {
+	query cache.GeoQuery
+	unit float64
+	withCoord bool
+	withDist bool
+	withHash bool
+	storeDist bool
+}
+
+// parseGeoSearch reads the options following the key of a GEOSEARCH, or the
+// keys of a GEOSEARCHSTORE when store is set.
+func parseGeoSearch(args []string, store bool) (geoSearchOptions, error) {
+	opts := geoSearchOptions{}
+	q := &opts.query
+	fromSet, shapeSet, countSet := false, false, false
+	for i := 0; i < len(args); i++ {
+		left := len(args) - i - 1
+		switch option := strings.ToLower(args[i]); {
+		case option == "withcoord" && !store:
+			opts.withCoord = true
+		case option == "withdist" && !store:
+			opts.withDist = true
+		case option == "withhash" && !store:
+			opts.withHash = true
+		case option == "storedist" && store:
+			opts.storeDist = true
+		case option == "any":
+			q.Any = true
+		case option == "asc":
+			q.Sort = 1
+		case option == "desc":
+			q.Sort = -1
+		case option == "count" && left >= 1:
+			count, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return opts, cache.ErrNotInteger
+			}
+			if count <= 0 {
+				return opts, fmt.Errorf("ERR COUNT must be > 0")
+			}
+			q.Count, countSet = count, true
+			i++
+		case option == "frommember" && left >= 1 && !fromSet:
+			q.FromMember, q.Member, fromSet = true, args[i+1], true
+			i++
+		case option == "fromlonlat" && left >= 2 && !fromSet:
+			lon, lat, err := parseLonLat(args[i+1], args[i+2])
+			if err != nil {
+				return opts, err
+			}
+			q.Lon, q.Lat, fromSet = lon, lat, true
+			i += 2
+		case option == "byradius" && left >= 2 && !shapeSet:
+			radius, ok := cache.ParseFloat(args[i+1])
+			if !ok {
+				return opts, fmt.Errorf("ERR need numeric radius")
+			}
+			if radius < 0 {
+				return opts, fmt.Errorf("ERR radius cannot be negative")
+			}
+			unit, err := parseGeoUnit(args[i+2])
+			if err != nil {
+				return opts, err
+			}
+			q.Radius, opts.unit, shapeSet = radius*unit, unit, true
+			i += 2
+		case option == "bybox" && left >= 3 && !shapeSet:
+			width, ok := cache.ParseFloat(args[i+1])
+			if !ok {
+				return opts, fmt.Errorf("ERR need numeric width")
+			}
+			height, ok := cache.ParseFloat(args[i+2])
+			if !ok {
+				return opts, fmt.Errorf("ERR need numeric height")
+			}
+			if width < 0 || height < 0 {
+				return opts, fmt.Errorf("ERR height or width cannot be negative")
+			}
+			unit, err := parseGeoUnit(args[i+3])
+			if err != nil {
+				return opts, err
+			}
+			q.ByBox, q.Width, q.Height, opts.unit, shapeSet = true, width*unit, height*unit, unit, true
+			i += 3
+		default:
+			return opts, fmt.Errorf(ErrSyntax)
+		}
+	}
+	if !fromSet {
+		return opts, fmt.Errorf("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH")
+	}
+	if !shapeSet {
+		return opts, fmt.Errorf("ERR exactly one of BYRADIUS and BYBOX arguments must be provided for GEOSEARCH command")
+	}
+	if q.Any && !countSet {
+		return opts, fmt.Errorf("ERR the ANY argument requires COUNT argument")
+	}
+	return opts, nil
+}
+
+func handleGeoSearch(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 1 {
+		return resp.ToRESPError(wrongArgs("geosearch"))
+	}
+	opts, err := parseGeoSearch(args[1:], false)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	results, err := c.GeoSearch(args[0], opts.query)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if !opts.withCoord && !opts.withDist && !opts.withHash {
+		members := make([]string, len(results))
+		for i, r := range results {
+			members[i] = r.Member
+		}
+		return resp.ToRESPArray(members)
+	}
+	res := resp.ToRESPArrayLen(len(results))
+	for _, r := range results {
+		fields := resp.ToRESPBulkString(r.Member)
+		n := 1
+		if opts.withDist {
+			fields += resp.ToRESPBulkString(formatDist(r.Dist / opts.unit))
+			n++
+		}
+		if opts.withHash {
+			fields += resp.ToRESPInteger(int(r.Score))
+			n++
+		}
+		if opts.withCoord {
+			fields += resp.ToRESPArray([]string{formatCoord(r.Lon), formatCoord(r.Lat)})
+			n++
+		}
+		res += resp.ToRESPArrayLen(n) + fields
+	}
+	return res
+}
+
+func handleGeoSearchStore(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 2 {
+		return resp.ToRESPError(wrongArgs("geosearchstore"))
+	}
+	opts, err := parseGeoSearch(args[2:], true)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	distScale := 0.0
+	if opts.storeDist {
+		distScale = opts.unit
+	}
+	card, err := c.GeoSearchStore(args[0], args[1], opts.query, distScale)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPInteger(card)
+}
@@ -5,7 +5,7 @@ import (
 	"net"
 	"strconv"
 	"strings"
-	"time"
+	"sync/atomic"
 
 	"github.com/codecrafters-io/redis-starter-go/internal/cache"
 	"github.com/codecrafters-io/redis-starter-go/internal/command"
@@ -13,27 +13,83 @@ import (
 	"github.com/codecrafters-io/redis-starter-go/internal/resp"
 )
 
-var ackChan = make(chan bool, 1)
-var pubSub = NewPubSub()
+// replicaAcks counts the REPLCONF ACKs received from replicas, which WAIT
+// blocks on under replicaAckKey.
+var replicaAcks atomic.Int64
+
+const replicaAckKey = "\x00replconf-ack"
 
 func handleSet(cmd command.Command, c cache.Cache, redis redis.Node) string {
-	go propagate(redis, cmd)
-	if len(cmd.GetArgs()) > 2 && cmd.GetArg(2) == "px" {
-		px, err := strconv.Atoi(cmd.GetArg(3))
-		if err != nil {
-			return resp.ToRESPError("Invalid Argument")
+	args := cmd.GetArgs()
+	if len(args) < 2 {
+		return resp.ToRESPError(wrongArgs("set"))
+	}
+	opts := cache.SetOptions{}
+	hasExpire := false
+	for i := 2; i < len(args); i++ {
+		switch option := strings.ToLower(args[i]); option {
+		case "nx":
+			if opts.XX {
+				return resp.ToRESPError(ErrSyntax)
+			}
+			opts.NX = true
+		case "xx":
+			if opts.NX {
+				return resp.ToRESPError(ErrSyntax)
+			}
+			opts.XX = true
+		case "get":
+			opts.Get = true
+		case "keepttl":
+			if hasExpire {
+				return resp.ToRESPError(ErrSyntax)
+			}
+			opts.KeepTTL = true
+		case "ex", "px", "exat", "pxat":
+			if hasExpire || opts.KeepTTL || i+1 >= len(args) {
+				return resp.ToRESPError(ErrSyntax)
+			}
+			expireAt, err := parseExpireAt(option, args[i+1], "set")
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			opts.ExpireAt = expireAt
+			hasExpire = true
+			i++
+		default:
+			return resp.ToRESPError(ErrSyntax)
 		}
-		c.Set(cmd.GetArg(0), cmd.GetArg(1), int64(px))
-	} else if len(cmd.GetArgs()) == 3 {
-		return resp.ToRESPError("Invalid Argument")
-	} else {
-		c.Set(cmd.GetArg(0), cmd.GetArg(1), 0)
+	}
+	res, err := c.SetWithOptions(args[0], args[1], opts)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if res.Written {
+		propagated := []string{"SET", args[0], args[1]}
+		if opts.ExpireAt > 0 {
+			propagated = append(propagated, "PXAT", strconv.FormatInt(opts.ExpireAt, 10))
+		} else if opts.KeepTTL {
+			propagated = append(propagated, "KEEPTTL")
+		}
+		propagateArgs(redis, propagated)
+	}
+	if opts.Get {
+		if !res.OldExists {
+			return resp.ToRESPNullBulkString()
+		}
+		return resp.ToRESPBulkString(res.Old)
+	}
+	if !res.Written {
+		return resp.ToRESPNullBulkString()
 	}
 	return resp.ToRESPSimpleString("OK")
 }
 
 func handleGet(cmd command.Command, c cache.Cache) string {
 	value, err := c.Get(cmd.GetArg(0))
+	if err == cache.ErrWrongType {
+		return resp.ToRESPError(err.Error())
+	}
 	if err != nil {
 		return resp.ToRESPNullBulkString()
 	}
@@ -55,9 +111,22 @@ func handleInfo(redis redis.Node) string {
 	if redis.IsMaster() {
 		master_id := "master_replid:" + redis.GetReplId()
 		master_offset := "master_repl_offset:" + strconv.Itoa(redis.GetRepOffset())
-		return resp.ToRESPBulkString(role + "\n" + master_id + "\n" + master_offset)
+		return resp.ToRESPBulkString(role + "\n" + master_id + "\n" + master_offset + "\n" + statsInfo(redis))
 	} 
-	return resp.ToRESPBulkString(role)
+	return resp.ToRESPBulkString(role + "\n" + statsInfo(redis))
+}
+
+// statsInfo reports the memory the keys take and how many were evicted or
+// expired.
+func statsInfo(redis redis.Node) string {
+	used, evicted := redis.GetCache().MemoryStats()
+	maxMemory, _ := cache.MemoryConfig("maxmemory")
+	policy, _ := cache.MemoryConfig("maxmemory-policy")
+	return "used_memory:" + strconv.FormatInt(used, 10) + "\n" +
+		"maxmemory:" + maxMemory + "\n" +
+		"maxmemory_policy:" + policy + "\n" +
+		"evicted_keys:" + strconv.FormatInt(evicted, 10) + "\n" +
+		"expired_keys:" + strconv.FormatInt(redis.GetCache().ExpiredKeys(), 10)
 }
 
 func handleReplConf(cmd command.Command, redis redis.Node, conn net.Conn) string {
@@ -77,7 +146,8 @@ func handleReplConf(cmd command.Command, redis redis.Node, conn net.Conn) string
 		}
 		return ""
 	} else if cmd.GetArg(0) == "ack" {
-		ackChan <- true
+		replicaAcks.Add(1)
+		wakeKey(replicaAckKey)
 		return ""
 	} else {
 		return resp.ToRESPError("Invalid Configuration")
@@ -96,35 +166,33 @@ func handlePSYNC(cmd command.Command, redis redis.Node, conn net.Conn) string {
 	return resp.ToRESPError("Invalid Command")
 }
 
-func handleWait(cmd command.Command, redis redis.Node) string {
+func handleWait(cmd command.Command, redis redis.Node, client *Client) string {
 	numConn := len(redis.GetSlaveConn())
 	needAck, _ := strconv.Atoi(cmd.GetArg(0))
-	timeout, _ := strconv.Atoi(cmd.GetArg(1))
-	
-	if needAck != 0 {
-		ackCmd, err := command.NewCommand([]string{"REPLCONF", "GETACK", "*"})
-		if err != nil {
-			return resp.ToRESPError(err.Error())
-		}
-		go propagate(redis, *ackCmd)
-
-	}
-	numAck := 0
-	if needAck != 0 {
-		for numAck < needAck {
-			select {
-			case <- ackChan:
-				numAck++
-				if numAck >= needAck {
-					return resp.ToRESPInteger(numAck)
-				}
-			case <- time.After(time.Duration(timeout) * time.Millisecond):
-				if numAck > 0 {
-					return resp.ToRESPInteger(numAck)
-				}
-				return resp.ToRESPInteger(numConn)
-			}
+	timeout, err := parseTimeout(cmd.GetArg(1), false)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if needAck == 0 {
+		return resp.ToRESPInteger(numConn)
+	}
+	start := replicaAcks.Load()
+	ackCmd, err := command.NewCommand([]string{"REPLCONF", "GETACK", "*"})
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	go propagate(redis, *ackCmd)
+	try := func() (string, bool) {
+		if numAck := int(replicaAcks.Load() - start); numAck >= needAck {
+			return resp.ToRESPInteger(numAck), true
 		}
+		return "", false
+	}
+	if res := blocker.Block(client, []string{replicaAckKey}, timeout, try, nil, ""); res != "" {
+		return res
+	}
+	if numAck := int(replicaAcks.Load() - start); numAck > 0 {
+		return resp.ToRESPInteger(numAck)
 	}
 	return resp.ToRESPInteger(numConn)
 }
@@ -141,109 +209,194 @@ func handleType(cmd command.Command, c cache.Cache) string {
 	return resp.ToRESPSimpleString(c.GetType(cmd.GetArg(0)))
 }
 
-func handleConfig(cmd command.Command, redis redis.Node) string {
-	if cmd.GetArg(0) == "get" {
-		if cmd.GetArg(1) == "dir" {
-			return resp.ToRESPArray([]string{cmd.GetArg(1), redis.GetRDBDir()})
+func handleObject(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 1 {
+		return resp.ToRESPError(wrongArgs("object"))
+	}
+	subcommand := strings.ToLower(args[0])
+	switch subcommand {
+	case "encoding", "idletime", "freq", "refcount":
+		if len(args) != 2 {
+			return resp.ToRESPError(wrongArgs("object|" + subcommand))
+		}
+		info, ok := c.ObjectInfo(args[1])
+		if !ok {
+			return resp.ToRESPNullBulkString()
 		}
+		switch subcommand {
+		case "encoding":
+			return resp.ToRESPBulkString(info.Encoding)
+		case "idletime":
+			return resp.ToRESPInteger(int(info.IdleTime / 1000))
+		case "freq":
+			return resp.ToRESPInteger(info.Freq)
+		}
+		return resp.ToRESPInteger(info.RefCount)
+	case "help":
+		return resp.ToRESPArray([]string{
+			"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"ENCODING <key>",
+			"    Return the kind of internal representation used in order to store the value",
+			"    associated with a <key>.",
+			"FREQ <key>",
+			"    Return the access frequency index of the <key>. The returned integer is",
+			"    proportional to the logarithm of the recent access frequency of the key.",
+			"IDLETIME <key>",
+			"    Return the idle time of the <key>, that is the approximated number of",
+			"    seconds elapsed since the last access to the key.",
+			"REFCOUNT <key>",
+			"    Return the number of references of the value associated with the specified",
+			"    <key>.",
+			"HELP",
+			"    Print this help.",
+		})
 	}
-	return resp.ToRESPError("Invalid Command")
+	return resp.ToRESPError("ERR unknown subcommand '" + args[0] + "'. Try OBJECT HELP.")
 }
 
-func handleXADD(cmd command.Command, c cache.Cache, redis redis.Node) string {
-	go propagate(redis, cmd)
-	c.SetStream(cmd.GetArg(0))
-	id, err := c.AddToStream(cmd.GetArg(0), cmd.GetArg(1), cmd.GetArgs()[2:])
-	if err != nil {
-		return resp.ToRESPError(err.Error())
+// handleMemory serves MEMORY USAGE. SAMPLES is accepted for compatibility;
+// collections are always estimated from a few of their elements.
+func handleMemory(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 1 {
+		return resp.ToRESPError(wrongArgs("memory"))
 	}
-	pubSub.Publish("xread", cmd.GetArg(0) + "_" + id)
-	return resp.ToRESPBulkString(id)
+	switch strings.ToLower(args[0]) {
+	case "usage":
+		if len(args) != 2 && len(args) != 4 {
+			return resp.ToRESPError(wrongArgs("memory|usage"))
+		}
+		if len(args) == 4 {
+			if strings.ToLower(args[2]) != "samples" {
+				return resp.ToRESPError(ErrSyntax)
+			}
+			if _, err := parseInt(args[3]); err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+		}
+		size, ok := c.MemoryUsage(args[1])
+		if !ok {
+			return resp.ToRESPNullBulkString()
+		}
+		return resp.ToRESPInteger(int(size))
+	case "help":
+		return resp.ToRESPArray([]string{
+			"MEMORY <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"USAGE <key> [SAMPLES <count>]",
+			"    Return memory in bytes used by <key> and its value.",
+			"HELP",
+			"    Print this help.",
+		})
+	}
+	return resp.ToRESPError("ERR unknown subcommand '" + args[0] + "'. Try MEMORY HELP.")
 }
 
-func handleXRANGE(cmd command.Command, c cache.Cache) string {
-	stream := c.GetStream(cmd.GetArg(0), cmd.GetArg(1), cmd.GetArg(2))
-	if len(stream) == 0 {
-		return resp.ToRESPNullArray()
+// evict makes room under maxmemory before a command runs, sending a DEL for
+// every evicted key to the replicas. It returns false when the keys still do
+// not fit.
+func evict(redis redis.Node, c cache.Cache) bool {
+	evicted, ok := c.Evict()
+	for _, key := range evicted {
+		propagateArgs(redis, []string{"DEL", key})
 	}
-	return resp.ToStreamRESPArray(stream)
+	return ok
 }
 
-func handleXREAD(cmd command.Command, c cache.Cache) string {
-	switch cmd.GetArg(0) {
-	case "streams":
-		streamMap := GetStreamMap(cmd.GetArgs()[1:], c)
-		if len(streamMap) == 0 {
-			return resp.ToRESPNullArray()
-		}
-		return resp.ToRESPStreamWithName(streamMap)
-	case "block":
-		timeout, _ := strconv.Atoi(cmd.GetArg(1))
-		streamMap := make(map[string][]cache.StreamType)
-		agrsSet := map[string]bool{}
-		needs := len(cmd.GetArgs()[3:]) / 2
-		for _, arg := range cmd.GetArgs()[3: needs + 3] {
-			agrsSet[arg] = true
-		}
-		resposeChan := make(chan string)
-		go handleBlockXREAD(timeout, c, streamMap, agrsSet, resposeChan)
-		return <- resposeChan
-	default:
-		return resp.ToRESPError("Invalid Command")
+func handleTouch(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) < 1 {
+		return resp.ToRESPError(wrongArgs("touch"))
 	}
+	return resp.ToRESPInteger(c.Touch(cmd.GetArgs()))
 }
 
-func handleBlockXREAD(timeout int, cache cache.Cache, streamMap map[string][]cache.StreamType, args map[string]bool, resposeChan chan string) {
-	xread_chan := pubSub.Subscribe("xread")
-	defer pubSub.Unsubscribe("xread")
-	for	{
-		if timeout == 0 {
-			message := <- xread_chan
-			AddToStreamMap(message, cache, streamMap, args)
-			if len(streamMap) == len(args) {
-				resposeChan <- resp.ToRESPStreamWithName(streamMap)
-			}
-		} else {
-			select {
-			case <- time.After(time.Duration(timeout) * time.Millisecond):
-				resposeChan <- resp.ToRESPNullArray()
-			case message := <- xread_chan:
-				AddToStreamMap(message, cache, streamMap, args)
-				if len(streamMap) == len(args) {
-					resposeChan <- resp.ToRESPStreamWithName(streamMap)
-				}
+func handleConfig(cmd command.Command, redis redis.Node) string {
+	if cmd.GetArg(0) == "get" {
+		if cmd.GetArg(1) == "dir" {
+			return resp.ToRESPArray([]string{cmd.GetArg(1), redis.GetRDBDir()})
+		}
+		if value, ok := cache.EncodingConfig(cmd.GetArg(1)); ok {
+			return resp.ToRESPArray([]string{cmd.GetArg(1), strconv.FormatInt(value, 10)})
+		}
+		if value, ok := cache.MemoryConfig(cmd.GetArg(1)); ok {
+			return resp.ToRESPArray([]string{cmd.GetArg(1), value})
+		}
+	}
+	if cmd.GetArg(0) == "set" && len(cmd.GetArgs()) == 3 {
+		if _, ok := cache.MemoryConfig(cmd.GetArg(1)); ok {
+			if err := cache.SetMemoryConfig(cmd.GetArg(1), cmd.GetArg(2)); err != nil {
+				return resp.ToRESPError(err.Error())
 			}
+			return resp.ToRESPSimpleString("OK")
 		}
+		if _, ok := cache.EncodingConfig(cmd.GetArg(1)); !ok {
+			return resp.ToRESPError("ERR Unknown option or number of arguments for CONFIG SET - '" + cmd.GetArg(1) + "'")
+		}
+		value, ok := cache.ParseInt(cmd.GetArg(2))
+		if !ok || !cache.SetEncodingConfig(cmd.GetArg(1), value) {
+			return resp.ToRESPError("ERR Invalid argument '" + cmd.GetArg(2) + "' for CONFIG SET '" + cmd.GetArg(1) + "'")
+		}
+		return resp.ToRESPSimpleString("OK")
 	}
+	return resp.ToRESPError("Invalid Command")
 }
 
-func AddToStreamMap(message PubSubMessage, cache cache.Cache, streamMap map[string][]cache.StreamType, args map[string]bool) {
-	parts := strings.Split(message.Message, "_")
-	key := parts[0]
-	id := parts[1]
-	stream := cache.GetStream(key, id, "+")
-	if len(stream) == 0 {
-		return
-	}
-	if _, ok := args[key]; ok {
-		streamMap[key] = stream
-	}
+func propagate(redis redis.Node, cmd command.Command) {
+	propagateArgs(redis, cmd.CmdToSlice())
 }
 
-func propagate(redis redis.Node, cmd command.Command) {
+// propagateArgs sends a command to the replicas in a form that may differ from
+// what the client sent, e.g. with relative expiries resolved to absolute ones.
+func propagateArgs(redis redis.Node, args []string) {
 	for _, slave := range redis.GetSlaveConn() {
-		slave.Write([]byte(resp.ToRESPArray(cmd.CmdToSlice())))
+		slave.Write([]byte(resp.ToRESPArray(args)))
 	}
 }
 
-func GetStreamMap(streamArgs []string, c cache.Cache) map[string][]cache.StreamType {
-	m := len(streamArgs) / 2
-	streamMap := make(map[string][]cache.StreamType)
-	for i := 0; i < m; i++ {
-		key := streamArgs[i]
-		start := streamArgs[i + m]
-		stream := c.GetStream(key, start, "+")
-		streamMap[key] = stream
+func handleClient(cmd command.Command, client *Client) string {
+	if len(cmd.GetArgs()) < 1 {
+		return resp.ToRESPError(wrongArgs("client"))
+	}
+	switch strings.ToLower(cmd.GetArg(0)) {
+	case "id":
+		return resp.ToRESPInteger(int(client.GetId()))
+	case "unblock":
+		args := cmd.GetArgs()
+		if len(args) < 2 || len(args) > 3 {
+			return resp.ToRESPError(wrongArgs("client|unblock"))
+		}
+		id, err := parseInt(args[1])
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		withError := false
+		if len(args) == 3 {
+			switch strings.ToLower(args[2]) {
+			case "timeout":
+			case "error":
+				withError = true
+			default:
+				return resp.ToRESPError("ERR CLIENT UNBLOCK reason should be TIMEOUT or ERROR")
+			}
+		}
+		target := getClient(id)
+		if target != nil && blocker.Unblock(target, withError) {
+			return resp.ToRESPInteger(1)
+		}
+		return resp.ToRESPInteger(0)
+	case "no-touch":
+		if len(cmd.GetArgs()) != 2 {
+			return resp.ToRESPError(wrongArgs("client|no-touch"))
+		}
+		switch strings.ToLower(cmd.GetArg(1)) {
+		case "on":
+			client.SetNoTouch(true)
+		case "off":
+			client.SetNoTouch(false)
+		default:
+			return resp.ToRESPError(ErrSyntax)
+		}
+		return resp.ToRESPSimpleString("OK")
 	}
-	return streamMap
-}
\ No newline at end of file
+	return resp.ToRESPError("ERR unknown subcommand '" + cmd.GetArg(0) + "'. Try CLIENT HELP.")
+}
@@ -0,0 +1,188 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/resp"
+)
+
+func handleHSet(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	name := cmd.GetName()
+	args := cmd.GetArgs()
+	if len(args) < 3 || len(args)%2 == 0 || (name == command.HSETNX && len(args) != 3) {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	added, err := c.HSet(args[0], args[1:], name == command.HSETNX)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if name == command.HSET || added > 0 {
+		propagate(redis, cmd)
+	}
+	return resp.ToRESPInteger(added)
+}
+
+// handleHGet serves the single-field reads HGET, HEXISTS and HSTRLEN.
+func handleHGet(cmd command.Command, c cache.Cache) string {
+	name := cmd.GetName()
+	if len(cmd.GetArgs()) != 2 {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	values, err := c.HGet(cmd.GetArg(0), cmd.GetArgs()[1:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	value := values[0]
+	switch name {
+	case command.HEXISTS:
+		if value == nil {
+			return resp.ToRESPInteger(0)
+		}
+		return resp.ToRESPInteger(1)
+	case command.HSTRLEN:
+		if value == nil {
+			return resp.ToRESPInteger(0)
+		}
+		return resp.ToRESPInteger(len(*value))
+	}
+	if value == nil {
+		return resp.ToRESPNullBulkString()
+	}
+	return resp.ToRESPBulkString(*value)
+}
+
+func handleHMGet(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) < 2 {
+		return resp.ToRESPError(wrongArgs("hmget"))
+	}
+	values, err := c.HGet(cmd.GetArg(0), cmd.GetArgs()[1:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPNullableArray(values)
+}
+
+func handleHDel(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) < 2 {
+		return resp.ToRESPError(wrongArgs("hdel"))
+	}
+	removed, err := c.HDel(cmd.GetArg(0), cmd.GetArgs()[1:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if removed > 0 {
+		propagate(redis, cmd)
+	}
+	return resp.ToRESPInteger(removed)
+}
+
+// handleHGetAll serves HGETALL, HKEYS and HVALS.
+func handleHGetAll(cmd command.Command, c cache.Cache) string {
+	name := cmd.GetName()
+	if len(cmd.GetArgs()) != 1 {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	pairs, err := c.HGetAll(cmd.GetArg(0))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if name == command.HGETALL {
+		return resp.ToRESPArray(pairs)
+	}
+	offset := 0
+	if name == command.HVALS {
+		offset = 1
+	}
+	half := make([]string, 0, len(pairs)/2)
+	for i := offset; i < len(pairs); i += 2 {
+		half = append(half, pairs[i])
+	}
+	return resp.ToRESPArray(half)
+}
+
+func handleHLen(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) != 1 {
+		return resp.ToRESPError(wrongArgs("hlen"))
+	}
+	length, err := c.HLen(cmd.GetArg(0))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPInteger(length)
+}
+
+func handleHIncrBy(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 3 {
+		return resp.ToRESPError(wrongArgs("hincrby"))
+	}
+	delta, err := parseInt(cmd.GetArg(2))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	value, err := c.HIncrBy(cmd.GetArg(0), cmd.GetArg(1), delta)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPInteger(int(value))
+}
+
+func handleHIncrByFloat(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 3 {
+		return resp.ToRESPError(wrongArgs("hincrbyfloat"))
+	}
+	delta, ok := cache.ParseFloat(cmd.GetArg(2))
+	if !ok {
+		return resp.ToRESPError(cache.ErrNotFloat.Error())
+	}
+	value, err := c.HIncrByFloat(cmd.GetArg(0), cmd.GetArg(1), delta)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagateArgs(redis, []string{"HSET", cmd.GetArg(0), cmd.GetArg(1), value})
+	return resp.ToRESPBulkString(value)
+}
+
+func handleHRandField(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 1 || len(args) > 3 {
+		return resp.ToRESPError(wrongArgs("hrandfield"))
+	}
+	if len(args) == 1 {
+		pairs, err := c.HRandField(args[0], 1)
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		if len(pairs) == 0 {
+			return resp.ToRESPNullBulkString()
+		}
+		return resp.ToRESPBulkString(pairs[0])
+	}
+	count, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || count < -maxRandomRepeats {
+		return resp.ToRESPError("ERR value is out of range")
+	}
+	withValues := false
+	if len(args) == 3 {
+		if strings.ToLower(args[2]) != "withvalues" {
+			return resp.ToRESPError(ErrSyntax)
+		}
+		withValues = true
+	}
+	pairs, err := c.HRandField(args[0], count)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if withValues {
+		return resp.ToRESPArray(pairs)
+	}
+	fields := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		fields = append(fields, pairs[i])
+	}
+	return resp.ToRESPArray(fields)
+}
@@ -0,0 +1,22 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+)
+
+func TestHRandFieldRejectsHugeNegativeCount(t *testing.T) {
+	c := cache.NewCache()
+	c.HSet("hash", []string{"field", "value"}, false)
+	for _, count := range []string{"-2305843009213693952", "-9223372036854775808"} {
+		cmd, err := command.NewCommand([]string{"HRANDFIELD", "hash", count})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := handleHRandField(*cmd, c); res != "-ERR value is out of range\r\n" {
+			t.Fatalf("HRANDFIELD hash %s = %q", count, res)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/resp"
+)
+
+func handlePFAdd(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) < 1 {
+		return resp.ToRESPError(wrongArgs("pfadd"))
+	}
+	changed, err := c.PFAdd(cmd.GetArg(0), cmd.GetArgs()[1:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if !changed {
+		return resp.ToRESPInteger(0)
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPInteger(1)
+}
+
+func handlePFCount(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) < 1 {
+		return resp.ToRESPError(wrongArgs("pfcount"))
+	}
+	count, err := c.PFCount(cmd.GetArgs())
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPInteger(int(count))
+}
+
+func handlePFMerge(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) < 1 {
+		return resp.ToRESPError(wrongArgs("pfmerge"))
+	}
+	if err := c.PFMerge(cmd.GetArg(0), cmd.GetArgs()[1:]); err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPSimpleString("OK")
+}
@@ -0,0 +1,489 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/resp"
+)
+
+// parseSide reads a LEFT|RIGHT argument, reporting whether it is LEFT.
+func parseSide(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "left":
+		return true, true
+	case "right":
+		return false, true
+	}
+	return false, false
+}
+
+// parsePositiveCount reads a COUNT argument that must be greater than zero.
+func parsePositiveCount(value string) (int, error) {
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return 0, errValueOutOfRange
+	}
+	return count, nil
+}
+
+func handlePush(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	name := cmd.GetName()
+	if len(cmd.GetArgs()) < 2 {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	left := name == command.LPUSH || name == command.LPUSHX
+	onlyExisting := name == command.LPUSHX || name == command.RPUSHX
+	length, err := c.Push(cmd.GetArg(0), cmd.GetArgs()[1:], left, onlyExisting)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if length > 0 {
+		propagate(redis, cmd)
+		wakeKey(cmd.GetArg(0))
+	}
+	return resp.ToRESPInteger(length)
+}
+
+// handlePop serves LPOP and RPOP, which reply with a single element unless a
+// count is given.
+func handlePop(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	name := cmd.GetName()
+	args := cmd.GetArgs()
+	if len(args) < 1 || len(args) > 2 {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	count := 1
+	if len(args) == 2 {
+		n, err := parsePositiveCount(args[1])
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		count = n
+	}
+	values, ok, err := c.Pop(args[0], count, name == command.LPOP)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if len(values) > 0 {
+		propagate(redis, cmd)
+	}
+	if len(args) == 2 {
+		if !ok {
+			return resp.ToRESPNullArray()
+		}
+		return resp.ToRESPArray(values)
+	}
+	if len(values) == 0 {
+		return resp.ToRESPNullBulkString()
+	}
+	return resp.ToRESPBulkString(values[0])
+}
+
+func handleLLen(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) != 1 {
+		return resp.ToRESPError(wrongArgs("llen"))
+	}
+	length, err := c.LLen(cmd.GetArg(0))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPInteger(length)
+}
+
+func handleLRange(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) != 3 {
+		return resp.ToRESPError(wrongArgs("lrange"))
+	}
+	start, err := parseInt(cmd.GetArg(1))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	stop, err := parseInt(cmd.GetArg(2))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	values, err := c.LRange(cmd.GetArg(0), start, stop)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPArray(values)
+}
+
+func handleLIndex(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) != 2 {
+		return resp.ToRESPError(wrongArgs("lindex"))
+	}
+	index, err := parseInt(cmd.GetArg(1))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	value, ok, err := c.LIndex(cmd.GetArg(0), index)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if !ok {
+		return resp.ToRESPNullBulkString()
+	}
+	return resp.ToRESPBulkString(value)
+}
+
+func handleLSet(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 3 {
+		return resp.ToRESPError(wrongArgs("lset"))
+	}
+	index, err := parseInt(cmd.GetArg(1))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if err := c.LSet(cmd.GetArg(0), index, cmd.GetArg(2)); err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPSimpleString("OK")
+}
+
+func handleLRem(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 3 {
+		return resp.ToRESPError(wrongArgs("lrem"))
+	}
+	count, err := parseInt(cmd.GetArg(1))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	removed, err := c.LRem(cmd.GetArg(0), count, cmd.GetArg(2))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if removed > 0 {
+		propagate(redis, cmd)
+	}
+	return resp.ToRESPInteger(removed)
+}
+
+func handleLTrim(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 3 {
+		return resp.ToRESPError(wrongArgs("ltrim"))
+	}
+	start, err := parseInt(cmd.GetArg(1))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	stop, err := parseInt(cmd.GetArg(2))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if err := c.LTrim(cmd.GetArg(0), start, stop); err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPSimpleString("OK")
+}
+
+func handleLInsert(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 4 {
+		return resp.ToRESPError(wrongArgs("linsert"))
+	}
+	where := strings.ToLower(cmd.GetArg(1))
+	if where != "before" && where != "after" {
+		return resp.ToRESPError(ErrSyntax)
+	}
+	length, err := c.LInsert(cmd.GetArg(0), where == "before", cmd.GetArg(2), cmd.GetArg(3))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if length > 0 {
+		propagate(redis, cmd)
+	}
+	return resp.ToRESPInteger(length)
+}
+
+func handleLPos(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 2 {
+		return resp.ToRESPError(wrongArgs("lpos"))
+	}
+	rank, count, maxLen := int64(1), int64(-1), int64(0)
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return resp.ToRESPError(ErrSyntax)
+		}
+		n, err := parseInt(args[i+1])
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		switch strings.ToLower(args[i]) {
+		case "rank":
+			if n == 0 {
+				return resp.ToRESPError("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+			}
+			rank = n
+		case "count":
+			if n < 0 {
+				return resp.ToRESPError("ERR COUNT can't be negative")
+			}
+			count = n
+		case "maxlen":
+			if n < 0 {
+				return resp.ToRESPError("ERR MAXLEN can't be negative")
+			}
+			maxLen = n
+		default:
+			return resp.ToRESPError(ErrSyntax)
+		}
+	}
+	single := count < 0
+	if single {
+		count = 1
+	}
+	matches, err := c.LPos(args[0], args[1], rank, count, maxLen)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if single {
+		if len(matches) == 0 {
+			return resp.ToRESPNullBulkString()
+		}
+		return resp.ToRESPInteger(int(matches[0]))
+	}
+	res := resp.ToRESPArrayLen(len(matches))
+	for _, match := range matches {
+		res += resp.ToRESPInteger(int(match))
+	}
+	return res
+}
+
+// handleLMove serves LMOVE and RPOPLPUSH, its RIGHT LEFT special case.
+func handleLMove(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	name := cmd.GetName()
+	args := cmd.GetArgs()
+	fromLeft, toLeft := false, true
+	if name == command.RPOPLPUSH {
+		if len(args) != 2 {
+			return resp.ToRESPError(wrongArgs(name))
+		}
+	} else {
+		if len(args) != 4 {
+			return resp.ToRESPError(wrongArgs(name))
+		}
+		var ok1, ok2 bool
+		fromLeft, ok1 = parseSide(args[2])
+		toLeft, ok2 = parseSide(args[3])
+		if !ok1 || !ok2 {
+			return resp.ToRESPError(ErrSyntax)
+		}
+	}
+	value, ok, err := c.LMove(args[0], args[1], fromLeft, toLeft)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if !ok {
+		return resp.ToRESPNullBulkString()
+	}
+	propagate(redis, cmd)
+	wakeKey(args[1])
+	return resp.ToRESPBulkString(value)
+}
+
+// parseMPop reads the numkeys key [key ...] LEFT|RIGHT [COUNT count] tail of
+// LMPOP and BLMPOP.
+func parseMPop(args []string) ([]string, bool, int, string) {
+	if len(args) < 3 {
+		return nil, false, 0, ""
+	}
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil || numKeys <= 0 {
+		return nil, false, 0, "ERR numkeys should be greater than 0"
+	}
+	if len(args) < numKeys+2 {
+		return nil, false, 0, ErrSyntax
+	}
+	keys := args[1 : numKeys+1]
+	left, ok := parseSide(args[numKeys+1])
+	if !ok {
+		return nil, false, 0, ErrSyntax
+	}
+	count := 1
+	rest := args[numKeys+2:]
+	if len(rest) > 0 {
+		if len(rest) != 2 || strings.ToLower(rest[0]) != "count" {
+			return nil, false, 0, ErrSyntax
+		}
+		count, err = strconv.Atoi(rest[1])
+		if err != nil || count <= 0 {
+			return nil, false, 0, "ERR count should be greater than 0"
+		}
+	}
+	return keys, left, count, ""
+}
+
+func mpopReply(key string, values []string) string {
+	return resp.ToRESPArrayLen(2) + resp.ToRESPBulkString(key) + resp.ToRESPArray(values)
+}
+
+func handleLMPop(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	keys, left, count, errMsg := parseMPop(cmd.GetArgs())
+	if keys == nil {
+		if errMsg == "" {
+			errMsg = wrongArgs("lmpop")
+		}
+		return resp.ToRESPError(errMsg)
+	}
+	key, values, err := c.LMPop(keys, left, count)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if key == "" {
+		return resp.ToRESPNullArray()
+	}
+	propagatePop(redis, key, left, len(values))
+	return mpopReply(key, values)
+}
+
+// propagatePop sends replicas the plain LPOP/RPOP equivalent of a pop that
+// chose its key at run time.
+func propagatePop(redis redis.Node, key string, left bool, count int) {
+	name := "RPOP"
+	if left {
+		name = "LPOP"
+	}
+	propagateArgs(redis, []string{name, key, strconv.Itoa(count)})
+}
+
+// unpop pushes values popped from key back where they came from, the first
+// popped ending up first again, for a blocked pop whose client disconnected
+// before it got them.
+func unpop(c cache.Cache, redis redis.Node, key string, values []string, left bool) {
+	if key == "" {
+		return
+	}
+	reversed := make([]string, len(values))
+	for i, value := range values {
+		reversed[len(values)-1-i] = value
+	}
+	if _, err := c.Push(key, reversed, left, false); err != nil {
+		return
+	}
+	name := "RPUSH"
+	if left {
+		name = "LPUSH"
+	}
+	propagateArgs(redis, append([]string{name, key}, reversed...))
+}
+
+// handleBPop serves BLPOP and BRPOP, popping from the first non-empty list
+// among the keys or blocking until one receives an element.
+func handleBPop(cmd command.Command, c cache.Cache, redis redis.Node, client *Client) string {
+	name := cmd.GetName()
+	args := cmd.GetArgs()
+	if len(args) < 2 {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	timeout, err := parseTimeout(args[len(args)-1], true)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	keys := args[:len(args)-1]
+	left := name == command.BLPOP
+	var popped string
+	var values []string
+	try := func() (string, bool) {
+		key, res, err := c.LMPop(keys, left, 1)
+		if err != nil {
+			return resp.ToRESPError(err.Error()), true
+		}
+		if key == "" {
+			return "", false
+		}
+		propagatePop(redis, key, left, 1)
+		popped, values = key, res
+		return resp.ToRESPArray([]string{key, res[0]}), true
+	}
+	undo := func() { unpop(c, redis, popped, values, left) }
+	return blocker.Block(client, keys, timeout, try, undo, resp.ToRESPNullArray())
+}
+
+// handleBLMove serves BLMOVE and BRPOPLPUSH, its RIGHT LEFT special case.
+func handleBLMove(cmd command.Command, c cache.Cache, redis redis.Node, client *Client) string {
+	name := cmd.GetName()
+	args := cmd.GetArgs()
+	fromLeft, toLeft := false, true
+	if name == command.BRPOPLPUSH {
+		if len(args) != 3 {
+			return resp.ToRESPError(wrongArgs(name))
+		}
+	} else {
+		if len(args) != 5 {
+			return resp.ToRESPError(wrongArgs(name))
+		}
+		var ok1, ok2 bool
+		fromLeft, ok1 = parseSide(args[2])
+		toLeft, ok2 = parseSide(args[3])
+		if !ok1 || !ok2 {
+			return resp.ToRESPError(ErrSyntax)
+		}
+	}
+	timeout, err := parseTimeout(args[len(args)-1], true)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	src, dst := args[0], args[1]
+	try := func() (string, bool) {
+		value, ok, err := c.LMove(src, dst, fromLeft, toLeft)
+		if err != nil {
+			return resp.ToRESPError(err.Error()), true
+		}
+		if !ok {
+			return "", false
+		}
+		propagateArgs(redis, []string{"LMOVE", src, dst, sideName(fromLeft), sideName(toLeft)})
+		blocker.signal(dst)
+		return resp.ToRESPBulkString(value), true
+	}
+	// A moved element stays in dst even if the reply is lost.
+	return blocker.Block(client, []string{src}, timeout, try, nil, resp.ToRESPNullArray())
+}
+
+func sideName(left bool) string {
+	if left {
+		return "LEFT"
+	}
+	return "RIGHT"
+}
+
+func handleBLMPop(cmd command.Command, c cache.Cache, redis redis.Node, client *Client) string {
+	args := cmd.GetArgs()
+	if len(args) < 1 {
+		return resp.ToRESPError(wrongArgs("blmpop"))
+	}
+	timeout, err := parseTimeout(args[0], true)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	keys, left, count, errMsg := parseMPop(args[1:])
+	if keys == nil {
+		if errMsg == "" {
+			errMsg = wrongArgs("blmpop")
+		}
+		return resp.ToRESPError(errMsg)
+	}
+	var popped string
+	var values []string
+	try := func() (string, bool) {
+		key, res, err := c.LMPop(keys, left, count)
+		if err != nil {
+			return resp.ToRESPError(err.Error()), true
+		}
+		if key == "" {
+			return "", false
+		}
+		propagatePop(redis, key, left, len(res))
+		popped, values = key, res
+		return mpopReply(key, res), true
+	}
+	undo := func() { unpop(c, redis, popped, values, left) }
+	return blocker.Block(client, keys, timeout, try, undo, resp.ToRESPNullArray())
+}
@@ -0,0 +1,220 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/resp"
+)
+
+func handleSAdd(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) < 2 {
+		return resp.ToRESPError(wrongArgs("sadd"))
+	}
+	added, err := c.SAdd(cmd.GetArg(0), cmd.GetArgs()[1:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if added > 0 {
+		propagate(redis, cmd)
+	}
+	return resp.ToRESPInteger(added)
+}
+
+func handleSRem(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) < 2 {
+		return resp.ToRESPError(wrongArgs("srem"))
+	}
+	removed, err := c.SRem(cmd.GetArg(0), cmd.GetArgs()[1:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if removed > 0 {
+		propagate(redis, cmd)
+	}
+	return resp.ToRESPInteger(removed)
+}
+
+func handleSMembers(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) != 1 {
+		return resp.ToRESPError(wrongArgs("smembers"))
+	}
+	members, err := c.SMembers(cmd.GetArg(0))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPArray(members)
+}
+
+// handleSIsMember serves SISMEMBER and SMISMEMBER.
+func handleSIsMember(cmd command.Command, c cache.Cache) string {
+	name := cmd.GetName()
+	args := cmd.GetArgs()
+	if len(args) < 2 || (name == command.SISMEMBER && len(args) != 2) {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	found, err := c.SIsMember(args[0], args[1:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	res := ""
+	for _, ok := range found {
+		if ok {
+			res += resp.ToRESPInteger(1)
+		} else {
+			res += resp.ToRESPInteger(0)
+		}
+	}
+	if name == command.SISMEMBER {
+		return res
+	}
+	return resp.ToRESPArrayLen(len(found)) + res
+}
+
+func handleSCard(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) != 1 {
+		return resp.ToRESPError(wrongArgs("scard"))
+	}
+	card, err := c.SCard(cmd.GetArg(0))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPInteger(card)
+}
+
+func handleSPop(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 1 || len(args) > 2 {
+		return resp.ToRESPError(wrongArgs("spop"))
+	}
+	count := 1
+	if len(args) == 2 {
+		n, err := parsePositiveCount(args[1])
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		count = n
+	}
+	members, err := c.SPop(args[0], count)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if len(members) > 0 {
+		// The members were picked at random, so replicas remove them by name.
+		propagateArgs(redis, append([]string{"SREM", args[0]}, members...))
+	}
+	if len(args) == 2 {
+		return resp.ToRESPArray(members)
+	}
+	if len(members) == 0 {
+		return resp.ToRESPNullBulkString()
+	}
+	return resp.ToRESPBulkString(members[0])
+}
+
+func handleSRandMember(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 1 || len(args) > 2 {
+		return resp.ToRESPError(wrongArgs("srandmember"))
+	}
+	if len(args) == 1 {
+		members, err := c.SRandMember(args[0], 1)
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		if len(members) == 0 {
+			return resp.ToRESPNullBulkString()
+		}
+		return resp.ToRESPBulkString(members[0])
+	}
+	count, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || count < -maxRandomRepeats {
+		return resp.ToRESPError("ERR value is out of range")
+	}
+	members, err := c.SRandMember(args[0], count)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPArray(members)
+}
+
+func handleSMove(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 3 {
+		return resp.ToRESPError(wrongArgs("smove"))
+	}
+	moved, err := c.SMove(cmd.GetArg(0), cmd.GetArg(1), cmd.GetArg(2))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if !moved {
+		return resp.ToRESPInteger(0)
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPInteger(1)
+}
+
+// setOpName maps SINTER/SUNION/SDIFF and their STORE variants to the
+// operation they perform.
+func setOpName(name string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(name, "s"), "store")
+}
+
+// handleSetOp serves SINTER, SUNION and SDIFF.
+func handleSetOp(cmd command.Command, c cache.Cache) string {
+	name := cmd.GetName()
+	if len(cmd.GetArgs()) < 1 {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	members, err := c.SetOp(setOpName(name), cmd.GetArgs())
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPArray(members)
+}
+
+// handleSetOpStore serves SINTERSTORE, SUNIONSTORE and SDIFFSTORE.
+func handleSetOpStore(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	name := cmd.GetName()
+	if len(cmd.GetArgs()) < 2 {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	card, err := c.SetOpStore(setOpName(name), cmd.GetArg(0), cmd.GetArgs()[1:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPInteger(card)
+}
+
+func handleSInterCard(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 2 {
+		return resp.ToRESPError(wrongArgs("sintercard"))
+	}
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil || numKeys <= 0 {
+		return resp.ToRESPError("ERR numkeys should be greater than 0")
+	}
+	if numKeys > len(args)-1 {
+		return resp.ToRESPError("ERR Number of keys can't be greater than number of args")
+	}
+	limit := 0
+	rest := args[numKeys+1:]
+	if len(rest) > 0 {
+		if len(rest) != 2 || strings.ToLower(rest[0]) != "limit" {
+			return resp.ToRESPError(ErrSyntax)
+		}
+		limit, err = strconv.Atoi(rest[1])
+		if err != nil || limit < 0 {
+			return resp.ToRESPError("ERR LIMIT can't be negative")
+		}
+	}
+	card, err := c.SInterCard(args[1:numKeys+1], limit)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPInteger(card)
+}
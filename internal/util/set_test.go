@@ -0,0 +1,22 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+)
+
+func TestSRandMemberRejectsHugeNegativeCount(t *testing.T) {
+	c := cache.NewCache()
+	c.SAdd("set", []string{"a", "b"})
+	for _, count := range []string{"-2305843009213693952", "-9223372036854775808"} {
+		cmd, err := command.NewCommand([]string{"SRANDMEMBER", "set", count})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := handleSRandMember(*cmd, c); res != "-ERR value is out of range\r\n" {
+			t.Fatalf("SRANDMEMBER set %s = %q", count, res)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/resp"
+)
+
+// handleSort serves SORT and, with readOnly set, SORT_RO, which refuses
+// STORE so that it can run on replicas.
+func handleSort(cmd command.Command, c cache.Cache, redis redis.Node, readOnly bool) string {
+	args := cmd.GetArgs()
+	if len(args) < 1 {
+		return resp.ToRESPError(wrongArgs(cmd.GetName()))
+	}
+	opts := cache.SortOptions{Count: -1}
+	dest := ""
+	for i := 1; i < len(args); i++ {
+		switch option := strings.ToLower(args[i]); {
+		case option == "asc":
+			opts.Desc = false
+		case option == "desc":
+			opts.Desc = true
+		case option == "alpha":
+			opts.Alpha = true
+		case option == "by" && i+1 < len(args):
+			opts.By = args[i+1]
+			i++
+		case option == "get" && i+1 < len(args):
+			opts.Get = append(opts.Get, args[i+1])
+			i++
+		case option == "limit" && i+2 < len(args):
+			offset, err := parseInt(args[i+1])
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			count, err := parseInt(args[i+2])
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			opts.Offset, opts.Count = offset, count
+			i += 2
+		case option == "store" && i+1 < len(args) && !readOnly:
+			dest = args[i+1]
+			i++
+		default:
+			return resp.ToRESPError(ErrSyntax)
+		}
+	}
+	if dest == "" {
+		result, err := c.Sort(args[0], opts)
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		return resp.ToRESPNullableArray(result)
+	}
+	n, err := c.SortStore(args[0], dest, opts)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagate(redis, cmd)
+	if n > 0 {
+		wakeKey(dest)
+	}
+	return resp.ToRESPInteger(n)
+}
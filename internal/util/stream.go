@@ -0,0 +1,410 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/resp"
+)
+
+var errUnbalancedStreams = fmt.Errorf("ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.")
+
+// parseXAddOptions reads the trimming options of XTRIM, or the options of
+// XADD when xadd is set, in which case it stops at the entry ID. It returns
+// the options and how many arguments they took.
+func parseXAddOptions(args []string, xadd bool) (cache.XAddOptions, int, error) {
+	opts := cache.XAddOptions{}
+	limit := int64(-1)
+	i := 0
+options:
+	for ; i < len(args); i++ {
+		option := strings.ToLower(args[i])
+		left := len(args) - i - 1
+		switch {
+		case option == "nomkstream" && xadd:
+			opts.NoMkStream = true
+		case (option == "maxlen" || option == "minid") && left >= 1:
+			minID := option == "minid"
+			if opts.Trim != nil && opts.Trim.MinID != minID {
+				return opts, 0, fmt.Errorf("ERR syntax error, MAXLEN and MINID options at the same time are not compatible")
+			}
+			opts.Trim = &cache.StreamTrim{MinID: minID}
+			if (args[i+1] == "~" || args[i+1] == "=") && left >= 2 {
+				opts.Trim.Approx = args[i+1] == "~"
+				i++
+			}
+			i++
+			if minID {
+				threshold, err := cache.ParseStreamID(args[i], 0)
+				if err != nil {
+					return opts, 0, err
+				}
+				opts.Trim.Threshold = threshold
+			} else {
+				maxLen, err := parseInt(args[i])
+				if err != nil {
+					return opts, 0, err
+				}
+				if maxLen < 0 {
+					return opts, 0, fmt.Errorf("ERR The MAXLEN argument must be >= 0.")
+				}
+				opts.Trim.MaxLen = maxLen
+			}
+		case option == "limit" && left >= 1:
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				return opts, 0, err
+			}
+			if n < 0 {
+				return opts, 0, fmt.Errorf("ERR The LIMIT argument must be >= 0.")
+			}
+			limit = n
+			i++
+		case xadd:
+			break options
+		default:
+			return opts, 0, fmt.Errorf(ErrSyntax)
+		}
+	}
+	if limit >= 0 {
+		if opts.Trim == nil || !opts.Trim.Approx {
+			return opts, 0, fmt.Errorf("ERR syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		opts.Trim.Limit = limit
+	} else if opts.Trim != nil {
+		opts.Trim.Limit = -1
+	}
+	return opts, i, nil
+}
+
+func handleXADD(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 4 {
+		return resp.ToRESPError(wrongArgs("xadd"))
+	}
+	opts, n, err := parseXAddOptions(args[1:], true)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	rest := args[1+n:]
+	if len(rest) < 3 || len(rest)%2 != 1 {
+		return resp.ToRESPError(wrongArgs("xadd"))
+	}
+	id, ok, err := c.XAdd(args[0], rest[0], rest[1:], opts)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if !ok {
+		return resp.ToRESPNullBulkString()
+	}
+	// Replicas must store the entry under the ID generated here.
+	propagated := append([]string{"XADD"}, args[:1+n]...)
+	propagated = append(append(propagated, id.String()), rest[1:]...)
+	propagateArgs(redis, propagated)
+	wakeKey(args[0])
+	return resp.ToRESPBulkString(id.String())
+}
+
+func handleXTRIM(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 3 {
+		return resp.ToRESPError(wrongArgs("xtrim"))
+	}
+	opts, _, err := parseXAddOptions(args[1:], false)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if opts.Trim == nil {
+		return resp.ToRESPError(ErrSyntax)
+	}
+	removed, err := c.XTrim(args[0], *opts.Trim)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if removed > 0 {
+		propagate(redis, cmd)
+	}
+	return resp.ToRESPInteger(removed)
+}
+
+func handleXDEL(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 2 {
+		return resp.ToRESPError(wrongArgs("xdel"))
+	}
+	ids := make([]cache.StreamID, len(args)-1)
+	for i, arg := range args[1:] {
+		id, err := cache.ParseStreamID(arg, 0)
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		ids[i] = id
+	}
+	deleted, err := c.XDel(args[0], ids)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if deleted > 0 {
+		propagate(redis, cmd)
+	}
+	return resp.ToRESPInteger(deleted)
+}
+
+func handleXLEN(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) != 1 {
+		return resp.ToRESPError(wrongArgs("xlen"))
+	}
+	length, err := c.XLen(cmd.GetArg(0))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPInteger(length)
+}
+
+func handleXSETID(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 2 {
+		return resp.ToRESPError(wrongArgs("xsetid"))
+	}
+	lastID, err := cache.ParseStreamID(args[1], 0)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	var entriesAdded *uint64
+	var maxDeletedID *cache.StreamID
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return resp.ToRESPError(ErrSyntax)
+		}
+		switch strings.ToLower(args[i]) {
+		case "entriesadded":
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			if n < 0 {
+				return resp.ToRESPError("ERR entries_added must be positive")
+			}
+			added := uint64(n)
+			entriesAdded = &added
+		case "maxdeletedid":
+			id, err := cache.ParseStreamID(args[i+1], 0)
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			maxDeletedID = &id
+		default:
+			return resp.ToRESPError(ErrSyntax)
+		}
+	}
+	if err := c.XSetID(args[0], lastID, entriesAdded, maxDeletedID); err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPSimpleString("OK")
+}
+
+// handleXCONFIG serves XCONFIG SET key RETENTION ms, which sets how long
+// the entries of a stream are kept, and XCONFIG GET key RETENTION.
+func handleXCONFIG(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 3 || strings.ToLower(args[2]) != "retention" {
+		return resp.ToRESPError(wrongArgs("xconfig"))
+	}
+	switch subcommand := strings.ToLower(args[0]); {
+	case subcommand == "get" && len(args) == 3:
+		retention, err := c.XConfigGet(args[1])
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		return resp.ToRESPArrayLen(2) + resp.ToRESPBulkString("retention") + resp.ToRESPInteger(int(retention))
+	case subcommand == "set" && len(args) == 4:
+		retention, err := parseInt(args[3])
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		if retention < -1 {
+			return resp.ToRESPError(errValueOutOfRange.Error())
+		}
+		if err := c.XConfigSet(args[1], retention); err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		propagate(redis, cmd)
+		return resp.ToRESPSimpleString("OK")
+	}
+	return resp.ToRESPError(fmt.Sprintf("ERR unknown subcommand or wrong number of arguments for '%s'", args[0]))
+}
+
+// handleXRANGE serves XRANGE and XREVRANGE, which takes its bounds the other
+// way round.
+func handleXRANGE(cmd command.Command, c cache.Cache) string {
+	name := cmd.GetName()
+	args := cmd.GetArgs()
+	if len(args) < 3 {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	rev := name == command.XREVRANGE
+	startArg, endArg := args[1], args[2]
+	if rev {
+		startArg, endArg = endArg, startArg
+	}
+	count := -1
+	for i := 3; i < len(args); i += 2 {
+		if strings.ToLower(args[i]) != "count" || i+1 >= len(args) {
+			return resp.ToRESPError(ErrSyntax)
+		}
+		n, err := parseInt(args[i+1])
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		count = int(max(n, 0))
+	}
+	start, err := cache.ParseStreamBound(startArg, false)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	end, err := cache.ParseStreamBound(endArg, true)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	stream, err := c.XRange(args[0], start, end, count, rev)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToStreamRESPArray(stream)
+}
+
+// streamRead is one stream an XREAD reads from, after the given ID.
+type streamRead struct {
+	key string
+	after cache.StreamID
+}
+
+// parseStreamReads pairs the keys following STREAMS with their IDs. "$"
+// stands for the last ID the stream generated, so only new entries are read,
+// and "+" for the ID just before its last entry, so that entry is read too.
+func parseStreamReads(c cache.Cache, streamArgs []string) ([]streamRead, error) {
+	if len(streamArgs) == 0 || len(streamArgs)%2 != 0 {
+		return nil, errUnbalancedStreams
+	}
+	n := len(streamArgs) / 2
+	reads := make([]streamRead, n)
+	for i, key := range streamArgs[:n] {
+		reads[i].key = key
+		arg := streamArgs[n+i]
+		if arg != "$" && arg != "+" {
+			id, err := cache.ParseStreamID(arg, 0)
+			if err != nil {
+				return nil, err
+			}
+			reads[i].after = id
+			continue
+		}
+		info, err := c.XInfo(key)
+		if err != nil {
+			return nil, err
+		}
+		if info == nil {
+			continue
+		}
+		reads[i].after = info.LastID
+		if arg == "+" && info.Last != nil {
+			reads[i].after, _ = info.Last.Id.Prev()
+		}
+	}
+	return reads, nil
+}
+
+// readStreams returns the reply to an XREAD covering the streams that have
+// entries after their ID, and false when none has.
+func readStreams(c cache.Cache, reads []streamRead, count int) (string, bool, error) {
+	names := []string{}
+	streams := [][]cache.StreamType{}
+	for _, read := range reads {
+		start, ok := read.after.Next()
+		if !ok {
+			continue
+		}
+		entries, err := c.XRange(read.key, start, cache.MaxStreamID, count, false)
+		if err != nil {
+			return "", false, err
+		}
+		if len(entries) > 0 {
+			names = append(names, read.key)
+			streams = append(streams, entries)
+		}
+	}
+	if len(names) == 0 {
+		return "", false, nil
+	}
+	return resp.ToRESPStreamWithName(names, streams), true, nil
+}
+
+func handleXREAD(cmd command.Command, c cache.Cache, client *Client) string {
+	args := cmd.GetArgs()
+	count := -1
+	block := false
+	var timeout time.Duration
+	i := 0
+	for ; i < len(args); i++ {
+		option := strings.ToLower(args[i])
+		if option == "streams" {
+			break
+		}
+		if i+1 >= len(args) {
+			return resp.ToRESPError(ErrSyntax)
+		}
+		switch option {
+		case "count":
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			if n > 0 {
+				count = int(n)
+			}
+		case "block":
+			t, err := parseTimeout(args[i+1], false)
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			block, timeout = true, t
+		default:
+			return resp.ToRESPError(ErrSyntax)
+		}
+		i++
+	}
+	if i == len(args) {
+		return resp.ToRESPError(ErrSyntax)
+	}
+	reads, err := parseStreamReads(c, args[i+1:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if !block {
+		res, ok, err := readStreams(c, reads, count)
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		if !ok {
+			return resp.ToRESPNullArray()
+		}
+		return res
+	}
+	keys := make([]string, len(reads))
+	for j, read := range reads {
+		keys[j] = read.key
+	}
+	try := func() (string, bool) {
+		res, ok, err := readStreams(c, reads, count)
+		if err != nil {
+			return resp.ToRESPError(err.Error()), true
+		}
+		return res, ok
+	}
+	return blocker.Block(client, keys, timeout, try, nil, resp.ToRESPNullArray())
+}
@@ -0,0 +1,650 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/resp"
+)
+
+// groupError spells out cache.ErrNoGroup the way the command reports it.
+func groupError(err error, format string, args ...any) string {
+	if err == cache.ErrNoGroup {
+		return resp.ToRESPError(fmt.Sprintf(format, args...))
+	}
+	return resp.ToRESPError(err.Error())
+}
+
+// parseEntriesRead reads the value of an ENTRIESREAD option.
+func parseEntriesRead(value string) (int64, error) {
+	n, err := parseInt(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < -1 {
+		return 0, fmt.Errorf("ERR value for ENTRIESREAD must be positive or -1")
+	}
+	return n, nil
+}
+
+func handleXGROUP(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 1 {
+		return resp.ToRESPError(wrongArgs("xgroup"))
+	}
+	subcommand := strings.ToLower(args[0])
+	switch {
+	case (subcommand == "create" && len(args) >= 4 && len(args) <= 7) || (subcommand == "setid" && len(args) >= 4 && len(args) <= 6):
+		key, group, id := args[1], args[2], args[3]
+		mkStream := false
+		entriesRead := int64(-1)
+		for i := 4; i < len(args); i++ {
+			switch option := strings.ToLower(args[i]); {
+			case option == "mkstream" && subcommand == "create":
+				mkStream = true
+			case option == "entriesread" && i+1 < len(args):
+				n, err := parseEntriesRead(args[i+1])
+				if err != nil {
+					return resp.ToRESPError(err.Error())
+				}
+				entriesRead = n
+				i++
+			default:
+				return resp.ToRESPError(ErrSyntax)
+			}
+		}
+		var err error
+		if subcommand == "create" {
+			err = c.XGroupCreate(key, group, id, mkStream, entriesRead)
+		} else {
+			err = c.XGroupSetID(key, group, id, entriesRead)
+		}
+		if err != nil {
+			return groupError(err, "NOGROUP No such consumer group '%s' for key name '%s'", group, key)
+		}
+		propagate(redis, cmd)
+		return resp.ToRESPSimpleString("OK")
+	case subcommand == "destroy" && len(args) == 3:
+		destroyed, err := c.XGroupDestroy(args[1], args[2])
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		if !destroyed {
+			return resp.ToRESPInteger(0)
+		}
+		propagate(redis, cmd)
+		// Clients blocked reading from the group now fail.
+		wakeKey(args[1])
+		return resp.ToRESPInteger(1)
+	case subcommand == "createconsumer" && len(args) == 4:
+		created, err := c.XGroupCreateConsumer(args[1], args[2], args[3])
+		if err != nil {
+			return groupError(err, "NOGROUP No such consumer group '%s' for key name '%s'", args[2], args[1])
+		}
+		if !created {
+			return resp.ToRESPInteger(0)
+		}
+		propagate(redis, cmd)
+		return resp.ToRESPInteger(1)
+	case subcommand == "delconsumer" && len(args) == 4:
+		pending, err := c.XGroupDelConsumer(args[1], args[2], args[3])
+		if err != nil {
+			return groupError(err, "NOGROUP No such consumer group '%s' for key name '%s'", args[2], args[1])
+		}
+		propagate(redis, cmd)
+		return resp.ToRESPInteger(pending)
+	case subcommand == "help" && len(args) == 1:
+		return resp.ToRESPArray([]string{
+			"XGROUP <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"CREATE <key> <groupname> <id|$> [option]",
+			"    Create a new consumer group. Options are:",
+			"    * MKSTREAM",
+			"      Create the empty stream if it does not exist.",
+			"    * ENTRIESREAD entries_read",
+			"      Set the group's entries_read counter (internal use).",
+			"CREATECONSUMER <key> <groupname> <consumer>",
+			"    Create a new consumer in the specified group.",
+			"DELCONSUMER <key> <groupname> <consumer>",
+			"    Remove the specified consumer.",
+			"DESTROY <key> <groupname>",
+			"    Remove the specified group.",
+			"SETID <key> <groupname> <id|$> [ENTRIESREAD entries_read]",
+			"    Set the current group ID and entries_read counter.",
+			"HELP",
+			"    Print this help.",
+		})
+	}
+	return resp.ToRESPError(fmt.Sprintf("ERR unknown subcommand or wrong number of arguments for '%s'. Try XGROUP HELP.", args[0]))
+}
+
+// propagateGroupRead replicates the effect of XREADGROUP: new entries become
+// pending on the replicas through XCLAIM, unless NOACK is given, then
+// XGROUP SETID moves the last ID and entries read of the group, as Redis's
+// streamPropagateGroupID does.
+func propagateGroupRead(redis redis.Node, key, group, consumer string, read cache.GroupRead, noAck bool) {
+	if !noAck {
+		for _, entry := range read.Entries {
+			propagateArgs(redis, []string{"XCLAIM", key, group, consumer, "0", entry.Id.String(),
+				"TIME", strconv.FormatInt(read.Time, 10), "RETRYCOUNT", "1", "FORCE", "JUSTID", "LASTID", read.LastID.String()})
+		}
+	}
+	// XCLAIM moves the last delivered ID but not the entries read counter.
+	propagateArgs(redis, []string{"XGROUP", "SETID", key, group, read.LastID.String(), "ENTRIESREAD", strconv.FormatInt(read.EntriesRead, 10)})
+}
+
+func handleXREADGROUP(cmd command.Command, c cache.Cache, redis redis.Node, client *Client) string {
+	args := cmd.GetArgs()
+	count := -1
+	block, noAck := false, false
+	var timeout time.Duration
+	group, consumer := "", ""
+	i := 0
+	for ; i < len(args); i++ {
+		option := strings.ToLower(args[i])
+		left := len(args) - i - 1
+		if option == "streams" {
+			break
+		}
+		switch {
+		case option == "group" && left >= 2:
+			group, consumer = args[i+1], args[i+2]
+			i += 2
+		case option == "count" && left >= 1:
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			if n > 0 {
+				count = int(n)
+			}
+			i++
+		case option == "block" && left >= 1:
+			t, err := parseTimeout(args[i+1], false)
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			block, timeout = true, t
+			i++
+		case option == "noack":
+			noAck = true
+		default:
+			return resp.ToRESPError(ErrSyntax)
+		}
+	}
+	if i == len(args) {
+		return resp.ToRESPError(ErrSyntax)
+	}
+	if group == "" {
+		return resp.ToRESPError("ERR Missing GROUP option for XREADGROUP")
+	}
+	streamArgs := args[i+1:]
+	if len(streamArgs) == 0 || len(streamArgs)%2 != 0 {
+		return resp.ToRESPError("ERR Unbalanced 'xreadgroup' list of streams: for each stream key an ID or '>' must be specified.")
+	}
+	n := len(streamArgs) / 2
+	keys := streamArgs[:n]
+	queries := make([]cache.GroupReadQuery, n)
+	for j, arg := range streamArgs[n:] {
+		queries[j].Key = keys[j]
+		switch arg {
+		case ">":
+			continue
+		case "$":
+			return resp.ToRESPError("ERR The $ ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set.")
+		}
+		id, err := cache.ParseStreamID(arg, 0)
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		queries[j].ID, queries[j].History = id, true
+		// Reading the history never blocks.
+		block = false
+	}
+	try := func() (string, bool) {
+		reads, failed, err := c.XReadGroup(queries, group, consumer, count, noAck)
+		if err != nil {
+			return groupError(err, "NOGROUP No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option", keys[failed], group), true
+		}
+		names := []string{}
+		streams := [][]cache.StreamType{}
+		for j, read := range reads {
+			q := queries[j]
+			if q.History || len(read.Entries) > 0 {
+				names = append(names, q.Key)
+				streams = append(streams, read.Entries)
+			}
+			if !q.History && len(read.Entries) > 0 {
+				propagateGroupRead(redis, q.Key, group, consumer, read, noAck)
+			}
+		}
+		if len(names) == 0 {
+			return resp.ToRESPNullArray(), !block
+		}
+		return resp.ToRESPStreamWithName(names, streams), true
+	}
+	if !block {
+		res, _ := try()
+		return res
+	}
+	return blocker.Block(client, keys, timeout, try, nil, resp.ToRESPNullArray())
+}
+
+// parseStreamIDs reads a list of stream IDs.
+func parseStreamIDs(args []string) ([]cache.StreamID, error) {
+	ids := make([]cache.StreamID, len(args))
+	for i, arg := range args {
+		id, err := cache.ParseStreamID(arg, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func handleXACK(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 3 {
+		return resp.ToRESPError(wrongArgs("xack"))
+	}
+	ids, err := parseStreamIDs(args[2:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	acked, err := c.XAck(args[0], args[1], ids)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if acked > 0 {
+		propagate(redis, cmd)
+	}
+	return resp.ToRESPInteger(acked)
+}
+
+func handleXPENDING(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 2 {
+		return resp.ToRESPError(wrongArgs("xpending"))
+	}
+	key, group := args[0], args[1]
+	noGroup := "NOGROUP No such key '%s' or consumer group '%s'"
+	if len(args) == 2 {
+		summary, err := c.XPendingSummary(key, group)
+		if err != nil {
+			return groupError(err, noGroup, key, group)
+		}
+		if summary.Count == 0 {
+			return resp.ToRESPArrayLen(4) + resp.ToRESPInteger(0) + resp.ToRESPNullBulkString() + resp.ToRESPNullBulkString() + resp.ToRESPNullArray()
+		}
+		res := resp.ToRESPArrayLen(4) + resp.ToRESPInteger(summary.Count) +
+			resp.ToRESPBulkString(summary.Min.String()) + resp.ToRESPBulkString(summary.Max.String()) +
+			resp.ToRESPArrayLen(len(summary.Consumers))
+		for i, name := range summary.Consumers {
+			res += resp.ToRESPArray([]string{name, strconv.Itoa(summary.Counts[i])})
+		}
+		return res
+	}
+	q := cache.PendingQuery{}
+	rest := args[2:]
+	if strings.ToLower(rest[0]) == "idle" {
+		if len(rest) < 2 {
+			return resp.ToRESPError(ErrSyntax)
+		}
+		minIdle, err := parseInt(rest[1])
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		q.MinIdle = minIdle
+		rest = rest[2:]
+	}
+	if len(rest) < 3 || len(rest) > 4 {
+		return resp.ToRESPError(ErrSyntax)
+	}
+	var err error
+	if q.Start, err = cache.ParseStreamBound(rest[0], false); err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if q.End, err = cache.ParseStreamBound(rest[1], true); err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	count, err := parseInt(rest[2])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	q.Count = int(max(count, 0))
+	if len(rest) == 4 {
+		q.Consumer = rest[3]
+	}
+	entries, err := c.XPending(key, group, q)
+	if err != nil {
+		return groupError(err, noGroup, key, group)
+	}
+	now := time.Now().UnixMilli()
+	res := resp.ToRESPArrayLen(len(entries))
+	for _, entry := range entries {
+		res += resp.ToRESPArrayLen(4) + resp.ToRESPBulkString(entry.Id.String()) + resp.ToRESPBulkString(entry.Consumer) +
+			resp.ToRESPInteger(int(now-entry.DeliveryTime)) + resp.ToRESPInteger(int(entry.DeliveryCount))
+	}
+	return res
+}
+
+// claimReply renders claimed entries, or only their IDs when justID is set.
+func claimReply(entries []cache.StreamType, justID bool) string {
+	if !justID {
+		return resp.ToStreamRESPArray(entries)
+	}
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.Id.String()
+	}
+	return resp.ToRESPArray(ids)
+}
+
+// propagateClaim replicates a claim as an XCLAIM of the given IDs with the
+// delivery time resolved, so replicas do not depend on idle times.
+func propagateClaim(redis redis.Node, key, group, consumer string, ids []string, deliveryTime int64, options []string) {
+	if len(ids) == 0 {
+		return
+	}
+	args := append([]string{"XCLAIM", key, group, consumer, "0"}, ids...)
+	args = append(args, "TIME", strconv.FormatInt(deliveryTime, 10))
+	propagateArgs(redis, append(args, options...))
+}
+
+func handleXCLAIM(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 5 {
+		return resp.ToRESPError(wrongArgs("xclaim"))
+	}
+	key, group, consumer := args[0], args[1], args[2]
+	minIdle, err := strconv.ParseInt(args[3], 10, 64)
+	if err != nil {
+		return resp.ToRESPError("ERR Invalid min-idle-time argument for XCLAIM")
+	}
+	i := 4
+	ids := []cache.StreamID{}
+	for ; i < len(args); i++ {
+		id, err := cache.ParseStreamID(args[i], 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	now := time.Now().UnixMilli()
+	opts := cache.XClaimOptions{DeliveryTime: now}
+	// options are replicated along with the claim, less IDLE and TIME.
+	options := []string{}
+	for ; i < len(args); i++ {
+		option := strings.ToLower(args[i])
+		left := len(args) - i - 1
+		switch {
+		case option == "force":
+			opts.Force = true
+			options = append(options, "FORCE")
+		case option == "justid":
+			opts.JustID = true
+			options = append(options, "JUSTID")
+		case option == "idle" && left >= 1:
+			idle, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return resp.ToRESPError("ERR Invalid IDLE option argument for XCLAIM")
+			}
+			opts.DeliveryTime = now - idle
+			i++
+		case option == "time" && left >= 1:
+			at, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return resp.ToRESPError("ERR Invalid TIME option argument for XCLAIM")
+			}
+			opts.DeliveryTime = at
+			i++
+		case option == "retrycount" && left >= 1:
+			retries, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return resp.ToRESPError("ERR Invalid RETRYCOUNT option argument for XCLAIM")
+			}
+			opts.RetryCount = retries
+			options = append(options, "RETRYCOUNT", args[i+1])
+			i++
+		case option == "lastid" && left >= 1:
+			lastID, err := cache.ParseStreamID(args[i+1], 0)
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			opts.LastID = &lastID
+			options = append(options, "LASTID", args[i+1])
+			i++
+		default:
+			return resp.ToRESPError(fmt.Sprintf("ERR Unrecognized XCLAIM option '%s'", args[i]))
+		}
+	}
+	claimed, deleted, err := c.XClaim(key, group, consumer, max(minIdle, 0), ids, opts)
+	if err != nil {
+		return groupError(err, "NOGROUP No such key '%s' or consumer group '%s'", key, group)
+	}
+	propagated := []string{}
+	for _, entry := range claimed {
+		propagated = append(propagated, entry.Id.String())
+	}
+	for _, id := range deleted {
+		propagated = append(propagated, id.String())
+	}
+	propagateClaim(redis, key, group, consumer, propagated, opts.DeliveryTime, options)
+	return claimReply(claimed, opts.JustID)
+}
+
+func handleXAUTOCLAIM(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 5 {
+		return resp.ToRESPError(wrongArgs("xautoclaim"))
+	}
+	key, group, consumer := args[0], args[1], args[2]
+	minIdle, err := strconv.ParseInt(args[3], 10, 64)
+	if err != nil {
+		return resp.ToRESPError("ERR Invalid min-idle-time argument for XAUTOCLAIM")
+	}
+	start, err := cache.ParseStreamBound(args[4], false)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	count := 100
+	justID := false
+	for i := 5; i < len(args); i++ {
+		switch option := strings.ToLower(args[i]); {
+		case option == "justid":
+			justID = true
+		case option == "count" && i+1 < len(args):
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			if n < 1 || n > 1<<40 {
+				return resp.ToRESPError("ERR COUNT must be > 0")
+			}
+			count = int(n)
+			i++
+		default:
+			return resp.ToRESPError(ErrSyntax)
+		}
+	}
+	now := time.Now().UnixMilli()
+	next, claimed, deleted, err := c.XAutoClaim(key, group, consumer, max(minIdle, 0), start, count, justID)
+	if err != nil {
+		return groupError(err, "NOGROUP No such key '%s' or consumer group '%s'", key, group)
+	}
+	propagated := []string{}
+	for _, entry := range claimed {
+		propagated = append(propagated, entry.Id.String())
+	}
+	deletedIDs := make([]string, len(deleted))
+	for i, id := range deleted {
+		deletedIDs[i] = id.String()
+	}
+	options := []string{}
+	if justID {
+		options = append(options, "JUSTID")
+	}
+	propagateClaim(redis, key, group, consumer, append(propagated, deletedIDs...), now, options)
+	return resp.ToRESPArrayLen(3) + resp.ToRESPBulkString(next.String()) + claimReply(claimed, justID) + resp.ToRESPArray(deletedIDs)
+}
+
+// streamEntryReply renders a single entry, or a null bulk string for nil.
+func streamEntryReply(entry *cache.StreamType) string {
+	if entry == nil {
+		return resp.ToRESPNullBulkString()
+	}
+	return resp.ToRESPArrayLen(2) + resp.ToRESPBulkString(entry.Id.String()) + resp.ToRESPArray(entry.Data)
+}
+
+// optionalInt renders n, or a null bulk string when it is -1.
+func optionalInt(n int64) string {
+	if n == -1 {
+		return resp.ToRESPNullBulkString()
+	}
+	return resp.ToRESPInteger(int(n))
+}
+
+// streamInfoHeader renders the fields XINFO STREAM reports with and without
+// FULL.
+func streamInfoHeader(info *cache.StreamInfo) string {
+	firstID := cache.StreamID{}
+	if info.First != nil {
+		firstID = info.First.Id
+	}
+	return resp.ToRESPBulkString("length") + resp.ToRESPInteger(info.Length) +
+		resp.ToRESPBulkString("radix-tree-keys") + resp.ToRESPInteger(info.Nodes) +
+		resp.ToRESPBulkString("radix-tree-nodes") + resp.ToRESPInteger(info.Nodes) +
+		resp.ToRESPBulkString("last-generated-id") + resp.ToRESPBulkString(info.LastID.String()) +
+		resp.ToRESPBulkString("max-deleted-entry-id") + resp.ToRESPBulkString(info.MaxDeletedID.String()) +
+		resp.ToRESPBulkString("entries-added") + resp.ToRESPInteger(int(info.EntriesAdded)) +
+		resp.ToRESPBulkString("recorded-first-entry-id") + resp.ToRESPBulkString(firstID.String())
+}
+
+func xinfoStream(c cache.Cache, key string, args []string) string {
+	if len(args) == 0 {
+		info, err := c.XInfo(key)
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		if info == nil {
+			return resp.ToRESPError(cache.ErrNoSuchKey.Error())
+		}
+		return resp.ToRESPArrayLen(20) + streamInfoHeader(info) +
+			resp.ToRESPBulkString("groups") + resp.ToRESPInteger(info.Groups) +
+			resp.ToRESPBulkString("first-entry") + streamEntryReply(info.First) +
+			resp.ToRESPBulkString("last-entry") + streamEntryReply(info.Last)
+	}
+	count := 10
+	switch {
+	case strings.ToLower(args[0]) != "full" || len(args) == 2 || len(args) > 3:
+		return resp.ToRESPError(ErrSyntax)
+	case len(args) == 3:
+		if strings.ToLower(args[1]) != "count" {
+			return resp.ToRESPError(ErrSyntax)
+		}
+		n, err := parseInt(args[2])
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		count = int(max(n, 0))
+	}
+	info, entries, groups, err := c.XInfoFull(key, count)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	res := resp.ToRESPArrayLen(18) + streamInfoHeader(info) +
+		resp.ToRESPBulkString("entries") + resp.ToStreamRESPArray(entries) +
+		resp.ToRESPBulkString("groups") + resp.ToRESPArrayLen(len(groups))
+	for _, g := range groups {
+		res += resp.ToRESPArrayLen(14) +
+			resp.ToRESPBulkString("name") + resp.ToRESPBulkString(g.Name) +
+			resp.ToRESPBulkString("last-delivered-id") + resp.ToRESPBulkString(g.LastID.String()) +
+			resp.ToRESPBulkString("entries-read") + optionalInt(g.EntriesRead) +
+			resp.ToRESPBulkString("lag") + optionalInt(g.Lag) +
+			resp.ToRESPBulkString("pel-count") + resp.ToRESPInteger(g.PendingCount) +
+			resp.ToRESPBulkString("pending") + resp.ToRESPArrayLen(len(g.Pending))
+		for _, pe := range g.Pending {
+			res += resp.ToRESPArrayLen(4) + resp.ToRESPBulkString(pe.Id.String()) + resp.ToRESPBulkString(pe.Consumer) +
+				resp.ToRESPInteger(int(pe.DeliveryTime)) + resp.ToRESPInteger(int(pe.DeliveryCount))
+		}
+		res += resp.ToRESPBulkString("consumers") + resp.ToRESPArrayLen(len(g.Consumers))
+		for _, consumer := range g.Consumers {
+			res += resp.ToRESPArrayLen(10) +
+				resp.ToRESPBulkString("name") + resp.ToRESPBulkString(consumer.Name) +
+				resp.ToRESPBulkString("seen-time") + resp.ToRESPInteger(int(consumer.SeenTime)) +
+				resp.ToRESPBulkString("active-time") + resp.ToRESPInteger(int(consumer.ActiveTime)) +
+				resp.ToRESPBulkString("pel-count") + resp.ToRESPInteger(consumer.PendingCount) +
+				resp.ToRESPBulkString("pending") + resp.ToRESPArrayLen(len(consumer.Pending))
+			for _, pe := range consumer.Pending {
+				res += resp.ToRESPArrayLen(3) + resp.ToRESPBulkString(pe.Id.String()) +
+					resp.ToRESPInteger(int(pe.DeliveryTime)) + resp.ToRESPInteger(int(pe.DeliveryCount))
+			}
+		}
+	}
+	return res
+}
+
+func handleXINFO(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 1 {
+		return resp.ToRESPError(wrongArgs("xinfo"))
+	}
+	subcommand := strings.ToLower(args[0])
+	switch {
+	case subcommand == "stream" && len(args) >= 2:
+		return xinfoStream(c, args[1], args[2:])
+	case subcommand == "groups" && len(args) == 2:
+		groups, err := c.XInfoGroups(args[1])
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		res := resp.ToRESPArrayLen(len(groups))
+		for _, g := range groups {
+			res += resp.ToRESPArrayLen(12) +
+				resp.ToRESPBulkString("name") + resp.ToRESPBulkString(g.Name) +
+				resp.ToRESPBulkString("consumers") + resp.ToRESPInteger(len(g.Consumers)) +
+				resp.ToRESPBulkString("pending") + resp.ToRESPInteger(g.PendingCount) +
+				resp.ToRESPBulkString("last-delivered-id") + resp.ToRESPBulkString(g.LastID.String()) +
+				resp.ToRESPBulkString("entries-read") + optionalInt(g.EntriesRead) +
+				resp.ToRESPBulkString("lag") + optionalInt(g.Lag)
+		}
+		return res
+	case subcommand == "consumers" && len(args) == 3:
+		consumers, err := c.XInfoConsumers(args[1], args[2])
+		if err != nil {
+			return groupError(err, "NOGROUP No such consumer group '%s' for key name '%s'", args[2], args[1])
+		}
+		now := time.Now().UnixMilli()
+		res := resp.ToRESPArrayLen(len(consumers))
+		for _, consumer := range consumers {
+			inactive := int64(-1)
+			if consumer.ActiveTime != -1 {
+				inactive = now - consumer.ActiveTime
+			}
+			res += resp.ToRESPArrayLen(8) +
+				resp.ToRESPBulkString("name") + resp.ToRESPBulkString(consumer.Name) +
+				resp.ToRESPBulkString("pending") + resp.ToRESPInteger(consumer.PendingCount) +
+				resp.ToRESPBulkString("idle") + resp.ToRESPInteger(int(now-consumer.SeenTime)) +
+				resp.ToRESPBulkString("inactive") + resp.ToRESPInteger(int(inactive))
+		}
+		return res
+	case subcommand == "help" && len(args) == 1:
+		return resp.ToRESPArray([]string{
+			"XINFO <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"CONSUMERS <key> <groupname>",
+			"    Show consumers of <groupname>.",
+			"GROUPS <key>",
+			"    Show the stream consumer groups.",
+			"STREAM <key> [FULL [COUNT <count>]",
+			"    Show information about the stream.",
+			"HELP",
+			"    Print this help.",
+		})
+	}
+	return resp.ToRESPError(fmt.Sprintf("ERR unknown subcommand or wrong number of arguments for '%s'. Try XINFO HELP.", args[0]))
+}
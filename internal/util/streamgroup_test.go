@@ -0,0 +1,62 @@
+package util
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/resp"
+)
+
+// replicaConn records what the master propagates to a replica.
+type replicaConn struct {
+	written []string
+}
+
+func (c *replicaConn) Write(p []byte) { c.written = append(c.written, string(p)) }
+
+func (c *replicaConn) Read(p []byte) (int, error) { return 0, nil }
+
+func (c *replicaConn) GetConn() net.Conn { return nil }
+
+// masterNode is a master with one replica, standing in for redis.NodeType.
+type masterNode struct {
+	redis.Node
+	replica *replicaConn
+}
+
+func (n masterNode) GetSlaveConn() []redis.Conn { return []redis.Conn{n.replica} }
+
+// Whether or not the entries become pending, replicas get the last ID and
+// entries read of the group, which XCLAIM alone does not carry.
+func TestXReadGroupPropagatesEntriesRead(t *testing.T) {
+	c := cache.NewCache()
+	for _, id := range []string{"1-1", "1-2"} {
+		c.XAdd("s", id, []string{"field", "value"}, cache.XAddOptions{})
+	}
+	c.XGroupCreate("s", "g", "0", false, -1)
+	for i, noAck := range []bool{false, true} {
+		node := masterNode{replica: &replicaConn{}}
+		args := []string{"XREADGROUP", "GROUP", "g", "alice", "COUNT", "1"}
+		if noAck {
+			args = append(args, "NOACK")
+		}
+		cmd, err := command.NewCommand(append(args, "STREAMS", "s", ">"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		handleXREADGROUP(*cmd, c, node, NewClient(nil))
+		id := []string{"1-1", "1-2"}[i]
+		want := resp.ToRESPArray([]string{"XGROUP", "SETID", "s", "g", id, "ENTRIESREAD", strconv.Itoa(i + 1)})
+		written := node.replica.written
+		if len(written) == 0 || written[len(written)-1] != want {
+			t.Fatalf("noack %v: propagated %q, want %q last", noAck, written, want)
+		}
+		if claims := len(written) - 1; noAck && claims != 0 || !noAck && claims != 1 {
+			t.Fatalf("noack %v: propagated %d XCLAIMs", noAck, claims)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/resp"
+)
+
+func handleIncrBy(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	name := cmd.GetName()
+	delta := int64(1)
+	switch name {
+	case command.INCR, command.DECR:
+		if len(cmd.GetArgs()) != 1 {
+			return resp.ToRESPError(wrongArgs(name))
+		}
+	default:
+		if len(cmd.GetArgs()) != 2 {
+			return resp.ToRESPError(wrongArgs(name))
+		}
+		n, err := parseInt(cmd.GetArg(1))
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		delta = n
+	}
+	if name == command.DECR || name == command.DECRBY {
+		if delta == -delta && delta != 0 {
+			return resp.ToRESPError("ERR decrement would overflow")
+		}
+		delta = -delta
+	}
+	value, err := c.IncrBy(cmd.GetArg(0), delta)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPInteger(int(value))
+}
+
+func handleIncrByFloat(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 2 {
+		return resp.ToRESPError(wrongArgs("incrbyfloat"))
+	}
+	delta, ok := cache.ParseFloat(cmd.GetArg(1))
+	if !ok {
+		return resp.ToRESPError(cache.ErrNotFloat.Error())
+	}
+	value, err := c.IncrByFloat(cmd.GetArg(0), delta)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	// Replicas must not redo the float arithmetic, so send them the result.
+	propagateArgs(redis, []string{"SET", cmd.GetArg(0), value, "KEEPTTL"})
+	return resp.ToRESPBulkString(value)
+}
+
+func handleAppend(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 2 {
+		return resp.ToRESPError(wrongArgs("append"))
+	}
+	length, err := c.Append(cmd.GetArg(0), cmd.GetArg(1))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPInteger(length)
+}
+
+func handleStrLen(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) != 1 {
+		return resp.ToRESPError(wrongArgs("strlen"))
+	}
+	length, err := c.StrLen(cmd.GetArg(0))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPInteger(length)
+}
+
+func handleGetRange(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) != 3 {
+		return resp.ToRESPError(wrongArgs("getrange"))
+	}
+	start, err := parseInt(cmd.GetArg(1))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	end, err := parseInt(cmd.GetArg(2))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	value, err := c.GetRange(cmd.GetArg(0), start, end)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPBulkString(value)
+}
+
+func handleSetRange(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 3 {
+		return resp.ToRESPError(wrongArgs("setrange"))
+	}
+	offset, err := parseInt(cmd.GetArg(1))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	length, err := c.SetRange(cmd.GetArg(0), offset, cmd.GetArg(2))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if cmd.GetArg(2) != "" {
+		propagate(redis, cmd)
+	}
+	return resp.ToRESPInteger(length)
+}
+
+func handleMGet(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) < 1 {
+		return resp.ToRESPError(wrongArgs("mget"))
+	}
+	return resp.ToRESPNullableArray(c.MGet(cmd.GetArgs()))
+}
+
+func handleMSet(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	name := cmd.GetName()
+	if len(cmd.GetArgs()) < 2 || len(cmd.GetArgs())%2 != 0 {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	written := c.MSet(cmd.GetArgs(), name == command.MSETNX)
+	if written {
+		propagate(redis, cmd)
+	}
+	if name == command.MSET {
+		return resp.ToRESPSimpleString("OK")
+	}
+	if written {
+		return resp.ToRESPInteger(1)
+	}
+	return resp.ToRESPInteger(0)
+}
+
+func handleGetSet(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 2 {
+		return resp.ToRESPError(wrongArgs("getset"))
+	}
+	res, err := c.SetWithOptions(cmd.GetArg(0), cmd.GetArg(1), cache.SetOptions{Get: true})
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagateArgs(redis, []string{"SET", cmd.GetArg(0), cmd.GetArg(1)})
+	if !res.OldExists {
+		return resp.ToRESPNullBulkString()
+	}
+	return resp.ToRESPBulkString(res.Old)
+}
+
+func handleGetDel(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 1 {
+		return resp.ToRESPError(wrongArgs("getdel"))
+	}
+	value, ok, err := c.GetDel(cmd.GetArg(0))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if !ok {
+		return resp.ToRESPNullBulkString()
+	}
+	propagateArgs(redis, []string{"DEL", cmd.GetArg(0)})
+	return resp.ToRESPBulkString(value)
+}
+
+func handleGetEx(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 1 {
+		return resp.ToRESPError(wrongArgs("getex"))
+	}
+	expireAt := int64(0)
+	persist := false
+	for i := 1; i < len(args); i++ {
+		switch option := strings.ToLower(args[i]); option {
+		case "persist":
+			if expireAt > 0 || persist {
+				return resp.ToRESPError(ErrSyntax)
+			}
+			persist = true
+		case "ex", "px", "exat", "pxat":
+			if expireAt > 0 || persist || i+1 >= len(args) {
+				return resp.ToRESPError(ErrSyntax)
+			}
+			at, err := parseExpireAt(option, args[i+1], "getex")
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			expireAt = at
+			i++
+		default:
+			return resp.ToRESPError(ErrSyntax)
+		}
+	}
+	value, ok, err := c.GetEx(args[0], expireAt, persist)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if !ok {
+		return resp.ToRESPNullBulkString()
+	}
+	if expireAt > 0 {
+		propagateArgs(redis, []string{"GETEX", args[0], "PXAT", strconv.FormatInt(expireAt, 10)})
+	} else if persist {
+		propagateArgs(redis, []string{"GETEX", args[0], "PERSIST"})
+	}
+	return resp.ToRESPBulkString(value)
+}
+
+func handleSetNX(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 2 {
+		return resp.ToRESPError(wrongArgs("setnx"))
+	}
+	res, err := c.SetWithOptions(cmd.GetArg(0), cmd.GetArg(1), cache.SetOptions{NX: true})
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if !res.Written {
+		return resp.ToRESPInteger(0)
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPInteger(1)
+}
+
+// handleSetEx serves SETEX and PSETEX, which take the ttl before the value.
+func handleSetEx(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	name := cmd.GetName()
+	if len(cmd.GetArgs()) != 3 {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	unit := "ex"
+	if name == command.PSETEX {
+		unit = "px"
+	}
+	expireAt, err := parseExpireAt(unit, cmd.GetArg(1), name)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if _, err := c.SetWithOptions(cmd.GetArg(0), cmd.GetArg(2), cache.SetOptions{ExpireAt: expireAt}); err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagateArgs(redis, []string{"SET", cmd.GetArg(0), cmd.GetArg(2), "PXAT", strconv.FormatInt(expireAt, 10)})
+	return resp.ToRESPSimpleString("OK")
+}
+
+func handleLCS(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 2 {
+		return resp.ToRESPError(wrongArgs("lcs"))
+	}
+	getLen, getIdx, withMatchLen := false, false, false
+	minMatchLen := int64(0)
+	for i := 2; i < len(args); i++ {
+		switch option := strings.ToLower(args[i]); {
+		case option == "len":
+			getLen = true
+		case option == "idx":
+			getIdx = true
+		case option == "withmatchlen":
+			withMatchLen = true
+		case option == "minmatchlen" && i+1 < len(args):
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				return resp.ToRESPError(err.Error())
+			}
+			minMatchLen = max(n, 0)
+			i++
+		default:
+			return resp.ToRESPError(ErrSyntax)
+		}
+	}
+	if getLen && getIdx {
+		return resp.ToRESPError("ERR If you want both the length and indexes, please just use IDX.")
+	}
+	res, err := c.LCS(args[0], args[1], int(minMatchLen))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if getLen {
+		return resp.ToRESPInteger(res.Len)
+	}
+	if !getIdx {
+		return resp.ToRESPBulkString(res.Sequence)
+	}
+	reply := resp.ToRESPArrayLen(4) + resp.ToRESPBulkString("matches") + resp.ToRESPArrayLen(len(res.Matches))
+	for _, match := range res.Matches {
+		if withMatchLen {
+			reply += resp.ToRESPArrayLen(3)
+		} else {
+			reply += resp.ToRESPArrayLen(2)
+		}
+		reply += resp.ToRESPArrayLen(2) + resp.ToRESPInteger(match.A[0]) + resp.ToRESPInteger(match.A[1]) +
+			resp.ToRESPArrayLen(2) + resp.ToRESPInteger(match.B[0]) + resp.ToRESPInteger(match.B[1])
+		if withMatchLen {
+			reply += resp.ToRESPInteger(match.Len)
+		}
+	}
+	return reply + resp.ToRESPBulkString("len") + resp.ToRESPInteger(res.Len)
+}
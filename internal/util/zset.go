@@ -0,0 +1,399 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/resp"
+)
+
+// zmembersReply renders members as a flat array, with each score after its
+// member when withScores is set.
+func zmembersReply(members []cache.ZMember, withScores bool) string {
+	res := []string{}
+	for _, m := range members {
+		res = append(res, m.Member)
+		if withScores {
+			res = append(res, cache.FormatScore(m.Score))
+		}
+	}
+	return resp.ToRESPArray(res)
+}
+
+func handleZAdd(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 3 {
+		return resp.ToRESPError(wrongArgs("zadd"))
+	}
+	opts := cache.ZAddOptions{}
+	ch := false
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "nx":
+			opts.NX = true
+		case "xx":
+			opts.XX = true
+		case "gt":
+			opts.GT = true
+		case "lt":
+			opts.LT = true
+		case "ch":
+			ch = true
+		case "incr":
+			opts.Incr = true
+		default:
+			break options
+		}
+	}
+	rest := args[i:]
+	if len(rest) == 0 || len(rest)%2 != 0 {
+		return resp.ToRESPError(ErrSyntax)
+	}
+	if opts.NX && opts.XX {
+		return resp.ToRESPError("ERR XX and NX options at the same time are not compatible")
+	}
+	if (opts.GT && opts.LT) || (opts.NX && (opts.GT || opts.LT)) {
+		return resp.ToRESPError("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if opts.Incr && len(rest) != 2 {
+		return resp.ToRESPError("ERR INCR option supports a single increment-element pair")
+	}
+	members := []cache.ZMember{}
+	for j := 0; j < len(rest); j += 2 {
+		score, ok := cache.ParseFloat(rest[j])
+		if !ok {
+			return resp.ToRESPError(cache.ErrNotFloat.Error())
+		}
+		members = append(members, cache.ZMember{Member: rest[j+1], Score: score})
+	}
+	added, changed, score, ok, err := c.ZAdd(args[0], members, opts)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if added+changed > 0 {
+		propagate(redis, cmd)
+	}
+	if opts.Incr {
+		if !ok {
+			return resp.ToRESPNullBulkString()
+		}
+		return resp.ToRESPBulkString(cache.FormatScore(score))
+	}
+	if ch {
+		return resp.ToRESPInteger(added + changed)
+	}
+	return resp.ToRESPInteger(added)
+}
+
+func handleZIncrBy(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) != 3 {
+		return resp.ToRESPError(wrongArgs("zincrby"))
+	}
+	incr, ok := cache.ParseFloat(cmd.GetArg(1))
+	if !ok {
+		return resp.ToRESPError(cache.ErrNotFloat.Error())
+	}
+	members := []cache.ZMember{{Member: cmd.GetArg(2), Score: incr}}
+	_, _, score, _, err := c.ZAdd(cmd.GetArg(0), members, cache.ZAddOptions{Incr: true})
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPBulkString(cache.FormatScore(score))
+}
+
+func handleZRem(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	if len(cmd.GetArgs()) < 2 {
+		return resp.ToRESPError(wrongArgs("zrem"))
+	}
+	removed, err := c.ZRem(cmd.GetArg(0), cmd.GetArgs()[1:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if removed > 0 {
+		propagate(redis, cmd)
+	}
+	return resp.ToRESPInteger(removed)
+}
+
+// handleZScore serves ZSCORE and ZMSCORE.
+func handleZScore(cmd command.Command, c cache.Cache) string {
+	name := cmd.GetName()
+	args := cmd.GetArgs()
+	if len(args) < 2 || (name == command.ZSCORE && len(args) != 2) {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	scores, err := c.ZScore(args[0], args[1:])
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	res := make([]*string, len(scores))
+	for i, score := range scores {
+		if score != nil {
+			formatted := cache.FormatScore(*score)
+			res[i] = &formatted
+		}
+	}
+	if name == command.ZMSCORE {
+		return resp.ToRESPNullableArray(res)
+	}
+	if res[0] == nil {
+		return resp.ToRESPNullBulkString()
+	}
+	return resp.ToRESPBulkString(*res[0])
+}
+
+func handleZCard(cmd command.Command, c cache.Cache) string {
+	if len(cmd.GetArgs()) != 1 {
+		return resp.ToRESPError(wrongArgs("zcard"))
+	}
+	card, err := c.ZCard(cmd.GetArg(0))
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPInteger(card)
+}
+
+// handleZCount serves ZCOUNT and ZLEXCOUNT.
+func handleZCount(cmd command.Command, c cache.Cache) string {
+	name := cmd.GetName()
+	if len(cmd.GetArgs()) != 3 {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	q := cache.ZRangeQuery{By: "score"}
+	var err error
+	if name == command.ZLEXCOUNT {
+		q.By = "lex"
+		q.Lex, err = cache.ParseLexRange(cmd.GetArg(1), cmd.GetArg(2))
+	} else {
+		q.Score, err = cache.ParseScoreRange(cmd.GetArg(1), cmd.GetArg(2))
+	}
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	count, err := c.ZCount(cmd.GetArg(0), q)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return resp.ToRESPInteger(count)
+}
+
+// handleZRank serves ZRANK and ZREVRANK.
+func handleZRank(cmd command.Command, c cache.Cache) string {
+	name := cmd.GetName()
+	args := cmd.GetArgs()
+	if len(args) < 2 || len(args) > 3 {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	withScore := len(args) == 3
+	if withScore && strings.ToLower(args[2]) != "withscore" {
+		return resp.ToRESPError(ErrSyntax)
+	}
+	rank, score, ok, err := c.ZRank(args[0], args[1], name == command.ZREVRANK)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if !ok {
+		if withScore {
+			return resp.ToRESPNullArray()
+		}
+		return resp.ToRESPNullBulkString()
+	}
+	if withScore {
+		return resp.ToRESPArrayLen(2) + resp.ToRESPInteger(rank) + resp.ToRESPBulkString(cache.FormatScore(score))
+	}
+	return resp.ToRESPInteger(rank)
+}
+
+// parseZRange reads the start and stop of a ZRANGE or ZRANGESTORE followed
+// by its options. It reports whether WITHSCORES was given, which is only
+// accepted when withScoresAllowed is set.
+func parseZRange(start, stop string, options []string, withScoresAllowed bool) (cache.ZRangeQuery, bool, error) {
+	q := cache.ZRangeQuery{By: "rank", Count: -1}
+	withScores := false
+	limit := false
+	for i := 0; i < len(options); i++ {
+		switch strings.ToLower(options[i]) {
+		case "byscore":
+			q.By = "score"
+		case "bylex":
+			q.By = "lex"
+		case "rev":
+			q.Rev = true
+		case "withscores":
+			if !withScoresAllowed {
+				return q, false, fmt.Errorf(ErrSyntax)
+			}
+			withScores = true
+		case "limit":
+			if i+2 >= len(options) {
+				return q, false, fmt.Errorf(ErrSyntax)
+			}
+			offset, err := parseInt(options[i+1])
+			if err != nil {
+				return q, false, err
+			}
+			count, err := parseInt(options[i+2])
+			if err != nil {
+				return q, false, err
+			}
+			q.Offset, q.Count = offset, count
+			limit = true
+			i += 2
+		default:
+			return q, false, fmt.Errorf(ErrSyntax)
+		}
+	}
+	if limit && q.By == "rank" {
+		return q, false, fmt.Errorf("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if withScores && q.By == "lex" {
+		return q, false, fmt.Errorf("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+	// Reversed score and lex ranges are given from max to min.
+	min, max := start, stop
+	if q.Rev {
+		min, max = stop, start
+	}
+	var err error
+	switch q.By {
+	case "score":
+		q.Score, err = cache.ParseScoreRange(min, max)
+	case "lex":
+		q.Lex, err = cache.ParseLexRange(min, max)
+	default:
+		if q.Start, err = parseInt(start); err == nil {
+			q.Stop, err = parseInt(stop)
+		}
+	}
+	return q, withScores, err
+}
+
+func handleZRange(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 3 {
+		return resp.ToRESPError(wrongArgs("zrange"))
+	}
+	q, withScores, err := parseZRange(args[1], args[2], args[3:], true)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	members, err := c.ZRange(args[0], q)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return zmembersReply(members, withScores)
+}
+
+func handleZRangeStore(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	args := cmd.GetArgs()
+	if len(args) < 4 {
+		return resp.ToRESPError(wrongArgs("zrangestore"))
+	}
+	q, _, err := parseZRange(args[2], args[3], args[4:], false)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	card, err := c.ZRangeStore(args[0], args[1], q)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	propagate(redis, cmd)
+	return resp.ToRESPInteger(card)
+}
+
+// handleZPop serves ZPOPMIN and ZPOPMAX.
+func handleZPop(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	name := cmd.GetName()
+	args := cmd.GetArgs()
+	if len(args) < 1 || len(args) > 2 {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	count := 1
+	if len(args) == 2 {
+		n, err := parsePositiveCount(args[1])
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		count = n
+	}
+	members, err := c.ZPop(args[0], count, name == command.ZPOPMAX)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if len(members) > 0 {
+		propagate(redis, cmd)
+	}
+	return zmembersReply(members, true)
+}
+
+func handleZRandMember(cmd command.Command, c cache.Cache) string {
+	args := cmd.GetArgs()
+	if len(args) < 1 || len(args) > 3 {
+		return resp.ToRESPError(wrongArgs("zrandmember"))
+	}
+	if len(args) == 1 {
+		members, err := c.ZRandMember(args[0], 1)
+		if err != nil {
+			return resp.ToRESPError(err.Error())
+		}
+		if len(members) == 0 {
+			return resp.ToRESPNullBulkString()
+		}
+		return resp.ToRESPBulkString(members[0].Member)
+	}
+	withScores := len(args) == 3
+	if withScores && strings.ToLower(args[2]) != "withscores" {
+		return resp.ToRESPError(ErrSyntax)
+	}
+	count, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || count < -maxRandomRepeats {
+		return resp.ToRESPError("ERR value is out of range")
+	}
+	members, err := c.ZRandMember(args[0], count)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	return zmembersReply(members, withScores)
+}
+
+// handleZRemRange serves ZREMRANGEBYRANK, ZREMRANGEBYSCORE and
+// ZREMRANGEBYLEX.
+func handleZRemRange(cmd command.Command, c cache.Cache, redis redis.Node) string {
+	name := cmd.GetName()
+	if len(cmd.GetArgs()) != 3 {
+		return resp.ToRESPError(wrongArgs(name))
+	}
+	q := cache.ZRangeQuery{Count: -1}
+	var err error
+	switch name {
+	case command.ZREMRANGEBYRANK:
+		q.By = "rank"
+		if q.Start, err = parseInt(cmd.GetArg(1)); err == nil {
+			q.Stop, err = parseInt(cmd.GetArg(2))
+		}
+	case command.ZREMRANGEBYSCORE:
+		q.By = "score"
+		q.Score, err = cache.ParseScoreRange(cmd.GetArg(1), cmd.GetArg(2))
+	default:
+		q.By = "lex"
+		q.Lex, err = cache.ParseLexRange(cmd.GetArg(1), cmd.GetArg(2))
+	}
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	removed, err := c.ZRemRange(cmd.GetArg(0), q)
+	if err != nil {
+		return resp.ToRESPError(err.Error())
+	}
+	if removed > 0 {
+		propagate(redis, cmd)
+	}
+	return resp.ToRESPInteger(removed)
+}
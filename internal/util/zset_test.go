@@ -0,0 +1,22 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/cache"
+	"github.com/codecrafters-io/redis-starter-go/internal/command"
+)
+
+func TestZRandMemberRejectsHugeNegativeCount(t *testing.T) {
+	c := cache.NewCache()
+	c.ZAdd("zset", []cache.ZMember{{Member: "a", Score: 1}}, cache.ZAddOptions{})
+	for _, count := range []string{"-2305843009213693952", "-9223372036854775808"} {
+		cmd, err := command.NewCommand([]string{"ZRANDMEMBER", "zset", count})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := handleZRandMember(*cmd, c); res != "-ERR value is out of range\r\n" {
+			t.Fatalf("ZRANDMEMBER zset %s = %q", count, res)
+		}
+	}
+}